@@ -0,0 +1,109 @@
+// github.com/DauletBai/oilan.org/cmd/evaluate/main.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/app/evaluation"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/infrastructure/llm"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// evaluate plays a suite of scripted conversations against a persona and checks every reply
+// against the rules of the suite. The LLM is configured with the same LLM_* variables as the
+// server, so it runs against the mock provider, a cassette or a local model as well.
+// It exits with status 1 if any check failed.
+func main() {
+	suitePath := flag.String("suite", "configs/eval_suite.json", "suite of conversations and rules")
+	personasPath := flag.String("personas", "configs/personas.json", "persona configuration")
+	personaID := flag.String("persona", "", "persona to evaluate in every case (default: the persona of each case)")
+	promptPath := flag.String("prompt", "", "system prompt file to evaluate instead of the persona's, e.g. a draft")
+	judgeProvider := flag.String("judge", "", "provider that answers judge rules, configured with LLM_<NAME>_* (judge rules are skipped if empty)")
+	format := flag.String("format", "json", "report format: json or junit")
+	outPath := flag.String("out", "", "report file (default: stdout)")
+	timeout := flag.Duration("timeout", 2*time.Minute, "limit for a single reply")
+	flag.Parse()
+
+	if *format != "json" && *format != "junit" {
+		log.Fatalf("unknown report format %q", *format)
+	}
+
+	suite, err := evaluation.LoadSuite(*suitePath)
+	if err != nil {
+		log.Fatalf("failed to load suite: %v", err)
+	}
+	personas, err := services.LoadPersonas(*personasPath)
+	if err != nil {
+		log.Fatalf("failed to load personas: %v", err)
+	}
+	if _, ok := personas.Get(*personaID); *personaID != "" && !ok {
+		log.Fatalf("unknown persona %q", *personaID)
+	}
+
+	runner := &evaluation.Runner{Personas: personas, Persona: *personaID, Timeout: *timeout}
+	if *promptPath != "" {
+		prompt, err := os.ReadFile(*promptPath)
+		if err != nil {
+			log.Fatalf("failed to read prompt: %v", err)
+		}
+		runner.Prompt = strings.TrimSpace(string(prompt))
+		if err := services.ValidatePrompt(runner.Prompt); err != nil {
+			log.Fatalf("%s: %v", *promptPath, err)
+		}
+	}
+
+	// --- LLM Clients ---
+	if os.Getenv("LLM_CASSETTE_MODE") != string(llm.CassetteReplay) {
+		runner.Client, err = llm.NewClientChain(llm.ChainConfigFromEnv(), llm.DefaultFallbackPolicy())
+		if err != nil {
+			log.Fatalf("failed to create llm client: %v", err)
+		}
+	}
+	runner.Client, err = llm.WrapWithCassetteFromEnv(runner.Client)
+	if err != nil {
+		log.Fatalf("failed to set up llm cassette: %v", err)
+	}
+	runner.Now = llm.PromptClockFromEnv()
+	if *judgeProvider != "" {
+		runner.Judge, err = llm.NewClient(llm.NamedConfigFromEnv(*judgeProvider))
+		if err != nil {
+			log.Fatalf("failed to create judge: %v", err)
+		}
+	}
+
+	// --- Run ---
+	report := runner.Run(context.Background(), suite)
+
+	if err := writeReport(report, *format, *outPath); err != nil {
+		log.Fatalf("failed to write report: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: %d passed, %d failed, %d skipped, %d errors in %s\n",
+		suite.Name, report.Passed, report.Failed, report.Skipped, report.Errors, report.Duration.Round(time.Millisecond))
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+// writeReport writes the report in the given format to a file, or to stdout if path is empty.
+func writeReport(report *evaluation.Report, format, path string) error {
+	var out io.Writer = os.Stdout
+	if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	if format == "junit" {
+		return report.WriteJUnit(out)
+	}
+	return report.WriteJSON(out)
+}
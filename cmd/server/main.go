@@ -9,12 +9,17 @@ import (
 	//"github.com/DauletBai/oilan.org/internal/auth"
 	"github.com/DauletBai/oilan.org/internal/domain/repository"
 	"github.com/DauletBai/oilan.org/internal/infrastructure/handlers"
+	"github.com/DauletBai/oilan.org/internal/infrastructure/hotreload"
 	"github.com/DauletBai/oilan.org/internal/infrastructure/llm"
-	//"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
+	"github.com/DauletBai/oilan.org/internal/infrastructure/notify"
+	"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
+	"github.com/DauletBai/oilan.org/internal/infrastructure/repository/memory"
 	"github.com/DauletBai/oilan.org/internal/infrastructure/repository/postgres"
 	"github.com/DauletBai/oilan.org/internal/infrastructure/server"
 	"github.com/DauletBai/oilan.org/internal/view"
 	"os"
+	"strconv"
+	"time"
 
 	//"github.com/go-chi/chi/v5"
 	"github.com/gorilla/sessions"
@@ -53,6 +58,74 @@ func bootstrapAdmin(userRepo repository.UserRepository) {
 	}
 }
 
+// contextBudgetFromEnv reads the context window limits, keeping the defaults for unset variables.
+func contextBudgetFromEnv() services.ContextBudget {
+	budget := services.DefaultContextBudget()
+	if v, err := strconv.Atoi(os.Getenv("LLM_CONTEXT_TOKENS")); err == nil && v > 0 {
+		budget.MaxTokens = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("LLM_REPLY_TOKENS")); err == nil && v > 0 {
+		budget.ReplyTokens = v
+	}
+	return budget
+}
+
+// hotReloadInterval is how often the watched directories are checked for changes.
+const hotReloadInterval = time.Second
+
+// startHotReload re-parses templates and configuration whenever files below web/templates or
+// configs change. Anything that fails to load is logged and the last good version stays in use,
+// so open WebSocket connections survive a broken edit.
+func startHotReload(templates []*view.Template, personas *services.PersonaRegistry, phases *services.PhaseRegistry, crisisKeywords *services.KeywordClassifier, redactor *services.Redactor, consentService *services.ConsentService, rateLimiter *services.RateLimiter, promptService *services.PromptService, quotaService *services.QuotaService) {
+	watcher := hotreload.NewWatcher(hotReloadInterval)
+
+	err := watcher.Watch("web/templates", func() {
+		for _, tpl := range templates {
+			if err := tpl.Reload(); err != nil {
+				log.Printf("Hot reload: keeping the previous template: %v", err)
+			}
+		}
+	})
+	if err != nil {
+		log.Fatalf("could not watch templates: %v", err)
+	}
+
+	err = watcher.Watch("configs", func() {
+		if err := personas.Reload(); err != nil {
+			log.Printf("Hot reload: keeping the previous personas: %v", err)
+		} else if err := promptService.Seed(context.Background()); err != nil {
+			log.Printf("Hot reload: could not seed prompts of new personas: %v", err)
+		}
+		if err := phases.Reload(); err != nil {
+			log.Printf("Hot reload: keeping the previous phases: %v", err)
+		}
+		if err := crisisKeywords.Reload(); err != nil {
+			log.Printf("Hot reload: keeping the previous crisis keywords: %v", err)
+		}
+		if err := redactor.Reload(); err != nil {
+			log.Printf("Hot reload: keeping the previous redaction detectors: %v", err)
+		}
+		if err := consentService.Publish(context.Background()); err != nil {
+			log.Printf("Hot reload: keeping the previous legal documents: %v", err)
+		}
+		if err := rateLimiter.Reload(); err != nil {
+			log.Printf("Hot reload: keeping the previous rate limits: %v", err)
+		}
+		roleQuotas, err := services.LoadRoleQuotas("configs/quotas.json")
+		if err != nil {
+			log.Printf("Hot reload: keeping the previous quotas: %v", err)
+			return
+		}
+		quotaService.SetRoleQuotas(roleQuotas)
+	})
+	if err != nil {
+		log.Fatalf("could not watch configs: %v", err)
+	}
+
+	go watcher.Run(context.Background())
+	log.Println("Hot reload enabled for web/templates and configs")
+}
+
 func main() {
 	// --- Goth Configuration ---
 	googleClientID := os.Getenv("GOOGLE_CLIENT_ID")
@@ -75,6 +148,14 @@ func main() {
 	// --- Repositories ---
 	userRepo := postgres.NewUserRepository(db)
 	dialogRepo := postgres.NewDialogRepository(db)
+	summaryRepo := postgres.NewSummaryRepository(db)
+	quotaRepo := postgres.NewQuotaRepository(db)
+	promptRepo := postgres.NewPromptRepository(db)
+	phaseRepo := postgres.NewPhaseRepository(db)
+	crisisRepo := postgres.NewCrisisRepository(db)
+	moderationRepo := postgres.NewModerationRepository(db)
+	feedbackRepo := postgres.NewFeedbackRepository(db)
+	consentRepo := postgres.NewConsentRepository(db)
 
 	bootstrapAdmin(userRepo)
 
@@ -82,16 +163,111 @@ func main() {
 	bootstrapAdmin(userRepo)
 
 	// --- LLM Client ---
-	llmClient, err := llm.NewGeminiClient()
+	var llmClient services.LLMClient
+	if os.Getenv("LLM_CASSETTE_MODE") != string(llm.CassetteReplay) {
+		llmConfigs := llm.ChainConfigFromEnv()
+		llmClient, err = llm.NewClientChain(llmConfigs, llm.DefaultFallbackPolicy())
+		if err != nil {
+			log.Fatalf("failed to create llm client: %v", err)
+		}
+		for i, cfg := range llmConfigs {
+			log.Printf("LLM provider #%d: %s", i+1, cfg.Provider)
+		}
+	}
+	llmClient, err = llm.WrapWithCassetteFromEnv(llmClient)
 	if err != nil {
-		log.Fatalf("failed to create gemini client: %v", err)
+		log.Fatalf("failed to set up llm cassette: %v", err)
+	}
+
+	// Personal data is replaced with placeholders before anything reaches the provider, or a cassette.
+	redactor, err := services.LoadRedactor("configs/redaction.json")
+	if err != nil {
+		log.Fatalf("failed to load redaction detectors: %v", err)
+	}
+	chatRedactor := redactor
+	if os.Getenv("PII_REDACTION") == "false" {
+		chatRedactor = nil
+		log.Println("PII redaction is disabled; user text is sent to the LLM provider as is")
+	} else {
+		llmClient = services.NewRedactingClient(llmClient, redactor)
 	}
 
 	// --- Services ---
-	chatService, err := services.NewChatService(dialogRepo, llmClient)
+	roleQuotas, err := services.LoadRoleQuotas("configs/quotas.json")
+	if err != nil {
+		log.Fatalf("failed to load quotas: %v", err)
+	}
+	quotaService := services.NewQuotaService(quotaRepo, userRepo, roleQuotas)
+
+	personas, err := services.LoadPersonas("configs/personas.json")
+	if err != nil {
+		log.Fatalf("failed to load personas: %v", err)
+	}
+
+	phases, err := services.LoadPhases("configs/phases.json")
+	if err != nil {
+		log.Fatalf("failed to load phases: %v", err)
+	}
+	phaseService, err := services.NewPhaseService(phases, personas, phaseRepo)
+	if err != nil {
+		log.Fatalf("failed to create phase service: %v", err)
+	}
+
+	// Crises are detected by keywords first, which works without the LLM; the LLM classifier is optional.
+	crisisKeywords, err := services.LoadCrisisKeywords("configs/crisis.json")
+	if err != nil {
+		log.Fatalf("failed to load crisis keywords: %v", err)
+	}
+	crisisClassifiers := []services.CrisisClassifier{crisisKeywords}
+	if os.Getenv("CRISIS_LLM_CLASSIFIER") == "true" {
+		crisisClassifiers = append(crisisClassifiers, services.NewLLMCrisisClassifier(llmClient))
+	}
+	var crisisNotifier services.CrisisNotifier = notify.LogNotifier{}
+	if webhookURL := os.Getenv("CRISIS_WEBHOOK_URL"); webhookURL != "" {
+		crisisNotifier = notify.NewWebhookNotifier(webhookURL, os.Getenv("APP_BASE_URL"))
+	}
+	crisisService := services.NewCrisisService(crisisRepo, crisisNotifier, crisisClassifiers...)
+
+	feedbackThreshold := services.DefaultFeedbackThreshold
+	if v, err := strconv.Atoi(os.Getenv("MODERATION_FEEDBACK_THRESHOLD")); err == nil {
+		feedbackThreshold = v
+	}
+	moderationService := services.NewModerationService(moderationRepo, feedbackRepo, feedbackThreshold)
+
+	promptService := services.NewPromptService(promptRepo, personas, userRepo, dialogRepo, summaryRepo)
+	if err := promptService.Seed(context.Background()); err != nil {
+		log.Fatalf("failed to seed prompts: %v", err)
+	}
+
+	consentService := services.NewConsentService(consentRepo, "configs/legal.json")
+	if err := consentService.Publish(context.Background()); err != nil {
+		log.Fatalf("failed to publish legal documents: %v", err)
+	}
+
+	// Messages to a dialog are answered one at a time, across all instances.
+	dialogLocks := services.NewDialogLocks(postgres.NewDialogLocker(db))
+
+	chatService, err := services.NewChatService(dialogRepo, summaryRepo, llmClient, contextBudgetFromEnv(), quotaService, personas, promptService, phaseService, crisisService, moderationService, chatRedactor, dialogLocks)
 	if err != nil {
 		log.Fatalf("failed to create chat service: %v", err)
 	}
+	chatService.SetPromptClock(llm.PromptClockFromEnv())
+
+	// Buckets live in memory unless several instances have to share them.
+	var rateLimitStore repository.RateLimitStore
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+		rateLimitStore = memory.NewRateLimitStore()
+	case "postgres":
+		rateLimitStore = postgres.NewRateLimitStore(db)
+	default:
+		log.Fatalf("unknown RATE_LIMIT_STORE %q; use memory or postgres", store)
+	}
+	rateLimiter, err := services.LoadRateLimits("configs/ratelimit.json", rateLimitStore)
+	if err != nil {
+		log.Fatalf("failed to load rate limits: %v", err)
+	}
+	rateLimiter.StartPruning(context.Background())
 
 	// --- Template Parsing ---
 	welcomeTpl, err := view.NewTemplate(
@@ -112,6 +288,39 @@ func main() {
 	)
 	if err != nil { log.Fatalf("could not parse chat template: %v", err) }
 
+	consentTpl, err := view.NewTemplate(
+		"web/templates/base.html",
+		"web/templates/parts/head.html",
+		"web/templates/parts/header.html",
+		"web/templates/parts/footer.html",
+		"web/templates/pages/consent.html",
+	)
+	if err != nil {
+		log.Fatalf("could not parse consent template: %v", err)
+	}
+
+	consentsTpl, err := view.NewTemplate(
+		"web/templates/base.html",
+		"web/templates/parts/head.html",
+		"web/templates/parts/header.html",
+		"web/templates/parts/footer.html",
+		"web/templates/pages/consents.html",
+	)
+	if err != nil {
+		log.Fatalf("could not parse consents template: %v", err)
+	}
+
+	legalTpl, err := view.NewTemplate(
+		"web/templates/base.html",
+		"web/templates/parts/head.html",
+		"web/templates/parts/header.html",
+		"web/templates/parts/footer.html",
+		"web/templates/pages/legal.html",
+	)
+	if err != nil {
+		log.Fatalf("could not parse legal document template: %v", err)
+	}
+
 	// --- THIS BLOCK IS CORRECTED ---
 	dashboardTpl, err := view.NewTemplate(
 		"web/templates/admin/base.html",
@@ -151,23 +360,118 @@ func main() {
 		log.Fatalf("could not parse dialog view template: %v", err)
 	}
 
+	userQuotaTpl, err := view.NewTemplate(
+		"web/templates/admin/base.html",
+		"web/templates/admin/parts/admin_head.html",
+		"web/templates/admin/parts/admin_header.html",
+		"web/templates/admin/parts/admin_sidebar.html",
+		"web/templates/admin/pages/user_quota.html",
+	)
+	if err != nil {
+		log.Fatalf("could not parse user quota template: %v", err)
+	}
+
+	promptsTpl, err := view.NewTemplate(
+		"web/templates/admin/base.html",
+		"web/templates/admin/parts/admin_head.html",
+		"web/templates/admin/parts/admin_header.html",
+		"web/templates/admin/parts/admin_sidebar.html",
+		"web/templates/admin/pages/prompts.html",
+	)
+	if err != nil {
+		log.Fatalf("could not parse prompts template: %v", err)
+	}
+
+	promptEditTpl, err := view.NewTemplate(
+		"web/templates/admin/base.html",
+		"web/templates/admin/parts/admin_head.html",
+		"web/templates/admin/parts/admin_header.html",
+		"web/templates/admin/parts/admin_sidebar.html",
+		"web/templates/admin/pages/prompt_edit.html",
+	)
+	if err != nil {
+		log.Fatalf("could not parse prompt edit template: %v", err)
+	}
+
+	promptDiffTpl, err := view.NewTemplate(
+		"web/templates/admin/base.html",
+		"web/templates/admin/parts/admin_head.html",
+		"web/templates/admin/parts/admin_header.html",
+		"web/templates/admin/parts/admin_sidebar.html",
+		"web/templates/admin/pages/prompt_diff.html",
+	)
+	if err != nil {
+		log.Fatalf("could not parse prompt diff template: %v", err)
+	}
+
+	moderationTpl, err := view.NewTemplate(
+		"web/templates/admin/base.html",
+		"web/templates/admin/parts/admin_head.html",
+		"web/templates/admin/parts/admin_header.html",
+		"web/templates/admin/parts/admin_sidebar.html",
+		"web/templates/admin/pages/moderation.html",
+	)
+	if err != nil {
+		log.Fatalf("could not parse moderation template: %v", err)
+	}
+
+	moderationItemTpl, err := view.NewTemplate(
+		"web/templates/admin/base.html",
+		"web/templates/admin/parts/admin_head.html",
+		"web/templates/admin/parts/admin_header.html",
+		"web/templates/admin/parts/admin_sidebar.html",
+		"web/templates/admin/pages/moderation_item.html",
+	)
+	if err != nil {
+		log.Fatalf("could not parse moderation item template: %v", err)
+	}
+
 	// --- Handlers ---
-	apiHandlers := handlers.NewAPIHandlers(chatService, userRepo, dialogRepo)
+	apiHandlers := handlers.NewAPIHandlers(chatService, userRepo, dialogRepo, rateLimiter)
 	pageHandlers := &handlers.PageHandlers{
-		WelcomeTemplate: welcomeTpl,
-		ChatTemplate:    chatTpl,
+		WelcomeTemplate:  welcomeTpl,
+		ChatTemplate:     chatTpl,
+		ConsentTemplate:  consentTpl,
+		ConsentsTemplate: consentsTpl,
+		LegalTemplate:    legalTpl,
+		ConsentService:   consentService,
 	}
 	adminHandlers := &handlers.AdminHandlers{
-		DashboardTemplate:  dashboardTpl,
-		UsersTemplate:      usersTpl,
-		DialogsTemplate:    dialogsTpl,
-		DialogViewTemplate: dialogViewTpl,
-		UserRepo:           userRepo,
-		DialogRepo:         dialogRepo,
+		DashboardTemplate:      dashboardTpl,
+		UsersTemplate:          usersTpl,
+		DialogsTemplate:        dialogsTpl,
+		DialogViewTemplate:     dialogViewTpl,
+		UserQuotaTemplate:      userQuotaTpl,
+		PromptsTemplate:        promptsTpl,
+		PromptEditTemplate:     promptEditTpl,
+		PromptDiffTemplate:     promptDiffTpl,
+		ModerationTemplate:     moderationTpl,
+		ModerationItemTemplate: moderationItemTpl,
+		UserRepo:               userRepo,
+		DialogRepo:             dialogRepo,
+		ChatService:            chatService,
+		QuotaService:           quotaService,
+		PromptService:          promptService,
+		PhaseService:           phaseService,
+		CrisisService:          crisisService,
+		ModerationService:      moderationService,
+	}
+
+	// --- Hot Reload ---
+	if os.Getenv("HOT_RELOAD") == "true" {
+		templates := []*view.Template{
+			welcomeTpl, chatTpl, consentTpl, consentsTpl, legalTpl, dashboardTpl, usersTpl, dialogsTpl, dialogViewTpl,
+			userQuotaTpl, promptsTpl, promptEditTpl, promptDiffTpl, moderationTpl, moderationItemTpl,
+		}
+		startHotReload(templates, personas, phases, crisisKeywords, redactor, consentService, rateLimiter, promptService, quotaService)
 	}
 
 	// --- Server ---
-	srv := server.NewServer(apiHandlers, pageHandlers, adminHandlers, userRepo)
+	trustedProxies, err := middleware.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("failed to parse TRUSTED_PROXIES: %v", err)
+	}
+	srv := server.NewServer(apiHandlers, pageHandlers, adminHandlers, userRepo, trustedProxies)
 
 	log.Println("Starting server on :8080")
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -12,6 +12,7 @@ require (
 	github.com/markbates/goth v1.81.0
 	github.com/sashabaranov/go-openai v1.40.5
 	google.golang.org/api v0.246.0
+	google.golang.org/grpc v1.74.2
 )
 
 require (
@@ -46,6 +47,5 @@ require (
 	golang.org/x/time v0.12.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250603155806-513f23925822 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250728155136-f173205681a0 // indirect
-	google.golang.org/grpc v1.74.2 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
 )
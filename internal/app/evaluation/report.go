@@ -0,0 +1,183 @@
+// github.com/DauletBai/oilan.org/internal/app/evaluation/report.go
+package evaluation
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Status is the outcome of checking a reply against a rule.
+type Status string
+
+const (
+	StatusPassed  Status = "passed"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped" // The rule could not be checked, e.g. without a judge
+	StatusError   Status = "error"   // No reply, or the judge did not answer
+)
+
+// Result is the outcome of one rule for the reply to one turn of a case.
+type Result struct {
+	Case      string        `json:"case"`
+	Turn      int           `json:"turn"` // 1-based; 0 if the case could not start
+	Rule      string        `json:"rule"`
+	Status    Status        `json:"status"`
+	Message   string        `json:"message,omitempty"`
+	User      string        `json:"user,omitempty"`
+	Reply     string        `json:"reply,omitempty"`
+	Provider  string        `json:"provider,omitempty"`
+	Model     string        `json:"model,omitempty"`
+	LatencyMs int64         `json:"latency_ms,omitempty"` // Time the reply took
+	Duration  time.Duration `json:"-"`                    // Time the check took, e.g. by the judge
+}
+
+// Report is the outcome of a whole suite.
+type Report struct {
+	Suite     string        `json:"suite"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"-"`
+	Passed    int           `json:"passed"`
+	Failed    int           `json:"failed"`
+	Skipped   int           `json:"skipped"`
+	Errors    int           `json:"errors"`
+	Results   []Result      `json:"results"`
+}
+
+func (r *Report) add(results ...Result) {
+	for _, result := range results {
+		switch result.Status {
+		case StatusPassed:
+			r.Passed++
+		case StatusFailed:
+			r.Failed++
+		case StatusSkipped:
+			r.Skipped++
+		default:
+			r.Errors++
+		}
+		r.Results = append(r.Results, result)
+	}
+}
+
+// OK reports whether no check failed or errored.
+func (r *Report) OK() bool {
+	return r.Failed == 0 && r.Errors == 0
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// JUnit XML, as read by CI servers: one test suite per case and one test case per checked rule.
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the report as JUnit XML.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	out := junitSuites{
+		Name:     r.Suite,
+		Tests:    len(r.Results),
+		Failures: r.Failed,
+		Errors:   r.Errors,
+		Skipped:  r.Skipped,
+		Time:     seconds(r.Duration),
+	}
+
+	index := make(map[string]int)
+	var elapsed []time.Duration
+	lastTurn := make(map[string]int)
+	for _, result := range r.Results {
+		i, ok := index[result.Case]
+		if !ok {
+			i = len(out.Suites)
+			index[result.Case] = i
+			out.Suites = append(out.Suites, junitSuite{Name: result.Case})
+			elapsed = append(elapsed, 0)
+		}
+		suite := &out.Suites[i]
+
+		// A reply is shared by all rules of its turn, so its latency counts once per suite.
+		elapsed[i] += result.Duration
+		if result.Turn != lastTurn[result.Case] {
+			lastTurn[result.Case] = result.Turn
+			elapsed[i] += time.Duration(result.LatencyMs) * time.Millisecond
+		}
+
+		tc := junitCase{
+			ClassName: r.Suite + "." + result.Case,
+			Name:      fmt.Sprintf("turn %d: %s", result.Turn, result.Rule),
+			Time:      seconds(result.Duration),
+		}
+		if result.User != "" {
+			tc.SystemOut = fmt.Sprintf("User: %s\n\nReply: %s\n", result.User, result.Reply)
+		}
+		message := &junitMessage{Message: result.Message}
+		switch result.Status {
+		case StatusFailed:
+			tc.Failure = message
+			suite.Failures++
+		case StatusSkipped:
+			tc.Skipped = message
+			suite.Skipped++
+		case StatusError:
+			tc.Error = message
+			suite.Errors++
+		}
+		suite.Tests++
+		suite.Time = seconds(elapsed[i])
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,100 @@
+// github.com/DauletBai/oilan.org/internal/app/evaluation/rules.go
+package evaluation
+
+import (
+	"context"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"strings"
+	"unicode"
+)
+
+// kazakhLetters are the Cyrillic letters used in Kazakh but not in Russian.
+const kazakhLetters = "әғқңөұүһіӘҒҚҢӨҰҮҺІ"
+
+// detectLanguage guesses whether a text is written in Kazakh, Russian or English from its
+// letters. It returns "" for a text without letters.
+func detectLanguage(text string) string {
+	var cyrillic, latin int
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune(kazakhLetters, r):
+			return "kk"
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+	switch {
+	case cyrillic == 0 && latin == 0:
+		return ""
+	case cyrillic >= latin:
+		return "ru"
+	default:
+		return "en"
+	}
+}
+
+// judgeSystemPrompt instructs the judge model. It must answer with a verdict on the first line.
+const judgeSystemPrompt = `You evaluate replies written by an AI guide in a self-reflection chat.
+You are given the user's message, the AI's reply and a yes/no question about the reply.
+Answer with PASS if the answer to the question is yes and FAIL if it is no, followed by one short sentence explaining why.`
+
+// check applies a rule to the reply to a user message. language is the language the user writes in.
+func (r *Runner) check(ctx context.Context, rule *Rule, language, user, reply string) (Status, string) {
+	switch rule.Type {
+	case RuleRegex:
+		if rule.match != nil && !rule.match.MatchString(reply) {
+			return StatusFailed, fmt.Sprintf("reply does not match %s", rule.Match)
+		}
+		if rule.forbid != nil {
+			if found := rule.forbid.FindString(reply); found != "" {
+				return StatusFailed, fmt.Sprintf("reply contains forbidden %q", found)
+			}
+		}
+		return StatusPassed, ""
+
+	case RuleLanguage:
+		if language == "" {
+			language = detectLanguage(user)
+		}
+		if got := detectLanguage(reply); got != language {
+			return StatusFailed, fmt.Sprintf("user writes in %q, reply is in %q", language, got)
+		}
+		return StatusPassed, ""
+
+	case RuleJudge:
+		if r.Judge == nil {
+			return StatusSkipped, "no judge configured"
+		}
+		return r.judge(ctx, rule, user, reply)
+	}
+	return StatusError, fmt.Sprintf("unknown rule type %q", rule.Type)
+}
+
+// judge asks the judge model a rule's question about a reply.
+func (r *Runner) judge(ctx context.Context, rule *Rule, user, reply string) (Status, string) {
+	zero := 0.0
+	ctx = services.WithGenerationParams(ctx, services.GenerationParams{Temperature: &zero})
+	question := fmt.Sprintf("User message:\n%s\n\nAI reply:\n%s\n\nQuestion: %s", user, reply, rule.Question)
+	history := []domain.Message{{Role: domain.RoleUser, Content: question}}
+
+	resp, err := r.Judge.GenerateResponse(ctx, history, judgeSystemPrompt)
+	if err != nil {
+		return StatusError, fmt.Sprintf("judge failed: %v", err)
+	}
+	verdict := strings.TrimSpace(resp.Content)
+	reason := verdict
+	if i := strings.IndexAny(verdict, " \n:.,"); i >= 0 {
+		verdict, reason = verdict[:i], strings.TrimSpace(strings.TrimLeft(verdict[i:], " \n:.,-"))
+	}
+	switch strings.ToUpper(strings.Trim(verdict, "*")) {
+	case "PASS":
+		return StatusPassed, reason
+	case "FAIL":
+		return StatusFailed, reason
+	}
+	return StatusError, fmt.Sprintf("judge gave no verdict: %q", resp.Content)
+}
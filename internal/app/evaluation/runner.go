@@ -0,0 +1,114 @@
+// github.com/DauletBai/oilan.org/internal/app/evaluation/runner.go
+package evaluation
+
+import (
+	"context"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"time"
+)
+
+// Runner plays the cases of a suite against an LLM client and checks the replies.
+type Runner struct {
+	Client   services.LLMClient
+	Judge    services.LLMClient // Answers judge rules; they are skipped when nil
+	Personas *services.PersonaRegistry
+	Persona  string           // Persona every case talks to, overriding the one of the case
+	Prompt   string           // System prompt used instead of the persona's, e.g. a draft
+	Timeout  time.Duration    // Limit for a single reply; none if zero
+	Now      func() time.Time // Time prompts are rendered for; the current time if nil
+}
+
+// Run plays every case of the suite and returns the report.
+func (r *Runner) Run(ctx context.Context, suite *Suite) *Report {
+	report := &Report{Suite: suite.Name, StartedAt: time.Now()}
+	for _, c := range suite.Cases {
+		report.add(r.runCase(ctx, suite, c)...)
+	}
+	report.Duration = time.Since(report.StartedAt)
+	return report
+}
+
+// runCase plays the turns of a case one after another, as a single dialog.
+func (r *Runner) runCase(ctx context.Context, suite *Suite, c *Case) []Result {
+	// 1. Build the system prompt the persona would get in a new dialog.
+	personaID := c.Persona
+	if r.Persona != "" {
+		personaID = r.Persona
+	}
+	persona := r.Personas.Default()
+	if personaID != "" {
+		var ok bool
+		if persona, ok = r.Personas.Get(personaID); !ok {
+			return []Result{caseError(c, fmt.Sprintf("unknown persona %q", personaID))}
+		}
+	}
+	prompt := persona.SystemPrompt
+	if r.Prompt != "" {
+		prompt = r.Prompt
+	}
+	now := time.Now()
+	if r.Now != nil {
+		now = r.Now()
+	}
+	systemPrompt, err := services.RenderPrompt(prompt, services.NewPromptVars(c.Language, "UTC", now, now))
+	if err != nil {
+		return []Result{caseError(c, err.Error())}
+	}
+	ctx = services.WithGenerationParams(ctx, services.GenerationParams{
+		Models:      persona.Models,
+		Temperature: persona.Temperature,
+		MaxTokens:   persona.MaxTokens,
+	})
+
+	// 2. Send the turns and check every reply.
+	var results []Result
+	var history []domain.Message
+	for i, turn := range c.Turns {
+		history = append(history, domain.Message{Role: domain.RoleUser, Content: turn.User, CreatedAt: time.Now()})
+		resp, err := r.generate(ctx, history, systemPrompt)
+		if err != nil {
+			// The rest of the dialog depends on this reply, so the case ends here.
+			results = append(results, Result{
+				Case: c.Name, Turn: i + 1, Rule: "reply", Status: StatusError,
+				Message: fmt.Sprintf("generation failed: %v", err), User: turn.User,
+			})
+			return results
+		}
+		history = append(history, domain.Message{Role: domain.RoleAI, Content: resp.Content, CreatedAt: time.Now()})
+
+		for _, rule := range suite.expectations(c, turn) {
+			start := time.Now()
+			status, message := r.check(ctx, rule, c.Language, turn.User, resp.Content)
+			results = append(results, Result{
+				Case:      c.Name,
+				Turn:      i + 1,
+				Rule:      rule.ID,
+				Status:    status,
+				Message:   message,
+				User:      turn.User,
+				Reply:     resp.Content,
+				Provider:  resp.Provider,
+				Model:     resp.Model,
+				LatencyMs: resp.Latency.Milliseconds(),
+				Duration:  time.Since(start),
+			})
+		}
+	}
+	return results
+}
+
+func (r *Runner) generate(ctx context.Context, history []domain.Message, systemPrompt string) (*services.LLMResponse, error) {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	return r.Client.GenerateResponse(ctx, history, systemPrompt)
+}
+
+// caseError is the result of a case that could not be played at all.
+func caseError(c *Case, message string) Result {
+	return Result{Case: c.Name, Rule: "setup", Status: StatusError, Message: message}
+}
@@ -0,0 +1,151 @@
+// github.com/DauletBai/oilan.org/internal/app/evaluation/suite.go
+package evaluation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Rule types.
+const (
+	RuleRegex    = "regex"    // The reply must match Match and must not match Forbid
+	RuleLanguage = "language" // The reply is written in the language of the user
+	RuleJudge    = "judge"    // An LLM judge answers Question about the reply
+)
+
+// Suite is a set of scripted conversations and the rules their replies are checked against.
+type Suite struct {
+	Name  string  `json:"name"`
+	Rules []*Rule `json:"rules"`
+	Cases []*Case `json:"cases"`
+
+	rules map[string]*Rule
+}
+
+// Rule is a check applied to a single AI reply.
+type Rule struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type"`
+
+	Match    string `json:"match,omitempty"`    // regex: a pattern the reply must contain
+	Forbid   string `json:"forbid,omitempty"`   // regex: a pattern the reply must not contain
+	Question string `json:"question,omitempty"` // judge: a yes/no question about the reply; yes passes
+
+	match  *regexp.Regexp
+	forbid *regexp.Regexp
+}
+
+// Case is a scripted conversation. Its user turns are sent one after another, and every reply
+// is checked against the rules in Expect of the case and of the turn.
+type Case struct {
+	Name     string   `json:"name"`
+	Persona  string   `json:"persona,omitempty"`  // Persona to talk to; the default one if empty
+	Language string   `json:"language,omitempty"` // Language the user writes in, e.g. "kk"; detected if empty
+	Expect   []string `json:"expect,omitempty"`
+	Turns    []Turn   `json:"turns"`
+}
+
+// Turn is a single user message of a case.
+type Turn struct {
+	User   string   `json:"user"`
+	Expect []string `json:"expect,omitempty"`
+}
+
+// LoadSuite reads a suite and checks that its rules are valid and every expected rule exists.
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suite: %w", err)
+	}
+	var suite Suite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse suite: %w", err)
+	}
+	if len(suite.Cases) == 0 {
+		return nil, errors.New("suite has no cases")
+	}
+
+	suite.rules = make(map[string]*Rule, len(suite.Rules))
+	for _, rule := range suite.Rules {
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+		if _, exists := suite.rules[rule.ID]; exists {
+			return nil, fmt.Errorf("duplicate rule %q", rule.ID)
+		}
+		suite.rules[rule.ID] = rule
+	}
+
+	names := make(map[string]bool, len(suite.Cases))
+	for _, c := range suite.Cases {
+		if c.Name == "" {
+			return nil, errors.New("every case needs a name")
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("duplicate case %q", c.Name)
+		}
+		names[c.Name] = true
+		if len(c.Turns) == 0 {
+			return nil, fmt.Errorf("case %q has no turns", c.Name)
+		}
+		expected := append([]string{}, c.Expect...)
+		for _, turn := range c.Turns {
+			expected = append(expected, turn.Expect...)
+		}
+		for _, id := range expected {
+			if _, ok := suite.rules[id]; !ok {
+				return nil, fmt.Errorf("case %q expects unknown rule %q", c.Name, id)
+			}
+		}
+	}
+	return &suite, nil
+}
+
+func (r *Rule) compile() error {
+	if r.ID == "" {
+		return errors.New("every rule needs an id")
+	}
+	var err error
+	switch r.Type {
+	case RuleRegex:
+		if r.Match == "" && r.Forbid == "" {
+			return fmt.Errorf("rule %q: a regex rule needs match or forbid", r.ID)
+		}
+		if r.Match != "" {
+			if r.match, err = regexp.Compile(r.Match); err != nil {
+				return fmt.Errorf("rule %q: invalid match: %w", r.ID, err)
+			}
+		}
+		if r.Forbid != "" {
+			if r.forbid, err = regexp.Compile(r.Forbid); err != nil {
+				return fmt.Errorf("rule %q: invalid forbid: %w", r.ID, err)
+			}
+		}
+	case RuleLanguage:
+	case RuleJudge:
+		if r.Question == "" {
+			return fmt.Errorf("rule %q: a judge rule needs a question", r.ID)
+		}
+	default:
+		return fmt.Errorf("rule %q: unknown type %q", r.ID, r.Type)
+	}
+	return nil
+}
+
+// expectations returns the rules a reply to the given turn of a case is checked against,
+// each once, those of the case first.
+func (s *Suite) expectations(c *Case, turn Turn) []*Rule {
+	seen := make(map[string]bool)
+	var rules []*Rule
+	for _, id := range append(append([]string{}, c.Expect...), turn.Expect...) {
+		if !seen[id] {
+			seen[id] = true
+			rules = append(rules, s.rules[id])
+		}
+	}
+	return rules
+}
@@ -7,46 +7,105 @@ import (
 	"fmt"
 	"github.com/DauletBai/oilan.org/internal/domain"
 	"github.com/DauletBai/oilan.org/internal/domain/repository"
-	"os"
+	"log"
 	"time"
 )
 
-// LLMClient defines the interface for an external Large Language Model.
-type LLMClient interface {
-	GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (string, error)
+var (
+	// ErrDialogNotFound is returned when the requested dialog does not exist.
+	ErrDialogNotFound = errors.New("dialog not found")
+	// ErrDialogAccessDenied is returned when a user tries to access a dialog they do not own.
+	ErrDialogAccessDenied = errors.New("user does not own this dialog")
+)
+
+// MessageHooks lets callers observe the stages of PostMessageStream.
+type MessageHooks struct {
+	// OnUserMessage is called once the user's message has been persisted.
+	OnUserMessage func(msg *domain.Message) error
+	// OnChunk receives partial chunks of the AI response. When nil, the response is generated in one request.
+	OnChunk StreamHandler
+	// OnPhaseChange is called when the AI's reply moved the dialog to another phase.
+	OnPhaseChange func(transition *domain.PhaseTransition)
 }
 
 // ChatService provides methods for chat-related operations.
 type ChatService struct {
 	dialogRepo repository.DialogRepository
 	llmClient  LLMClient
-	systemPrompt string
+	history    *HistoryBuilder
+	quotas     *QuotaService
+	personas   *PersonaRegistry
+	prompts    *PromptService
+	phases     *PhaseService
+	crisis     *CrisisService
+	moderation *ModerationService
+	redactor   *Redactor
+	locks      *DialogLocks
+	now        func() time.Time // Time system prompts are rendered for
 }
 
 // NewChatService creates a new ChatService.
-func NewChatService(dialogRepo repository.DialogRepository, llmClient LLMClient) (*ChatService, error) {
-	// Read the system prompt from the file system upon initialization.
-	promptBytes, err := os.ReadFile("configs/prompt_therapist.txt")
-	if err != nil {
-		return nil, fmt.Errorf("failed to read system prompt: %w", err)
+func NewChatService(dialogRepo repository.DialogRepository, summaryRepo repository.SummaryRepository, llmClient LLMClient, budget ContextBudget, quotas *QuotaService, personas *PersonaRegistry, prompts *PromptService, phases *PhaseService, crisis *CrisisService, moderation *ModerationService, redactor *Redactor, locks *DialogLocks) (*ChatService, error) {
+	if personas == nil {
+		return nil, errors.New("chat service needs at least one persona")
 	}
 
 	return &ChatService{
 		dialogRepo: dialogRepo,
 		llmClient:  llmClient,
-		systemPrompt: string(promptBytes),
+		history:    NewHistoryBuilder(budget, summaryRepo, llmClient, quotas),
+		quotas:     quotas,
+		personas:   personas,
+		prompts:    prompts,
+		phases:     phases,
+		crisis:     crisis,
+		moderation: moderation,
+		redactor:   redactor,
+		locks:      locks,
+		now:        time.Now,
 	}, nil
 }
 
-// StartNewDialog creates a new dialog for a user.
-func (s *ChatService) StartNewDialog(ctx context.Context, userID int64, title string) (*domain.Dialog, error) {
+// SetPromptClock sets the clock system prompts are rendered with, e.g. a fixed one while LLM
+// exchanges are recorded or replayed. A nil clock restores the current time.
+func (s *ChatService) SetPromptClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	s.now = now
+}
+
+// Personas returns the personas a dialog can be started with.
+func (s *ChatService) Personas() []*domain.Persona {
+	return s.personas.List()
+}
+
+// DefaultPersona returns the persona used when a dialog does not choose one.
+func (s *ChatService) DefaultPersona() *domain.Persona {
+	return s.personas.Default()
+}
+
+// StartNewDialog creates a new dialog for a user with the given persona, or the default one when personaID is empty.
+func (s *ChatService) StartNewDialog(ctx context.Context, userID int64, title string, personaID string) (*domain.Dialog, error) {
 	if title == "" {
 		title = "New Chat"
 	}
 
+	persona := s.personas.Default()
+	if personaID != "" {
+		var ok bool
+		if persona, ok = s.personas.Get(personaID); !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownPersona, personaID)
+		}
+	}
+
 	dialog := &domain.Dialog{
-		UserID: userID,
-		Title:  title,
+		UserID:    userID,
+		Title:     title,
+		PersonaID: persona.ID,
+	}
+	if s.phases != nil {
+		dialog.Phase = s.phases.InitialPhase(persona)
 	}
 
 	err := s.dialogRepo.Save(ctx, dialog)
@@ -57,52 +116,367 @@ func (s *ChatService) StartNewDialog(ctx context.Context, userID int64, title st
 	return dialog, nil
 }
 
-// PostMessage handles posting a new message to a dialog and getting a response from the AI.
-func (s *ChatService) PostMessage(ctx context.Context, dialogID int64, userID int64, content string) (*domain.Message, error) {
-	// 1. Verify that the user owns the dialog (security check).
+// GetDialog loads a dialog with its messages and verifies that it belongs to the user.
+func (s *ChatService) GetDialog(ctx context.Context, dialogID int64, userID int64) (*domain.Dialog, error) {
 	dialog, err := s.dialogRepo.FindByID(ctx, dialogID)
 	if err != nil {
 		return nil, fmt.Errorf("could not find dialog: %w", err)
 	}
 	if dialog == nil {
-		return nil, errors.New("dialog not found")
+		return nil, ErrDialogNotFound
 	}
 	if dialog.UserID != userID {
-		return nil, errors.New("user does not own this dialog") // Security error
+		return nil, ErrDialogAccessDenied // Security error
 	}
+	return dialog, nil
+}
 
-	// 2. Save the user's message to the database.
-	userMessage := &domain.Message{
-		DialogID: dialogID,
-		Role:     domain.RoleUser,
-		Content:  content,
-		CreatedAt: time.Now(),
+// PostMessage handles posting a new message to a dialog and getting a response from the AI.
+func (s *ChatService) PostMessage(ctx context.Context, dialogID int64, userID int64, content string) (*domain.Message, error) {
+	return s.PostMessageStream(ctx, dialogID, userID, content, MessageHooks{})
+}
+
+// PostMessageStream works like PostMessage, but reports its progress through hooks: the persisted user
+// message first, then partial chunks of the AI response while they are generated.
+// The complete response is persisted only once the stream has finished.
+// Messages posted to the same dialog at the same time are answered one after the other, in the
+// order they arrived, so that every reply sees the turns before it. Only a message that shows
+// signs of a crisis is answered at once.
+func (s *ChatService) PostMessageStream(ctx context.Context, dialogID int64, userID int64, content string, hooks MessageHooks) (*domain.Message, error) {
+	// 1. Load the dialog and verify that the user owns it (security check).
+	dialog, err := s.GetDialog(ctx, dialogID, userID)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.dialogRepo.AddMessage(ctx, userMessage); err != nil {
-		return nil, fmt.Errorf("could not save user message: %w", err)
+
+	// 2. Check the message for signs of a crisis. The response to a crisis depends neither on
+	// the user's quotas nor on the LLM, so it is sent even when both are exhausted. Nor does it
+	// wait for the dialog lock: a crisis must be answered and escalated even if the replies to
+	// earlier messages keep the dialog busy.
+	if s.crisis != nil {
+		if signal := s.crisis.Detect(ctx, content); signal != nil {
+			return s.respondToCrisis(ctx, dialog, content, signal, hooks)
+		}
 	}
 
-	// 3. Get the full, updated conversation history.
+	// 3. Wait until the messages posted before are answered, and load the dialog again with the
+	// turns those messages added. Only the owner gets this far, so that nobody can hold up a
+	// dialog that is not theirs.
+	if s.locks != nil {
+		unlock, err := s.locks.Lock(ctx, dialogID)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+		if dialog, err = s.GetDialog(ctx, dialogID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 4. Check the user's quotas before anything is stored or sent to the LLM.
+	if s.quotas != nil {
+		release, err := s.quotas.Acquire(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	// 5. Save the user's message to the database.
+	userMessage, err := s.saveUserMessage(ctx, dialogID, content, hooks)
+	if err != nil {
+		return nil, err
+	}
+
+	// 6. Get the full, updated conversation history.
 	// We add the new user message to the history we already loaded.
 	dialog.Messages = append(dialog.Messages, *userMessage)
-	
-	// 4. Send the history and the system prompt to the LLM to get a response.
-	aiContent, err := s.llmClient.GenerateResponse(ctx, dialog.Messages, s.systemPrompt)
+
+	// 7. Fit the history into the model's context window, summarizing older turns if needed.
+	// The dialog's persona provides the system prompt and the generation parameters.
+	persona := s.personas.Resolve(dialog.PersonaID)
+	prompt, systemPrompt, err := s.systemPrompt(ctx, dialog, persona)
+	if err != nil {
+		return nil, err
+	}
+	ctx = WithGenerationParams(ctx, personaParams(persona))
+	window, err := s.history.Build(ctx, dialog, systemPrompt, true)
 	if err != nil {
+		return nil, err
+	}
+
+	// 8. Send the history and the system prompt to the LLM to get a response.
+	// In a guided session the reply may end with a phase marker, which the user never sees.
+	guided := s.hasPhases(dialog)
+	var aiResponse *LLMResponse
+	if hooks.OnChunk != nil && guided {
+		filter := &phaseMarkerFilter{next: hooks.OnChunk}
+		aiResponse, err = s.llmClient.StreamResponse(ctx, window.Messages, window.SystemPrompt, filter.write)
+		if err == nil {
+			err = filter.flush()
+		}
+	} else if hooks.OnChunk != nil {
+		aiResponse, err = s.llmClient.StreamResponse(ctx, window.Messages, window.SystemPrompt, hooks.OnChunk)
+	} else {
+		aiResponse, err = s.llmClient.GenerateResponse(ctx, window.Messages, window.SystemPrompt)
+	}
+	if err != nil {
+		if errors.Is(err, ErrResponseBlocked) {
+			s.flag(ctx, Flag{
+				Dialog:   dialog,
+				Message:  userMessage,
+				Source:   domain.ModerationSourceSafetyBlock,
+				Severity: domain.SeverityMedium,
+				Reason:   "The provider's safety filters blocked the reply to this message",
+			})
+		}
 		return nil, fmt.Errorf("llm client failed to generate response: %w", err)
 	}
 
-	// 5. Save the AI's message to the database.
+	// 9. Save the AI's message to the database.
+	replyContent, nextPhase := aiResponse.Content, ""
+	if guided {
+		replyContent, nextPhase = extractPhaseMarker(replyContent)
+	}
 	aiMessage := &domain.Message{
-		DialogID: dialogID,
-		Role:     domain.RoleAI,
-		Content:  aiContent,
-		CreatedAt: time.Now(),
+		DialogID:         dialogID,
+		Role:             domain.RoleAI,
+		Content:          replyContent,
+		CreatedAt:        time.Now(),
+		Provider:         aiResponse.Provider,
+		Model:            aiResponse.Model,
+		PromptTokens:     aiResponse.PromptTokens,
+		CompletionTokens: aiResponse.CompletionTokens,
+		LatencyMs:        aiResponse.Latency.Milliseconds(),
+		FinishReason:     aiResponse.FinishReason,
+		Redactions:       s.redactions(replyContent),
+	}
+	if prompt.ID != 0 {
+		aiMessage.PromptVersionID = &prompt.ID
 	}
 	if err := s.dialogRepo.AddMessage(ctx, aiMessage); err != nil {
 		return nil, fmt.Errorf("could not save ai message: %w", err)
 	}
 
-	// 6. Return the AI's message to the user.
+	// 10. Move on to the phase the AI asked for. The reply is already stored, so a transition the
+	// rules do not allow is only logged.
+	if nextPhase != "" {
+		transition, err := s.phases.Advance(ctx, dialog, nextPhase, domain.PhaseTriggerLLM, &aiMessage.ID)
+		if err != nil {
+			log.Printf("Ignoring phase marker %q in dialog %d: %v", nextPhase, dialogID, err)
+		} else if hooks.OnPhaseChange != nil {
+			hooks.OnPhaseChange(transition)
+		}
+	}
+
+	// 11. Return the AI's message to the user.
 	return aiMessage, nil
-}
\ No newline at end of file
+}
+
+// saveUserMessage stores a message the user wrote and reports it to the hooks.
+func (s *ChatService) saveUserMessage(ctx context.Context, dialogID int64, content string, hooks MessageHooks) (*domain.Message, error) {
+	userMessage := &domain.Message{
+		DialogID:   dialogID,
+		Role:       domain.RoleUser,
+		Content:    content,
+		CreatedAt:  time.Now(),
+		Redactions: s.redactions(content),
+	}
+	if err := s.dialogRepo.AddMessage(ctx, userMessage); err != nil {
+		return nil, fmt.Errorf("could not save user message: %w", err)
+	}
+	if hooks.OnUserMessage != nil {
+		if err := hooks.OnUserMessage(userMessage); err != nil {
+			return nil, err
+		}
+	}
+	return userMessage, nil
+}
+
+// redactions counts the personal data in a message that is hidden from the LLM provider.
+func (s *ChatService) redactions(content string) int {
+	if s.redactor == nil {
+		return 0
+	}
+	return s.redactor.Count(content)
+}
+
+// respondToCrisis answers a message in which a crisis was detected with the fixed crisis-resources
+// response instead of an AI reply, and escalates the crisis.
+func (s *ChatService) respondToCrisis(ctx context.Context, dialog *domain.Dialog, content string, signal *CrisisSignal, hooks MessageHooks) (*domain.Message, error) {
+	// 1. Save the user's message.
+	userMessage, err := s.saveUserMessage(ctx, dialog.ID, content, hooks)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Save the response. It is marked with the classifier instead of an LLM provider.
+	response, language := s.crisis.Response(ctx, signal)
+	crisisMessage := &domain.Message{
+		DialogID:     dialog.ID,
+		Role:         domain.RoleAI,
+		Content:      response,
+		CreatedAt:    time.Now(),
+		Provider:     CrisisProvider,
+		Model:        signal.Classifier,
+		FinishReason: FinishReasonStop,
+	}
+	if err := s.dialogRepo.AddMessage(ctx, crisisMessage); err != nil {
+		return nil, fmt.Errorf("could not save crisis response: %w", err)
+	}
+
+	// 3. Flag the dialog, notify the admins on call and put the message in the review queue.
+	// The user gets the response either way.
+	if err := s.crisis.Escalate(ctx, dialog, userMessage, signal, language); err != nil {
+		log.Printf("Could not record the crisis in dialog %d: %v", dialog.ID, err)
+	}
+	s.flag(ctx, Flag{
+		Dialog:   dialog,
+		Message:  userMessage,
+		Source:   domain.ModerationSourceCrisis,
+		Severity: domain.SeverityCritical,
+		Reason:   fmt.Sprintf("Crisis detected by %s (%s)", signal.Classifier, signal.Evidence),
+	})
+	return crisisMessage, nil
+}
+
+// flag puts a dialog in the review queue. Failures are only logged, since the flag is a side
+// effect of a message that must not be lost because of it.
+func (s *ChatService) flag(ctx context.Context, flag Flag) {
+	if s.moderation == nil {
+		return
+	}
+	if _, err := s.moderation.Flag(ctx, flag); err != nil {
+		log.Printf("Could not flag dialog %d for review: %v", flag.Dialog.ID, err)
+	}
+}
+
+// ReportMessage puts a message of a user's dialog in the review queue at the user's request.
+func (s *ChatService) ReportMessage(ctx context.Context, dialogID int64, userID int64, messageID int64, reason string) error {
+	dialog, message, err := s.dialogMessage(ctx, dialogID, userID, messageID)
+	if err != nil {
+		return err
+	}
+	_, err = s.moderation.Report(ctx, dialog, message, reason)
+	return err
+}
+
+// RateMessage stores the score a user gave an AI message of their dialog.
+func (s *ChatService) RateMessage(ctx context.Context, dialogID int64, userID int64, messageID int64, score int, comment string) error {
+	dialog, message, err := s.dialogMessage(ctx, dialogID, userID, messageID)
+	if err != nil {
+		return err
+	}
+	if message.Role != domain.RoleAI {
+		return ErrMessageNotFound
+	}
+	return s.moderation.Rate(ctx, dialog, message, score, comment)
+}
+
+// dialogMessage loads a user's dialog and one of its messages.
+func (s *ChatService) dialogMessage(ctx context.Context, dialogID int64, userID int64, messageID int64) (*domain.Dialog, *domain.Message, error) {
+	dialog, err := s.GetDialog(ctx, dialogID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range dialog.Messages {
+		if dialog.Messages[i].ID == messageID {
+			return dialog, &dialog.Messages[i], nil
+		}
+	}
+	return nil, nil, ErrMessageNotFound
+}
+
+// PreviewContext returns the context window that the next turn of a dialog would send to the model.
+// It is meant for admins and does not check ownership or update the stored summary.
+func (s *ChatService) PreviewContext(ctx context.Context, dialogID int64) (*ContextWindow, error) {
+	dialog, err := s.dialogRepo.FindByID(ctx, dialogID)
+	if err != nil {
+		return nil, fmt.Errorf("could not find dialog: %w", err)
+	}
+	if dialog == nil {
+		return nil, ErrDialogNotFound
+	}
+	_, systemPrompt, err := s.systemPrompt(ctx, dialog, s.personas.Resolve(dialog.PersonaID))
+	if err != nil {
+		return nil, err
+	}
+	window, err := s.history.Build(ctx, dialog, systemPrompt, false)
+	if err != nil || s.redactor == nil {
+		return window, err
+	}
+
+	// Show the window the way the provider receives it.
+	session := s.redactor.NewSession()
+	window.Messages, window.SystemPrompt = session.RedactRequest(window.Messages, window.SystemPrompt)
+	window.Summary = session.Redact(window.Summary)
+	return window, nil
+}
+
+// systemPrompt returns the prompt version the persona currently uses together with its text
+// rendered for the dialog, followed by the guidance of the dialog's phase. Without versioned
+// prompts the persona's prompt file is rendered with the variables that need no database.
+func (s *ChatService) systemPrompt(ctx context.Context, dialog *domain.Dialog, persona *domain.Persona) (*domain.PromptVersion, string, error) {
+	var version *domain.PromptVersion
+	var rendered string
+	var err error
+	if s.prompts == nil {
+		version = &domain.PromptVersion{PersonaID: persona.ID, Content: persona.SystemPrompt}
+		rendered, err = RenderPrompt(version.Content, NewPromptVars("", "", s.now(), dialog.CreatedAt))
+		if err != nil {
+			return nil, "", err
+		}
+	} else {
+		version, err = s.prompts.ActivePrompt(ctx, persona)
+		if err != nil {
+			return nil, "", err
+		}
+		rendered = s.prompts.Render(ctx, version, dialog, s.now())
+	}
+
+	if s.phases != nil {
+		guidance, err := s.phases.Guidance(ctx, dialog)
+		if err != nil {
+			return nil, "", err
+		}
+		if guidance != "" {
+			rendered += "\n\n" + guidance
+		}
+	}
+	return version, rendered, nil
+}
+
+// hasPhases reports whether a dialog is a guided session.
+func (s *ChatService) hasPhases(dialog *domain.Dialog) bool {
+	if s.phases == nil {
+		return false
+	}
+	flow, _ := s.phases.Current(dialog)
+	return flow != nil
+}
+
+// DialogPhase returns the flow of a user's dialog and the phase it is in; both are nil when the
+// dialog has no phases.
+func (s *ChatService) DialogPhase(ctx context.Context, dialogID int64, userID int64) (*domain.Flow, *domain.Phase, error) {
+	dialog, err := s.GetDialog(ctx, dialogID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.phases == nil {
+		return nil, nil, nil
+	}
+	flow, phase := s.phases.Current(dialog)
+	return flow, phase, nil
+}
+
+// ChangePhase moves a user's dialog to another phase at the user's request.
+func (s *ChatService) ChangePhase(ctx context.Context, dialogID int64, userID int64, to string) (*domain.PhaseTransition, error) {
+	dialog, err := s.GetDialog(ctx, dialogID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if s.phases == nil {
+		return nil, ErrNoPhases
+	}
+	return s.phases.Advance(ctx, dialog, to, domain.PhaseTriggerUser, nil)
+}
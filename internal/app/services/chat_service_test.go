@@ -0,0 +1,192 @@
+// github.com/DauletBai/oilan.org/internal/app/services/chat_service_test.go
+package services
+
+import (
+	"context"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryDialogRepo is an in-memory DialogRepository.
+type memoryDialogRepo struct {
+	mu      sync.Mutex
+	dialogs map[int64]*domain.Dialog
+	nextID  int64
+}
+
+func newMemoryDialogRepo() *memoryDialogRepo {
+	return &memoryDialogRepo{dialogs: make(map[int64]*domain.Dialog)}
+}
+
+func (r *memoryDialogRepo) Save(ctx context.Context, dialog *domain.Dialog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if dialog.ID == 0 {
+		r.nextID++
+		dialog.ID = r.nextID
+	}
+	stored := *dialog
+	stored.Messages = append([]domain.Message(nil), dialog.Messages...)
+	r.dialogs[dialog.ID] = &stored
+	return nil
+}
+
+func (r *memoryDialogRepo) FindByID(ctx context.Context, id int64) (*domain.Dialog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.dialogs[id]
+	if !ok {
+		return nil, nil
+	}
+	dialog := *stored
+	dialog.Messages = append([]domain.Message(nil), stored.Messages...)
+	return &dialog, nil
+}
+
+func (r *memoryDialogRepo) FindAllByUserID(ctx context.Context, userID int64) ([]*domain.Dialog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var dialogs []*domain.Dialog
+	for _, dialog := range r.dialogs {
+		if dialog.UserID == userID {
+			dialogs = append(dialogs, dialog)
+		}
+	}
+	return dialogs, nil
+}
+
+func (r *memoryDialogRepo) GetAll(ctx context.Context) ([]*domain.Dialog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var dialogs []*domain.Dialog
+	for _, dialog := range r.dialogs {
+		dialogs = append(dialogs, dialog)
+	}
+	return dialogs, nil
+}
+
+func (r *memoryDialogRepo) AddMessage(ctx context.Context, message *domain.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dialog := r.dialogs[message.DialogID]
+	r.nextID++
+	message.ID = r.nextID
+	message.Seq = int64(len(dialog.Messages) + 1)
+	dialog.Messages = append(dialog.Messages, *message)
+	return nil
+}
+
+// memoryCrisisRepo is an in-memory CrisisRepository.
+type memoryCrisisRepo struct {
+	mu     sync.Mutex
+	events []*domain.CrisisEvent
+}
+
+func (r *memoryCrisisRepo) Record(ctx context.Context, event *domain.CrisisEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *memoryCrisisRepo) ListByDialog(ctx context.Context, dialogID int64) ([]*domain.CrisisEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []*domain.CrisisEvent
+	for _, event := range r.events {
+		if event.DialogID == dialogID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// notifierFunc turns a function into a CrisisNotifier.
+type notifierFunc func(ctx context.Context, event *domain.CrisisEvent) error
+
+func (f notifierFunc) NotifyCrisis(ctx context.Context, event *domain.CrisisEvent) error {
+	return f(ctx, event)
+}
+
+// newTestPersonas writes a single persona with its prompt to a temporary directory and loads it.
+func newTestPersonas(t *testing.T) *PersonaRegistry {
+	t.Helper()
+	dir := t.TempDir()
+	prompt := filepath.Join(dir, "prompt.txt")
+	if err := os.WriteFile(prompt, []byte("You are a calm listener."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"personas": [{"id": "listener", "name": "Oilan", "prompt_file": "` + filepath.ToSlash(prompt) + `"}]}`
+	path := filepath.Join(dir, "personas.json")
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	personas, err := LoadPersonas(path)
+	if err != nil {
+		t.Fatalf("LoadPersonas: %v", err)
+	}
+	return personas
+}
+
+func TestCrisisIsAnsweredWhileTheDialogIsLocked(t *testing.T) {
+	const userID = 7
+	notified := make(chan *domain.CrisisEvent, 1)
+	notifier := notifierFunc(func(ctx context.Context, event *domain.CrisisEvent) error {
+		notified <- event
+		return nil
+	})
+	crisisRepo := &memoryCrisisRepo{}
+	crisis := NewCrisisService(crisisRepo, notifier, loadTestCrisisKeywords(t))
+
+	repo := newMemoryDialogRepo()
+	locks := NewDialogLocks(nil)
+	llm := &streamingStub{chunks: []string{"An answer that must not be sent."}}
+	chat, err := NewChatService(repo, nil, llm, DefaultContextBudget(), nil, newTestPersonas(t), nil, nil, crisis, nil, nil, locks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialog, err := chat.StartNewDialog(context.Background(), userID, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An earlier message is still being answered.
+	unlock, err := locks.Lock(context.Background(), dialog.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := chat.PostMessage(ctx, dialog.ID, userID, "I want to die")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	if reply.Provider != CrisisProvider {
+		t.Errorf("reply provider = %q, want %q", reply.Provider, CrisisProvider)
+	}
+	if llm.history != nil {
+		t.Error("the LLM was asked while the crisis response was due")
+	}
+
+	saved, _ := repo.FindByID(context.Background(), dialog.ID)
+	if len(saved.Messages) != 2 || saved.Messages[0].Content != "I want to die" || saved.Messages[1].ID != reply.ID {
+		t.Errorf("saved messages = %+v, want the user message and the crisis response", saved.Messages)
+	}
+	if events, _ := crisisRepo.ListByDialog(context.Background(), dialog.ID); len(events) != 1 {
+		t.Errorf("recorded %d crisis events, want 1", len(events))
+	}
+	select {
+	case event := <-notified:
+		if event.DialogID != dialog.ID || event.Classifier != "keywords" {
+			t.Errorf("notified about %+v, want a keyword crisis in dialog %d", event, dialog.ID)
+		}
+	case <-ctx.Done():
+		t.Error("the admins were not notified")
+	}
+}
@@ -0,0 +1,235 @@
+// github.com/DauletBai/oilan.org/internal/app/services/consent_service.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"maps"
+	"os"
+	"regexp"
+	"sync"
+)
+
+var (
+	// ErrUnknownDocument is returned for a document kind or version that is not published.
+	ErrUnknownDocument = errors.New("unknown legal document")
+	// ErrConsentRequired is returned when a user tries to withdraw the consent to a required document.
+	ErrConsentRequired = errors.New("consent to this document is required")
+)
+
+var documentKindPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ConsentStatus is where a user stands with the current version of a document.
+type ConsentStatus struct {
+	Document *domain.LegalDocument
+	Consent  *domain.Consent // The latest ledger entry of the document's kind, if any
+}
+
+// Accepted reports whether the user's consent to some version of the document is in force.
+func (s ConsentStatus) Accepted() bool {
+	return s.Consent != nil && s.Consent.Action == domain.ConsentAccepted
+}
+
+// Current reports whether the user accepted the current version of the document.
+func (s ConsentStatus) Current() bool {
+	return s.Accepted() && s.Consent.DocumentID == s.Document.ID
+}
+
+// ConsentService publishes the versioned legal documents and keeps the ledger of which version
+// every user accepted or withdrew, and when.
+type ConsentService struct {
+	repo repository.ConsentRepository
+	path string
+
+	mu      sync.RWMutex
+	current []*domain.LegalDocument // The current version of every kind, in the order of the file
+}
+
+// NewConsentService creates a new ConsentService for the documents in the given file. Nothing is
+// published until Publish is called.
+func NewConsentService(repo repository.ConsentRepository, path string) *ConsentService {
+	return &ConsentService{repo: repo, path: path}
+}
+
+// Publish reads the documents file and stores every version that is not published yet. The file
+// lists the current version of every kind; older versions stay in the database for the ledger.
+// Published versions are immutable, so changing the text of one without raising its version is
+// an error, and the documents published before stay current.
+func (s *ConsentService) Publish(ctx context.Context) error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read legal documents: %w", err)
+	}
+	var file struct {
+		Documents []*domain.LegalDocument `json:"documents"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse legal documents: %w", err)
+	}
+
+	kinds := make(map[string]bool)
+	for _, doc := range file.Documents {
+		if !documentKindPattern.MatchString(doc.Kind) {
+			return fmt.Errorf("invalid legal document kind %q", doc.Kind)
+		}
+		if kinds[doc.Kind] {
+			return fmt.Errorf("legal document %q is listed twice; list only its current version", doc.Kind)
+		}
+		kinds[doc.Kind] = true
+		if doc.Version < 1 {
+			return fmt.Errorf("legal document %q needs a version of 1 or higher", doc.Kind)
+		}
+		if len(doc.Title) == 0 || len(doc.Body) == 0 {
+			return fmt.Errorf("legal document %q needs a title and a body", doc.Kind)
+		}
+	}
+
+	current := make([]*domain.LegalDocument, 0, len(file.Documents))
+	for _, doc := range file.Documents {
+		stored, err := s.repo.SaveDocument(ctx, doc)
+		if err != nil {
+			return fmt.Errorf("could not publish legal document %q: %w", doc.Kind, err)
+		}
+		if stored.Required != doc.Required || !maps.Equal(stored.Title, doc.Title) || !maps.Equal(stored.Body, doc.Body) {
+			return fmt.Errorf("version %d of legal document %q was published with other content; publish the change as version %d", doc.Version, doc.Kind, doc.Version+1)
+		}
+		current = append(current, stored)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = current
+	return nil
+}
+
+// Documents returns the current version of every document.
+func (s *ConsentService) Documents() []*domain.LegalDocument {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Document returns a version of a document, or its current version when version is 0.
+func (s *ConsentService) Document(ctx context.Context, kind string, version int) (*domain.LegalDocument, error) {
+	current := s.currentDocument(kind)
+	if current == nil {
+		return nil, ErrUnknownDocument
+	}
+	if version == 0 || version == current.Version {
+		return current, nil
+	}
+	doc, err := s.repo.FindDocument(ctx, kind, version)
+	if err != nil {
+		return nil, fmt.Errorf("could not find legal document: %w", err)
+	}
+	if doc == nil {
+		return nil, ErrUnknownDocument
+	}
+	return doc, nil
+}
+
+func (s *ConsentService) currentDocument(kind string) *domain.LegalDocument {
+	for _, doc := range s.Documents() {
+		if doc.Kind == kind {
+			return doc
+		}
+	}
+	return nil
+}
+
+// Statuses returns where a user stands with every current document.
+func (s *ConsentService) Statuses(ctx context.Context, userID int64) ([]ConsentStatus, error) {
+	ledger, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load consents: %w", err)
+	}
+	latest := make(map[string]*domain.Consent)
+	for _, consent := range ledger {
+		latest[consent.Kind] = consent
+	}
+
+	documents := s.Documents()
+	statuses := make([]ConsentStatus, 0, len(documents))
+	for _, doc := range documents {
+		statuses = append(statuses, ConsentStatus{Document: doc, Consent: latest[doc.Kind]})
+	}
+	return statuses, nil
+}
+
+// Pending returns the required documents whose current version the user has not accepted.
+func (s *ConsentService) Pending(ctx context.Context, userID int64) ([]*domain.LegalDocument, error) {
+	statuses, err := s.Statuses(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var pending []*domain.LegalDocument
+	for _, status := range statuses {
+		if status.Document.Required && !status.Current() {
+			pending = append(pending, status.Document)
+		}
+	}
+	return pending, nil
+}
+
+// Accept records that a user accepted the current version of the documents of the given kinds.
+// Documents whose current version the user accepted before are skipped.
+func (s *ConsentService) Accept(ctx context.Context, userID int64, kinds []string) error {
+	statuses, err := s.Statuses(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, kind := range kinds {
+		i := statusIndex(statuses, kind)
+		if i < 0 {
+			return fmt.Errorf("%w: %q", ErrUnknownDocument, kind)
+		}
+		if statuses[i].Current() {
+			continue
+		}
+		consent := &domain.Consent{UserID: userID, DocumentID: statuses[i].Document.ID, Action: domain.ConsentAccepted}
+		if err := s.repo.Record(ctx, consent); err != nil {
+			return fmt.Errorf("could not record consent: %w", err)
+		}
+		statuses[i].Consent = consent
+	}
+	return nil
+}
+
+// Withdraw records that a user withdrew the consent to an optional document. Withdrawing a consent
+// that is not in force changes nothing.
+func (s *ConsentService) Withdraw(ctx context.Context, userID int64, kind string) error {
+	statuses, err := s.Statuses(ctx, userID)
+	if err != nil {
+		return err
+	}
+	i := statusIndex(statuses, kind)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrUnknownDocument, kind)
+	}
+	status := statuses[i]
+	if status.Document.Required {
+		return ErrConsentRequired
+	}
+	if !status.Accepted() {
+		return nil
+	}
+	// The withdrawal refers to the version that was accepted.
+	consent := &domain.Consent{UserID: userID, DocumentID: status.Consent.DocumentID, Action: domain.ConsentWithdrawn}
+	if err := s.repo.Record(ctx, consent); err != nil {
+		return fmt.Errorf("could not record withdrawal: %w", err)
+	}
+	return nil
+}
+
+func statusIndex(statuses []ConsentStatus, kind string) int {
+	for i, status := range statuses {
+		if status.Document.Kind == kind {
+			return i
+		}
+	}
+	return -1
+}
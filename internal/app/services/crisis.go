@@ -0,0 +1,177 @@
+// github.com/DauletBai/oilan.org/internal/app/services/crisis.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/i18n"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CrisisSignal describes a crisis a classifier detected in a user message.
+type CrisisSignal struct {
+	Classifier string // Name of the classifier, e.g. "keywords"
+	Language   string // Language the message is written in, if the classifier knows it
+	Evidence   string // What the classifier matched
+}
+
+// CrisisClassifier decides whether a user message shows signs of a crisis, such as suicidal
+// thoughts or self-harm. It returns nil when it found none.
+type CrisisClassifier interface {
+	Classify(ctx context.Context, content string) (*CrisisSignal, error)
+}
+
+// KeywordClassifier detects a crisis by phrases in the languages of the UI. It needs nothing but
+// the message, so it keeps working when no LLM provider is available.
+type KeywordClassifier struct {
+	path     string
+	mu       sync.RWMutex
+	keywords map[string][]string // Normalized phrases per language
+}
+
+// LoadCrisisKeywords reads the phrases that indicate a crisis, per language. A phrase matches
+// whole words only, so that "want to die" is not found in "want to diet". A phrase ending in "*"
+// is a stem that matches any ending of its last word, e.g. "суицид*" also finds "суицидальные".
+func LoadCrisisKeywords(path string) (*KeywordClassifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crisis keywords: %w", err)
+	}
+	var file struct {
+		Keywords map[string][]string `json:"keywords"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse crisis keywords: %w", err)
+	}
+	if len(file.Keywords) == 0 {
+		return nil, errors.New("no crisis keywords configured")
+	}
+
+	classifier := &KeywordClassifier{path: path, keywords: make(map[string][]string)}
+	for language, phrases := range file.Keywords {
+		if !i18n.IsSupported(language) {
+			return nil, fmt.Errorf("crisis keywords for unsupported language %q", language)
+		}
+		for _, phrase := range phrases {
+			if phrase = normalizeCrisisText(phrase); strings.TrimSuffix(phrase, "*") != "" {
+				classifier.keywords[language] = append(classifier.keywords[language], phrase)
+			}
+		}
+	}
+	return classifier, nil
+}
+
+// Reload reads the phrases again and swaps them in. If the file is invalid, the phrases loaded
+// before stay in use and the error is returned.
+func (c *KeywordClassifier) Reload() error {
+	loaded, err := LoadCrisisKeywords(c.path)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keywords = loaded.keywords
+	return nil
+}
+
+// Classify looks for the phrases of every language, those of the request's locale first, so that
+// a crisis is found whichever language the user writes in.
+func (c *KeywordClassifier) Classify(ctx context.Context, content string) (*CrisisSignal, error) {
+	text := normalizeCrisisText(content)
+	locale := i18n.FromContext(ctx)
+	languages := []string{locale}
+	for _, language := range i18n.Supported {
+		if language != locale {
+			languages = append(languages, language)
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, language := range languages {
+		for _, phrase := range c.keywords[language] {
+			if containsPhrase(text, phrase) {
+				return &CrisisSignal{Classifier: "keywords", Language: language, Evidence: phrase}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// containsPhrase reports whether a normalized text contains a normalized phrase on word
+// boundaries, or only on the left one if the phrase is a stem.
+func containsPhrase(text, phrase string) bool {
+	stem, isStem := strings.CutSuffix(phrase, "*")
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], stem)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(stem)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && (isStem || !isWordRune(after)) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		offset = start + size
+	}
+}
+
+// isWordRune reports whether a rune is part of a word; utf8.RuneError marks the ends of the text.
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// normalizeCrisisText lowercases a text and folds spelling variants, so that phrases match
+// however the user typed them.
+func normalizeCrisisText(text string) string {
+	text = strings.ToLower(text)
+	text = strings.NewReplacer("ё", "е", "’", "'").Replace(text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// llmCrisisTimeout bounds how long a message waits for the LLM classifier.
+const llmCrisisTimeout = 10 * time.Second
+
+const llmCrisisPrompt = `You screen messages sent to a self-reflection chat for signs that the writer is in crisis.
+Answer with exactly one word: CRISIS if the message expresses suicidal thoughts, an intention to harm themselves, or acute danger to their life; otherwise SAFE.`
+
+// LLMCrisisClassifier asks an LLM whether a message shows signs of a crisis. It catches what
+// keyword lists miss, but depends on a provider being available.
+type LLMCrisisClassifier struct {
+	client  LLMClient
+	timeout time.Duration
+}
+
+// NewLLMCrisisClassifier creates a classifier that uses the given LLM client.
+func NewLLMCrisisClassifier(client LLMClient) *LLMCrisisClassifier {
+	return &LLMCrisisClassifier{client: client, timeout: llmCrisisTimeout}
+}
+
+// Classify sends the message alone, without the rest of the dialog, to the LLM.
+func (c *LLMCrisisClassifier) Classify(ctx context.Context, content string) (*CrisisSignal, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	zero := 0.0
+	ctx = WithGenerationParams(ctx, GenerationParams{Temperature: &zero, MaxTokens: 5})
+
+	history := []domain.Message{{Role: domain.RoleUser, Content: content}}
+	resp, err := c.client.GenerateResponse(ctx, history, llmCrisisPrompt)
+	if err != nil {
+		return nil, err
+	}
+	answer := strings.ToUpper(strings.TrimSpace(resp.Content))
+	if !strings.HasPrefix(answer, "CRISIS") {
+		return nil, nil
+	}
+	return &CrisisSignal{Classifier: "llm", Evidence: resp.Provider + "/" + resp.Model}, nil
+}
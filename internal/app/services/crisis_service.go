@@ -0,0 +1,97 @@
+// github.com/DauletBai/oilan.org/internal/app/services/crisis_service.go
+package services
+
+import (
+	"context"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"github.com/DauletBai/oilan.org/internal/i18n"
+	"log"
+	"time"
+)
+
+// CrisisProvider is recorded as the provider of crisis responses, which no LLM wrote.
+const CrisisProvider = "crisis"
+
+// crisisNotifyTimeout bounds how long a notification may take; it runs in the background.
+const crisisNotifyTimeout = 30 * time.Second
+
+// CrisisNotifier alerts the admins on call that a crisis was detected.
+type CrisisNotifier interface {
+	NotifyCrisis(ctx context.Context, event *domain.CrisisEvent) error
+}
+
+// CrisisService checks user messages for signs of a crisis and escalates the ones it finds.
+type CrisisService struct {
+	classifiers []CrisisClassifier
+	crisisRepo  repository.CrisisRepository
+	notifier    CrisisNotifier
+}
+
+// NewCrisisService creates a new CrisisService. The classifiers are asked in order until one
+// detects a crisis, so the ones that do not depend on the LLM should come first.
+func NewCrisisService(crisisRepo repository.CrisisRepository, notifier CrisisNotifier, classifiers ...CrisisClassifier) *CrisisService {
+	return &CrisisService{classifiers: classifiers, crisisRepo: crisisRepo, notifier: notifier}
+}
+
+// Detect returns the first crisis a classifier finds in a message, or nil. A classifier that
+// fails is logged and skipped, so that a message is never let through unchecked by the others.
+func (s *CrisisService) Detect(ctx context.Context, content string) *CrisisSignal {
+	for _, classifier := range s.classifiers {
+		signal, err := classifier.Classify(ctx, content)
+		if err != nil {
+			log.Printf("Crisis classifier %T failed: %v", classifier, err)
+			continue
+		}
+		if signal != nil {
+			return signal
+		}
+	}
+	return nil
+}
+
+// Response returns the crisis-resources message sent instead of an AI reply, and its language.
+// It is the same for every crisis, in the language of the message if the classifier knows it,
+// otherwise in the language of the UI.
+func (s *CrisisService) Response(ctx context.Context, signal *CrisisSignal) (string, string) {
+	language := signal.Language
+	if language == "" {
+		language = i18n.FromContext(ctx)
+	}
+	return i18n.T(language, "crisis.response"), language
+}
+
+// Escalate records the crisis, which flags the dialog, and notifies the admins on call in the
+// background so that the user gets the response without waiting.
+func (s *CrisisService) Escalate(ctx context.Context, dialog *domain.Dialog, message *domain.Message, signal *CrisisSignal, language string) error {
+	event := &domain.CrisisEvent{
+		DialogID:   dialog.ID,
+		UserID:     dialog.UserID,
+		MessageID:  &message.ID,
+		Classifier: signal.Classifier,
+		Language:   language,
+		Evidence:   signal.Evidence,
+		CreatedAt:  time.Now(),
+	}
+	err := s.crisisRepo.Record(ctx, event)
+	if err == nil && dialog.CrisisAt == nil {
+		dialog.CrisisAt = &event.CreatedAt
+	}
+
+	// The notification goes out even if the event could not be stored.
+	if s.notifier != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), crisisNotifyTimeout)
+			defer cancel()
+			if err := s.notifier.NotifyCrisis(ctx, event); err != nil {
+				log.Printf("Could not notify admins about the crisis in dialog %d: %v", event.DialogID, err)
+			}
+		}()
+	}
+	return err
+}
+
+// Events returns the crises detected in a dialog, oldest first.
+func (s *CrisisService) Events(ctx context.Context, dialogID int64) ([]*domain.CrisisEvent, error) {
+	return s.crisisRepo.ListByDialog(ctx, dialogID)
+}
@@ -0,0 +1,148 @@
+// github.com/DauletBai/oilan.org/internal/app/services/crisis_test.go
+package services
+
+import (
+	"context"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/i18n"
+	"testing"
+	"time"
+)
+
+func loadTestCrisisKeywords(t *testing.T) *KeywordClassifier {
+	t.Helper()
+	keywords, err := LoadCrisisKeywords("../../../configs/crisis.json")
+	if err != nil {
+		t.Fatalf("LoadCrisisKeywords: %v", err)
+	}
+	return keywords
+}
+
+func TestKeywordClassifier(t *testing.T) {
+	tests := []struct {
+		name         string
+		locale       string
+		content      string
+		wantLanguage string // Empty when no crisis should be found
+		wantEvidence string
+	}{
+		{name: "english phrase", locale: "en", content: "Some days I want to die.", wantLanguage: "en", wantEvidence: "want to die"},
+		{name: "upper case", locale: "en", content: "I WANT TO DIE", wantLanguage: "en", wantEvidence: "want to die"},
+		{name: "spread over lines", locale: "en", content: "i want to\n  die", wantLanguage: "en", wantEvidence: "want to die"},
+		{name: "curly apostrophe", locale: "en", content: "I don’t want to live", wantLanguage: "en", wantEvidence: "don't want to live"},
+		{name: "phrase inside a longer word", locale: "en", content: "I want to diet before summer", wantLanguage: ""},
+		{name: "english stem", locale: "en", content: "I read about suicides", wantLanguage: "en", wantEvidence: "suicide*"},
+		{name: "russian phrase", locale: "ru", content: "Я больше не хочу жить", wantLanguage: "ru", wantEvidence: "не хочу жить"},
+		{name: "russian yo", locale: "ru", content: "Хочу свести счёты с жизнью", wantLanguage: "ru", wantEvidence: "свести счеты с жизнью"},
+		{name: "russian stem", locale: "ru", content: "У меня Суицидальные мысли", wantLanguage: "ru", wantEvidence: "суицид*"},
+		{name: "russian stem inside a word", locale: "ru", content: "Это антисуицидальная программа", wantLanguage: ""},
+		{name: "kazakh phrase", locale: "kk", content: "Мен өлгім келеді", wantLanguage: "kk", wantEvidence: "өлгім келеді"},
+		{name: "kazakh upper case", locale: "kk", content: "ӨЛГІМ КЕЛЕДІ", wantLanguage: "kk", wantEvidence: "өлгім келеді"},
+		{name: "kazakh stem", locale: "kk", content: "өз-өзіне қол жұмсауға ойладым", wantLanguage: "kk", wantEvidence: "өз-өзіне қол жұмсау*"},
+		{name: "shared phrase in the locale's language", locale: "ru", content: "суицид", wantLanguage: "ru", wantEvidence: "суицид*"},
+		{name: "shared phrase in another locale", locale: "kk", content: "суицид", wantLanguage: "kk", wantEvidence: "суицид*"},
+		{name: "language other than the locale", locale: "kk", content: "I want to die", wantLanguage: "en", wantEvidence: "want to die"},
+		{name: "safe message", locale: "ru", content: "Сегодня был хороший день", wantLanguage: ""},
+	}
+
+	classifier := loadTestCrisisKeywords(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := i18n.WithLocale(context.Background(), tt.locale)
+			signal, err := classifier.Classify(ctx, tt.content)
+			if err != nil {
+				t.Fatalf("Classify: %v", err)
+			}
+			if tt.wantLanguage == "" {
+				if signal != nil {
+					t.Errorf("found %+v, want no crisis", signal)
+				}
+				return
+			}
+			want := CrisisSignal{Classifier: "keywords", Language: tt.wantLanguage, Evidence: tt.wantEvidence}
+			if signal == nil || *signal != want {
+				t.Errorf("signal = %+v, want %+v", signal, want)
+			}
+		})
+	}
+}
+
+// answeringClient answers every request with content, or waits for the request to be cancelled
+// when content is empty.
+type answeringClient struct {
+	content string
+}
+
+func (c *answeringClient) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*LLMResponse, error) {
+	if c.content == "" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &LLMResponse{Content: c.content, Provider: "stub", Model: "classifier"}, nil
+}
+
+func (c *answeringClient) StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk StreamHandler) (*LLMResponse, error) {
+	return c.GenerateResponse(ctx, history, systemPrompt)
+}
+
+func TestCrisisServiceDetect(t *testing.T) {
+	tests := []struct {
+		name      string
+		llmAnswer string // Empty for an LLM that never answers
+		llmFirst  bool
+		content   string
+		want      *CrisisSignal
+	}{
+		{
+			name:     "llm timeout falls back to the keywords",
+			content:  "I want to die",
+			llmFirst: true,
+			want:     &CrisisSignal{Classifier: "keywords", Language: "en", Evidence: "want to die"},
+		},
+		{
+			name:    "llm timeout without a keyword",
+			content: "Everything feels grey",
+			want:    nil,
+		},
+		{
+			name:      "keywords are asked before the llm",
+			content:   "I want to die",
+			llmAnswer: "SAFE",
+			want:      &CrisisSignal{Classifier: "keywords", Language: "en", Evidence: "want to die"},
+		},
+		{
+			name:      "llm catches what the keywords miss",
+			content:   "I have given away all my things and said goodbye",
+			llmAnswer: "CRISIS",
+			want:      &CrisisSignal{Classifier: "llm", Evidence: "stub/classifier"},
+		},
+		{
+			name:      "llm finds no crisis",
+			content:   "Everything feels grey",
+			llmAnswer: " safe\n",
+			want:      nil,
+		},
+	}
+
+	keywords := loadTestCrisisKeywords(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := NewLLMCrisisClassifier(&answeringClient{content: tt.llmAnswer})
+			llm.timeout = 50 * time.Millisecond
+			classifiers := []CrisisClassifier{keywords, llm}
+			if tt.llmFirst {
+				classifiers = []CrisisClassifier{llm, keywords}
+			}
+			crisis := NewCrisisService(&memoryCrisisRepo{}, nil, classifiers...)
+
+			ctx := i18n.WithLocale(context.Background(), "en")
+			signal := crisis.Detect(ctx, tt.content)
+			switch {
+			case tt.want == nil && signal != nil:
+				t.Errorf("found %+v, want no crisis", signal)
+			case tt.want != nil && (signal == nil || *signal != *tt.want):
+				t.Errorf("signal = %+v, want %+v", signal, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// github.com/DauletBai/oilan.org/internal/app/services/dialog_lock.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"sync"
+	"time"
+)
+
+// ErrDialogBusy is returned when a message waited too long for the messages posted before it.
+var ErrDialogBusy = errors.New("dialog is busy answering earlier messages")
+
+// dialogLockTimeout bounds how long a message waits for the lock of its dialog, unless the
+// context sets a different bound with WithLockTimeout.
+const dialogLockTimeout = 2 * time.Minute
+
+type lockTimeoutKey struct{}
+
+// WithLockTimeout returns a context whose messages wait at most timeout for the lock of their
+// dialog, e.g. because the client of the request stops listening much sooner.
+func WithLockTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, lockTimeoutKey{}, timeout)
+}
+
+// lockTimeout returns the timeout set with WithLockTimeout, or dialogLockTimeout.
+func lockTimeout(ctx context.Context) time.Duration {
+	if timeout, ok := ctx.Value(lockTimeoutKey{}).(time.Duration); ok {
+		return timeout
+	}
+	return dialogLockTimeout
+}
+
+// DialogLocks lets one message at a time be worked on per dialog, from loading its history to
+// saving the reply, so that turns sent in quick succession are answered in order. Waiters in this
+// instance queue on a channel in the order they arrived; the optional backend extends the lock
+// to the other server instances.
+type DialogLocks struct {
+	backend repository.DialogLocker
+
+	mu    sync.Mutex
+	locks map[int64]*dialogLock
+}
+
+// dialogLock is the lock of one dialog, kept while anyone holds or waits for it.
+type dialogLock struct {
+	held chan struct{} // Holds a value while the dialog is locked
+	refs int           // Holders and waiters
+}
+
+// NewDialogLocks creates the per-dialog locks. With a nil backend, they only hold within this instance.
+func NewDialogLocks(backend repository.DialogLocker) *DialogLocks {
+	return &DialogLocks{backend: backend, locks: make(map[int64]*dialogLock)}
+}
+
+// Lock blocks until the dialog is locked or ctx is done. The returned function releases the lock.
+// After dialogLockTimeout, or the timeout set with WithLockTimeout, it gives up with ErrDialogBusy.
+func (l *DialogLocks) Lock(ctx context.Context, dialogID int64) (func(), error) {
+	waitCtx, cancel := context.WithTimeout(ctx, lockTimeout(ctx))
+	defer cancel()
+
+	// 1. Queue behind the other messages of the dialog in this instance.
+	l.mu.Lock()
+	lock, ok := l.locks[dialogID]
+	if !ok {
+		lock = &dialogLock{held: make(chan struct{}, 1)}
+		l.locks[dialogID] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	select {
+	case lock.held <- struct{}{}:
+	case <-waitCtx.Done():
+		l.release(dialogID, lock, false)
+		return nil, waitError(ctx, waitCtx.Err())
+	}
+
+	// 2. Only then take the lock shared with the other instances, so that a dialog keeps at most
+	// one database connection busy in each instance.
+	if l.backend == nil {
+		return func() { l.release(dialogID, lock, true) }, nil
+	}
+	unlock, err := l.backend.Lock(waitCtx, dialogID)
+	if err != nil {
+		l.release(dialogID, lock, true)
+		if waitCtx.Err() != nil {
+			return nil, waitError(ctx, waitCtx.Err())
+		}
+		return nil, fmt.Errorf("could not lock dialog %d: %w", dialogID, err)
+	}
+	return func() {
+		unlock()
+		l.release(dialogID, lock, true)
+	}, nil
+}
+
+// waitError tells a wait that timed out apart from a request that was cancelled.
+func waitError(ctx context.Context, err error) error {
+	if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return ErrDialogBusy
+	}
+	return err
+}
+
+// release lets the next waiter in, if held, and forgets the lock once nobody needs it.
+func (l *DialogLocks) release(dialogID int64, lock *dialogLock, held bool) {
+	if held {
+		<-lock.held
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock.refs--
+	if lock.refs == 0 {
+		delete(l.locks, dialogID)
+	}
+}
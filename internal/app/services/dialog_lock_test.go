@@ -0,0 +1,177 @@
+// github.com/DauletBai/oilan.org/internal/app/services/dialog_lock_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockerFunc turns a function into a DialogLocker.
+type lockerFunc func(ctx context.Context, dialogID int64) (func(), error)
+
+func (f lockerFunc) Lock(ctx context.Context, dialogID int64) (func(), error) {
+	return f(ctx, dialogID)
+}
+
+// lockAsync locks a dialog in the background and reports the result on the returned channel.
+func lockAsync(ctx context.Context, locks *DialogLocks, dialogID int64) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		unlock, err := locks.Lock(ctx, dialogID)
+		if err == nil {
+			unlock()
+		}
+		done <- err
+	}()
+	return done
+}
+
+func TestDialogLocksExcludeEachOther(t *testing.T) {
+	locks := NewDialogLocks(nil)
+	unlock, err := locks.Lock(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another dialog is not held up.
+	if err := <-lockAsync(context.Background(), locks, 2); err != nil {
+		t.Fatalf("Lock of another dialog: %v", err)
+	}
+
+	// The same dialog waits until the lock is released.
+	done := lockAsync(context.Background(), locks, 1)
+	select {
+	case err := <-done:
+		t.Fatalf("Lock of a held dialog returned %v before the lock was released", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Lock after release: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the waiter never got the released lock")
+	}
+
+	if n := len(locks.locks); n != 0 {
+		t.Errorf("%d locks kept after everyone released them", n)
+	}
+}
+
+func TestDialogLocksGiveUp(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{
+			name: "lock timeout",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return WithLockTimeout(context.Background(), 50*time.Millisecond), func() {}
+			},
+			wantErr: ErrDialogBusy,
+		},
+		{
+			name: "request deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name: "request cancelled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locks := NewDialogLocks(nil)
+			unlock, err := locks.Lock(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer unlock()
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			if _, err := locks.Lock(ctx, 1); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Lock = %v, want %v", err, tt.wantErr)
+			}
+			// The waiter that gave up is forgotten; only the holder is left.
+			if refs := locks.locks[1].refs; refs != 1 {
+				t.Errorf("lock has %d holders and waiters, want 1", refs)
+			}
+		})
+	}
+}
+
+func TestDialogLocksBackend(t *testing.T) {
+	var mu sync.Mutex
+	held := make(map[int64]bool)
+	backendErr := errors.New("connection refused")
+	backend := lockerFunc(func(ctx context.Context, dialogID int64) (func(), error) {
+		switch dialogID {
+		case 2: // Held by another instance
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case 3:
+			return nil, backendErr
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if held[dialogID] {
+			t.Errorf("the backend lock of dialog %d was taken twice in one instance", dialogID)
+		}
+		held[dialogID] = true
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			held[dialogID] = false
+		}, nil
+	})
+	locks := NewDialogLocks(backend)
+
+	t.Run("taken after the local lock", func(t *testing.T) {
+		unlock, err := locks.Lock(context.Background(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := lockAsync(context.Background(), locks, 1)
+		time.Sleep(50 * time.Millisecond)
+		unlock()
+		if err := <-done; err != nil {
+			t.Fatalf("Lock after release: %v", err)
+		}
+		if held[1] {
+			t.Error("the backend lock is still held after release")
+		}
+	})
+
+	t.Run("held by another instance", func(t *testing.T) {
+		ctx := WithLockTimeout(context.Background(), 50*time.Millisecond)
+		if _, err := locks.Lock(ctx, 2); !errors.Is(err, ErrDialogBusy) {
+			t.Errorf("Lock = %v, want %v", err, ErrDialogBusy)
+		}
+	})
+
+	t.Run("backend failure", func(t *testing.T) {
+		if _, err := locks.Lock(context.Background(), 3); !errors.Is(err, backendErr) {
+			t.Errorf("Lock = %v, want %v", err, backendErr)
+		}
+	})
+
+	if n := len(locks.locks); n != 0 {
+		t.Errorf("%d local locks kept after every wait ended", n)
+	}
+}
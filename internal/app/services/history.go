@@ -0,0 +1,186 @@
+// github.com/DauletBai/oilan.org/internal/app/services/history.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// summarizerPrompt instructs the model to fold older turns into the running summary.
+const summarizerPrompt = `You maintain a concise running summary of a conversation between a user and Oilan, an AI guide for self-reflection.
+You receive the previous summary (possibly empty) and the turns that happened after it.
+Write an updated summary that keeps every fact the guide will need later: symptoms and when they began,
+emotional events, people involved, insights the user reached and open questions.
+Write in the language the user speaks. Reply with the summary only.`
+
+// ContextBudget limits how much of a dialog is sent to the model on every turn.
+type ContextBudget struct {
+	MaxTokens         int // Size of the model's context window we are willing to use
+	ReplyTokens       int // Tokens reserved for the model's answer
+	MinRecentMessages int // Messages that are always sent verbatim, whatever their size
+	SummaryTokens     int // Tokens reserved for the summary of older turns
+}
+
+// DefaultContextBudget returns a budget that fits comfortably into all supported models.
+func DefaultContextBudget() ContextBudget {
+	return ContextBudget{
+		MaxTokens:         16000,
+		ReplyTokens:       2000,
+		MinRecentMessages: 6,
+		SummaryTokens:     1000,
+	}
+}
+
+// ContextWindow is exactly what is sent to the model for one turn.
+type ContextWindow struct {
+	SystemPrompt    string           `json:"system_prompt"` // Includes the summary, if any
+	Summary         string           `json:"summary,omitempty"`
+	Messages        []domain.Message `json:"messages"`         // Recent turns, sent verbatim
+	OmittedMessages int              `json:"omitted_messages"` // Older turns replaced by the summary
+	EstimatedTokens int              `json:"estimated_tokens"`
+}
+
+// HistoryBuilder decides which part of a dialog is sent to the model verbatim
+// and keeps a stored summary of everything older up to date.
+type HistoryBuilder struct {
+	budget      ContextBudget
+	summaryRepo repository.SummaryRepository
+	llmClient   LLMClient
+	quotas      *QuotaService // Charges the tokens of summaries to the owner of the dialog; may be nil
+}
+
+// NewHistoryBuilder creates a new HistoryBuilder.
+func NewHistoryBuilder(budget ContextBudget, summaryRepo repository.SummaryRepository, llmClient LLMClient, quotas *QuotaService) *HistoryBuilder {
+	return &HistoryBuilder{budget: budget, summaryRepo: summaryRepo, llmClient: llmClient, quotas: quotas}
+}
+
+// Build returns the context window for the next turn of the dialog. When older turns have to be
+// dropped, the stored summary is brought up to date first. With update set to false the stored
+// summary is used as is, which lets admins preview the context without calling the model.
+func (b *HistoryBuilder) Build(ctx context.Context, dialog *domain.Dialog, systemPrompt string, update bool) (*ContextWindow, error) {
+	messages := dialog.Messages
+	cut := b.splitPoint(messages, systemPrompt)
+	if cut == 0 {
+		return &ContextWindow{
+			SystemPrompt:    systemPrompt,
+			Messages:        messages,
+			EstimatedTokens: estimateTokens(systemPrompt) + estimateHistoryTokens(messages),
+		}, nil
+	}
+
+	summary, err := b.summaryRepo.FindByDialogID(ctx, dialog.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load dialog summary: %w", err)
+	}
+	older := messages[:cut]
+	if update && (summary == nil || summary.CoveredUntilID < older[len(older)-1].ID) {
+		updated, err := b.summarize(ctx, dialog, summary, older)
+		if err != nil {
+			// The conversation can go on without the summary; the older turns are simply dropped.
+			log.Printf("Could not update summary of dialog %d: %v", dialog.ID, err)
+		} else {
+			summary = updated
+		}
+	}
+
+	window := &ContextWindow{
+		SystemPrompt:    systemPrompt,
+		Messages:        messages[cut:],
+		OmittedMessages: cut,
+	}
+	if summary != nil {
+		window.Summary = summary.Content
+		window.SystemPrompt = systemPrompt + "\n\nSummary of the earlier part of this conversation:\n" + summary.Content
+	}
+	window.EstimatedTokens = estimateTokens(window.SystemPrompt) + estimateHistoryTokens(window.Messages)
+	return window, nil
+}
+
+// splitPoint returns the index of the first message that is sent verbatim.
+func (b *HistoryBuilder) splitPoint(messages []domain.Message, systemPrompt string) int {
+	available := b.budget.MaxTokens - b.budget.ReplyTokens - estimateTokens(systemPrompt)
+	if estimateHistoryTokens(messages) <= available {
+		return 0
+	}
+	available -= b.budget.SummaryTokens
+
+	cut := len(messages)
+	used := 0
+	for cut > 0 {
+		cost := estimateMessageTokens(messages[cut-1])
+		kept := len(messages) - cut
+		if kept >= b.budget.MinRecentMessages && used+cost > available {
+			break
+		}
+		used += cost
+		cut--
+	}
+
+	// Providers expect the history to start with a user turn, so a leading AI turn goes into the summary.
+	for cut > 0 && cut < len(messages) && messages[cut].Role != domain.RoleUser {
+		cut++
+	}
+	return cut
+}
+
+// summarize folds the messages not yet covered by the previous summary into a new summary and stores it.
+// The tokens it takes are charged to the owner of the dialog.
+func (b *HistoryBuilder) summarize(ctx context.Context, dialog *domain.Dialog, previous *domain.DialogSummary, older []domain.Message) (*domain.DialogSummary, error) {
+	var input strings.Builder
+	var coveredUntil int64
+	if previous != nil {
+		input.WriteString("Previous summary:\n" + previous.Content + "\n\n")
+		coveredUntil = previous.CoveredUntilID
+	}
+	input.WriteString("New turns:\n")
+	for _, msg := range older {
+		if msg.ID <= coveredUntil {
+			continue
+		}
+		fmt.Fprintf(&input, "%s: %s\n", msg.Role, msg.Content)
+	}
+
+	request := []domain.Message{{DialogID: dialog.ID, Role: domain.RoleUser, Content: input.String()}}
+	resp, err := b.llmClient.GenerateResponse(ctx, request, summarizerPrompt)
+	if err != nil {
+		return nil, err
+	}
+	if b.quotas != nil {
+		if err := b.quotas.ChargeTokens(ctx, dialog.UserID, dialog.ID, domain.TokenUsageSummary, resp); err != nil {
+			log.Printf("Could not charge summary of dialog %d: %v", dialog.ID, err)
+		}
+	}
+
+	summary := &domain.DialogSummary{
+		DialogID:       dialog.ID,
+		Content:        strings.TrimSpace(resp.Content),
+		CoveredUntilID: older[len(older)-1].ID,
+	}
+	if err := b.summaryRepo.Save(ctx, summary); err != nil {
+		return nil, fmt.Errorf("could not save dialog summary: %w", err)
+	}
+	return summary, nil
+}
+
+// estimateTokens is a cheap, provider-independent approximation. Cyrillic text needs noticeably more
+// tokens per character than English, so we count one token per three characters to stay on the safe side.
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 2) / 3
+}
+
+func estimateMessageTokens(msg domain.Message) int {
+	return estimateTokens(msg.Content) + 4 // Role and formatting overhead
+}
+
+func estimateHistoryTokens(messages []domain.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += estimateMessageTokens(msg)
+	}
+	return total
+}
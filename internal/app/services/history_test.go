@@ -0,0 +1,217 @@
+// github.com/DauletBai/oilan.org/internal/app/services/history_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"strings"
+	"testing"
+)
+
+// memorySummaryRepo is an in-memory SummaryRepository with one row per dialog, like dialog_summaries.
+type memorySummaryRepo struct {
+	summaries map[int64]domain.DialogSummary
+	saves     int
+}
+
+func (r *memorySummaryRepo) FindByDialogID(ctx context.Context, dialogID int64) (*domain.DialogSummary, error) {
+	summary, ok := r.summaries[dialogID]
+	if !ok {
+		return nil, nil
+	}
+	return &summary, nil
+}
+
+func (r *memorySummaryRepo) FindRecentByUserID(ctx context.Context, userID int64, beforeDialogID int64, limit int) ([]*domain.DialogSummary, error) {
+	return nil, nil
+}
+
+func (r *memorySummaryRepo) Save(ctx context.Context, summary *domain.DialogSummary) error {
+	r.summaries[summary.DialogID] = *summary
+	r.saves++
+	return nil
+}
+
+// summarizerStub answers every request with a fixed summary, or fails with err, and records the
+// requests it got.
+type summarizerStub struct {
+	summary  string
+	err      error
+	requests []string
+}
+
+func (s *summarizerStub) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*LLMResponse, error) {
+	s.requests = append(s.requests, history[len(history)-1].Content)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &LLMResponse{Content: " " + s.summary + "\n"}, nil
+}
+
+func (s *summarizerStub) StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk StreamHandler) (*LLMResponse, error) {
+	return s.GenerateResponse(ctx, history, systemPrompt)
+}
+
+// testTurns returns n messages that alternate between the user and the AI, starting with the user.
+// Each costs 14 tokens: 30 characters for 10 and 4 for the role.
+func testTurns(n int) []domain.Message {
+	messages := make([]domain.Message, n)
+	for i := range messages {
+		role := domain.RoleUser
+		if i%2 == 1 {
+			role = domain.RoleAI
+		}
+		messages[i] = domain.Message{ID: int64(100 + i), DialogID: 1, Seq: int64(i + 1), Role: role, Content: fmt.Sprintf("turn %02d %s", i, strings.Repeat(".", 22))}
+	}
+	return messages
+}
+
+// testBudget leaves 90 tokens for the history, or 70 once a summary is needed.
+var testBudget = ContextBudget{MaxTokens: 100, ReplyTokens: 10, MinRecentMessages: 2, SummaryTokens: 20}
+
+func TestHistorySplitPoint(t *testing.T) {
+	long := testTurns(6)
+	for i := range long {
+		long[i].Content = strings.Repeat("x", 300)
+	}
+
+	tests := []struct {
+		name         string
+		budget       ContextBudget
+		messages     []domain.Message
+		systemPrompt string
+		want         int
+	}{
+		{name: "everything fits", budget: testBudget, messages: testTurns(6), want: 0},
+		{name: "exactly fits", budget: testBudget, messages: testTurns(6), systemPrompt: strings.Repeat("p", 18), want: 0},
+		{name: "over the budget", budget: testBudget, messages: testTurns(10), want: 6}, // 5 fit; the leading AI turn is summarized
+		{name: "cut at a user turn", budget: testBudget, messages: testTurns(11), want: 6},
+		{name: "system prompt takes its share", budget: testBudget, messages: testTurns(10), systemPrompt: strings.Repeat("p", 60), want: 8},
+		{name: "recent messages kept whatever their size", budget: testBudget, messages: long, want: 4},
+		{name: "ai turn at the cut is summarized", budget: testBudget, messages: long[:5], want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewHistoryBuilder(tt.budget, nil, nil, nil)
+			if got := b.splitPoint(tt.messages, tt.systemPrompt); got != tt.want {
+				t.Errorf("splitPoint = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryBuild(t *testing.T) {
+	messages := testTurns(10) // Split at 6
+
+	tests := []struct {
+		name         string
+		stored       *domain.DialogSummary
+		update       bool
+		llmErr       error
+		wantSummary  string
+		wantRequests []string // Start of every request the summarizer gets
+		wantSaved    int64    // CoveredUntilID of the stored summary, 0 if none is stored
+	}{
+		{
+			name:         "first summary",
+			update:       true,
+			wantSummary:  "new summary",
+			wantRequests: []string{"New turns:\nuser: turn 00"},
+			wantSaved:    105,
+		},
+		{
+			name:        "up to date summary is reused",
+			stored:      &domain.DialogSummary{DialogID: 1, Content: "stored summary", CoveredUntilID: 105},
+			update:      true,
+			wantSummary: "stored summary",
+			wantSaved:   105,
+		},
+		{
+			name:         "older summary is extended",
+			stored:       &domain.DialogSummary{DialogID: 1, Content: "stored summary", CoveredUntilID: 101},
+			update:       true,
+			wantSummary:  "new summary",
+			wantRequests: []string{"Previous summary:\nstored summary\n\nNew turns:\nuser: turn 02"},
+			wantSaved:    105,
+		},
+		{
+			name:        "stale summary without update",
+			stored:      &domain.DialogSummary{DialogID: 1, Content: "stored summary", CoveredUntilID: 101},
+			update:      false,
+			wantSummary: "stored summary",
+			wantSaved:   101,
+		},
+		{
+			name:         "failed summary",
+			update:       true,
+			llmErr:       errors.New("provider down"),
+			wantRequests: []string{"New turns:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memorySummaryRepo{summaries: make(map[int64]domain.DialogSummary)}
+			if tt.stored != nil {
+				repo.summaries[tt.stored.DialogID] = *tt.stored
+			}
+			llm := &summarizerStub{summary: "new summary", err: tt.llmErr}
+			dialog := &domain.Dialog{ID: 1, UserID: 7, Messages: messages}
+
+			window, err := NewHistoryBuilder(testBudget, repo, llm, nil).Build(context.Background(), dialog, "Be kind.", tt.update)
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+
+			// The newest turns are sent verbatim, starting with a user turn.
+			if window.OmittedMessages != 6 || len(window.Messages) != 4 || window.Messages[0].ID != 106 || window.Messages[3].ID != 109 {
+				t.Errorf("window keeps %d messages after omitting %d, want 106..109 after omitting 6", len(window.Messages), window.OmittedMessages)
+			}
+			if window.Summary != tt.wantSummary {
+				t.Errorf("summary = %q, want %q", window.Summary, tt.wantSummary)
+			}
+			if tt.wantSummary != "" && !strings.HasSuffix(window.SystemPrompt, "\n"+tt.wantSummary) {
+				t.Errorf("system prompt %q does not end with the summary", window.SystemPrompt)
+			}
+
+			if len(llm.requests) != len(tt.wantRequests) {
+				t.Fatalf("summarizer asked %d times, want %d", len(llm.requests), len(tt.wantRequests))
+			}
+			for i, want := range tt.wantRequests {
+				request := llm.requests[i]
+				if !strings.HasPrefix(request, want) {
+					t.Errorf("summarizer request %q does not start with %q", request, want)
+				}
+				// Only the turns after the stored summary and before the split are summarized.
+				for _, msg := range messages {
+					summarized := msg.ID <= 105 && (tt.stored == nil || msg.ID > tt.stored.CoveredUntilID)
+					if strings.Contains(request, msg.Content) != summarized {
+						t.Errorf("summarizer request %q: turn %d included = %t, want %t", request, msg.ID, !summarized, summarized)
+					}
+				}
+			}
+
+			// There is at most one row per dialog, written only when the summary changed.
+			saved, ok := repo.summaries[1]
+			if tt.wantSaved == 0 {
+				if ok {
+					t.Errorf("stored %+v, want no summary", saved)
+				}
+				return
+			}
+			if saved.CoveredUntilID != tt.wantSaved {
+				t.Errorf("stored summary covers until %d, want %d", saved.CoveredUntilID, tt.wantSaved)
+			}
+			wantSaves := 0
+			if tt.wantRequests != nil {
+				wantSaves = 1
+			}
+			if repo.saves != wantSaves {
+				t.Errorf("summary saved %d times, want %d", repo.saves, wantSaves)
+			}
+		})
+	}
+}
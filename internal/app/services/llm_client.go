@@ -0,0 +1,82 @@
+// github.com/DauletBai/oilan.org/internal/app/services/llm_client.go
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"time"
+)
+
+var (
+	// ErrLLMUnavailable is returned when no LLM provider could produce a response.
+	ErrLLMUnavailable = errors.New("no llm provider is available")
+	// ErrResponseBlocked is returned when a provider refused to answer because of its safety filters.
+	ErrResponseBlocked = errors.New("response was blocked by the provider's safety filters")
+)
+
+// StreamHandler receives partial chunks of an AI response as they are generated.
+// Returning an error aborts the stream.
+type StreamHandler func(chunk string) error
+
+// Normalized finish reasons reported in LLMResponse.FinishReason.
+const (
+	FinishReasonStop      = "stop"       // The model finished its answer
+	FinishReasonMaxTokens = "max_tokens" // The answer was cut off by the token limit
+	FinishReasonSafety    = "safety"     // The answer was cut off by safety filters
+	FinishReasonOther     = "other"
+)
+
+// LLMResponse is the result of a single generation together with its metadata.
+type LLMResponse struct {
+	Content          string
+	Provider         string // Name of the provider that actually produced the response
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	Latency          time.Duration // Time from sending the request to the end of the response
+	FinishReason     string
+}
+
+// GenerationParams tunes a single generation, e.g. for the persona of a dialog.
+// Unset values keep the configuration of the provider.
+type GenerationParams struct {
+	Models      map[string]string `json:"models,omitempty"` // Model per provider name
+	Temperature *float64          `json:"temperature,omitempty"`
+	MaxTokens   int               `json:"max_tokens,omitempty"`
+}
+
+// Model returns the model to use for a provider, or fallback when none is set.
+func (p GenerationParams) Model(provider, fallback string) string {
+	if model := p.Models[provider]; model != "" {
+		return model
+	}
+	return fallback
+}
+
+// IsZero reports whether the params change nothing.
+func (p GenerationParams) IsZero() bool {
+	return len(p.Models) == 0 && p.Temperature == nil && p.MaxTokens == 0
+}
+
+type generationParamsKey struct{}
+
+// WithGenerationParams returns a context that carries params to the LLM client. They travel with the
+// context, so that wrappers such as the fallback chain pass them on without knowing about them.
+func WithGenerationParams(ctx context.Context, params GenerationParams) context.Context {
+	return context.WithValue(ctx, generationParamsKey{}, params)
+}
+
+// GenerationParamsFromContext returns the params set with WithGenerationParams, if any.
+func GenerationParamsFromContext(ctx context.Context) GenerationParams {
+	params, _ := ctx.Value(generationParamsKey{}).(GenerationParams)
+	return params
+}
+
+// LLMClient defines the interface for an external Large Language Model.
+type LLMClient interface {
+	GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*LLMResponse, error)
+	// StreamResponse generates a response chunk by chunk, passing each chunk to onChunk.
+	// It returns the complete response once the stream has finished.
+	StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk StreamHandler) (*LLMResponse, error)
+}
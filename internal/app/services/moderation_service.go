@@ -0,0 +1,251 @@
+// github.com/DauletBai/oilan.org/internal/app/services/moderation_service.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrModerationItemNotFound is returned when a moderation item does not exist.
+	ErrModerationItemNotFound = errors.New("moderation item not found")
+	// ErrModerationItemResolved is returned when a resolved item is changed without reopening it.
+	ErrModerationItemResolved = errors.New("moderation item is resolved")
+	// ErrModerationItemOpen is returned when an item that is still open is reopened.
+	ErrModerationItemOpen = errors.New("moderation item is open")
+	// ErrEmptyNote is returned when an admin adds a note without text.
+	ErrEmptyNote = errors.New("note is empty")
+	// ErrMessageNotFound is returned when a report or score refers to a message that is not in the dialog.
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrInvalidScore is returned when a score is out of range.
+	ErrInvalidScore = errors.New("score must be between 1 and 5")
+)
+
+// DefaultFeedbackThreshold is the highest score that puts a message in the review queue.
+const DefaultFeedbackThreshold = 2
+
+// maxExcerptRunes bounds the excerpt of the triggering text stored with a moderation item.
+const maxExcerptRunes = 500
+
+// Flag describes why a dialog or message should be reviewed.
+type Flag struct {
+	Dialog   *domain.Dialog
+	Message  *domain.Message // The triggering message, if any
+	Source   string
+	Severity string
+	Reason   string
+}
+
+// ModerationService runs the admin review queue: safety checks, user reports and low scores put
+// dialogs and messages in it, and admins assign, annotate and resolve them. Every change is recorded.
+type ModerationService struct {
+	moderationRepo    repository.ModerationRepository
+	feedbackRepo      repository.FeedbackRepository
+	feedbackThreshold int
+}
+
+// NewModerationService creates a new ModerationService. Messages scored at or below
+// feedbackThreshold are put in the queue.
+func NewModerationService(moderationRepo repository.ModerationRepository, feedbackRepo repository.FeedbackRepository, feedbackThreshold int) *ModerationService {
+	return &ModerationService{moderationRepo: moderationRepo, feedbackRepo: feedbackRepo, feedbackThreshold: feedbackThreshold}
+}
+
+// Flag puts a dialog or message in the queue. If the same message is already waiting for review
+// for the same reason, the open item is flagged again instead, raising its severity if needed.
+func (s *ModerationService) Flag(ctx context.Context, flag Flag) (*domain.ModerationItem, error) {
+	var messageID *int64
+	excerpt := ""
+	if flag.Message != nil {
+		messageID = &flag.Message.ID
+		excerpt = truncateRunes(flag.Message.Content, maxExcerptRunes)
+	}
+
+	open, err := s.moderationRepo.FindOpen(ctx, flag.Dialog.ID, messageID, flag.Source)
+	if err != nil {
+		return nil, fmt.Errorf("could not look up moderation item: %w", err)
+	}
+	if open != nil {
+		if severityRank(flag.Severity) < severityRank(open.Severity) {
+			open.Severity = flag.Severity
+		}
+		event := &domain.ModerationEvent{Action: domain.ModerationActionFlagged, Note: flag.Reason}
+		if err := s.moderationRepo.Update(ctx, open, event); err != nil {
+			return nil, fmt.Errorf("could not update moderation item: %w", err)
+		}
+		return open, nil
+	}
+
+	item := &domain.ModerationItem{
+		DialogID:  flag.Dialog.ID,
+		UserID:    flag.Dialog.UserID,
+		MessageID: messageID,
+		Source:    flag.Source,
+		Severity:  flag.Severity,
+		Reason:    flag.Reason,
+		Excerpt:   excerpt,
+		Status:    domain.ModerationOpen,
+	}
+	event := &domain.ModerationEvent{Action: domain.ModerationActionCreated}
+	if err := s.moderationRepo.Create(ctx, item, event); err != nil {
+		return nil, fmt.Errorf("could not create moderation item: %w", err)
+	}
+	return item, nil
+}
+
+// Report puts a message the user reported in the queue.
+func (s *ModerationService) Report(ctx context.Context, dialog *domain.Dialog, message *domain.Message, reason string) (*domain.ModerationItem, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		reason = "Reported by the user"
+	}
+	return s.Flag(ctx, Flag{
+		Dialog:   dialog,
+		Message:  message,
+		Source:   domain.ModerationSourceUserReport,
+		Severity: domain.SeverityHigh,
+		Reason:   reason,
+	})
+}
+
+// Rate stores the score a user gave an AI message and puts the message in the queue if the
+// score is low.
+func (s *ModerationService) Rate(ctx context.Context, dialog *domain.Dialog, message *domain.Message, score int, comment string) error {
+	if score < 1 || score > 5 {
+		return ErrInvalidScore
+	}
+	feedback := &domain.MessageFeedback{
+		MessageID: message.ID,
+		UserID:    dialog.UserID,
+		Score:     score,
+		Comment:   strings.TrimSpace(comment),
+	}
+	if err := s.feedbackRepo.Save(ctx, feedback); err != nil {
+		return fmt.Errorf("could not save feedback: %w", err)
+	}
+	if score > s.feedbackThreshold {
+		return nil
+	}
+
+	reason := fmt.Sprintf("Scored %d of 5", score)
+	if feedback.Comment != "" {
+		reason += ": " + feedback.Comment
+	}
+	_, err := s.Flag(ctx, Flag{
+		Dialog:   dialog,
+		Message:  message,
+		Source:   domain.ModerationSourceLowFeedback,
+		Severity: domain.SeverityLow,
+		Reason:   reason,
+	})
+	return err
+}
+
+// Queue returns the items that match the filter, most urgent first.
+func (s *ModerationService) Queue(ctx context.Context, filter domain.ModerationFilter) ([]*domain.ModerationItem, error) {
+	return s.moderationRepo.List(ctx, filter)
+}
+
+// DialogItems returns the items of a dialog, most urgent first.
+func (s *ModerationService) DialogItems(ctx context.Context, dialogID int64) ([]*domain.ModerationItem, error) {
+	return s.moderationRepo.ListByDialog(ctx, dialogID)
+}
+
+// Item returns an item with its history.
+func (s *ModerationService) Item(ctx context.Context, id int64) (*domain.ModerationItem, error) {
+	item, err := s.moderationRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not find moderation item: %w", err)
+	}
+	if item == nil {
+		return nil, ErrModerationItemNotFound
+	}
+	return item, nil
+}
+
+// Assign gives an open item to an admin, or takes it back from its assignee when assigneeID is nil.
+func (s *ModerationService) Assign(ctx context.Context, id int64, actorID int64, assigneeID *int64) error {
+	item, err := s.openItem(ctx, id)
+	if err != nil {
+		return err
+	}
+	item.AssigneeID = assigneeID
+	event := &domain.ModerationEvent{ActorID: &actorID, Action: domain.ModerationActionUnassigned}
+	if assigneeID != nil {
+		event.Action = domain.ModerationActionAssigned
+		event.Note = fmt.Sprintf("user %d", *assigneeID)
+	}
+	return s.moderationRepo.Update(ctx, item, event)
+}
+
+// Annotate adds a note to an item.
+func (s *ModerationService) Annotate(ctx context.Context, id int64, actorID int64, note string) error {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return ErrEmptyNote
+	}
+	item, err := s.Item(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.moderationRepo.Update(ctx, item, &domain.ModerationEvent{ActorID: &actorID, Action: domain.ModerationActionNoted, Note: note})
+}
+
+// Resolve closes an open item with an optional note on what was done.
+func (s *ModerationService) Resolve(ctx context.Context, id int64, actorID int64, note string) error {
+	item, err := s.openItem(ctx, id)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	item.Status = domain.ModerationResolved
+	item.ResolvedAt = &now
+	return s.moderationRepo.Update(ctx, item, &domain.ModerationEvent{ActorID: &actorID, Action: domain.ModerationActionResolved, Note: strings.TrimSpace(note)})
+}
+
+// Reopen puts a resolved item back in the queue.
+func (s *ModerationService) Reopen(ctx context.Context, id int64, actorID int64, note string) error {
+	item, err := s.Item(ctx, id)
+	if err != nil {
+		return err
+	}
+	if item.Status != domain.ModerationResolved {
+		return ErrModerationItemOpen
+	}
+	item.Status = domain.ModerationOpen
+	item.ResolvedAt = nil
+	return s.moderationRepo.Update(ctx, item, &domain.ModerationEvent{ActorID: &actorID, Action: domain.ModerationActionReopened, Note: strings.TrimSpace(note)})
+}
+
+func (s *ModerationService) openItem(ctx context.Context, id int64) (*domain.ModerationItem, error) {
+	item, err := s.Item(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item.Status != domain.ModerationOpen {
+		return nil, ErrModerationItemResolved
+	}
+	return item, nil
+}
+
+// severityRank orders severities, the most urgent first.
+func severityRank(severity string) int {
+	if i := slices.Index(domain.Severities, severity); i >= 0 {
+		return i
+	}
+	return len(domain.Severities)
+}
+
+// truncateRunes shortens a text to at most n runes, marking the cut with an ellipsis.
+func truncateRunes(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n-1]) + "…"
+}
@@ -0,0 +1,126 @@
+// github.com/DauletBai/oilan.org/internal/app/services/persona.go
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownPersona is returned when a dialog is started with a persona that does not exist.
+var ErrUnknownPersona = errors.New("unknown persona")
+
+// PersonaRegistry holds all personas a dialog can be started with.
+type PersonaRegistry struct {
+	path      string
+	mu        sync.RWMutex
+	personas  []*domain.Persona
+	byID      map[string]*domain.Persona
+	defaultID string
+}
+
+// LoadPersonas reads the persona list and the prompt file of every persona.
+// The persona named in "default", or the first one, is used when a dialog does not choose one.
+func LoadPersonas(path string) (*PersonaRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read personas: %w", err)
+	}
+	var file struct {
+		Default  string            `json:"default"`
+		Personas []*domain.Persona `json:"personas"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse personas: %w", err)
+	}
+	if len(file.Personas) == 0 {
+		return nil, errors.New("no personas configured")
+	}
+
+	registry := &PersonaRegistry{path: path, byID: make(map[string]*domain.Persona), defaultID: file.Default}
+	for _, persona := range file.Personas {
+		if persona.ID == "" {
+			return nil, errors.New("every persona needs an id")
+		}
+		if _, exists := registry.byID[persona.ID]; exists {
+			return nil, fmt.Errorf("duplicate persona %q", persona.ID)
+		}
+		prompt, err := os.ReadFile(persona.PromptFile)
+		if err != nil {
+			return nil, fmt.Errorf("persona %q: failed to read system prompt: %w", persona.ID, err)
+		}
+		persona.SystemPrompt = strings.TrimSpace(string(prompt))
+		if err := ValidatePrompt(persona.SystemPrompt); err != nil {
+			return nil, fmt.Errorf("persona %q: %w", persona.ID, err)
+		}
+		registry.personas = append(registry.personas, persona)
+		registry.byID[persona.ID] = persona
+	}
+
+	if registry.defaultID == "" {
+		registry.defaultID = file.Personas[0].ID
+	}
+	if _, ok := registry.byID[registry.defaultID]; !ok {
+		return nil, fmt.Errorf("default persona %q is not configured", registry.defaultID)
+	}
+	return registry, nil
+}
+
+// Reload reads the persona list and the prompt files again and swaps them in. If anything
+// is invalid, the personas loaded before stay in use and the error is returned.
+func (r *PersonaRegistry) Reload() error {
+	loaded, err := LoadPersonas(r.path)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.personas, r.byID, r.defaultID = loaded.personas, loaded.byID, loaded.defaultID
+	return nil
+}
+
+// List returns all personas in the configured order.
+func (r *PersonaRegistry) List() []*domain.Persona {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.personas
+}
+
+// Get returns the persona with the given ID.
+func (r *PersonaRegistry) Get(id string) (*domain.Persona, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	persona, ok := r.byID[id]
+	return persona, ok
+}
+
+// Default returns the persona used when none is chosen.
+func (r *PersonaRegistry) Default() *domain.Persona {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.byID[r.defaultID]
+}
+
+// Resolve returns the persona of a dialog. Dialogs whose persona has since been removed
+// from the configuration continue with the default one.
+func (r *PersonaRegistry) Resolve(id string) *domain.Persona {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if persona, ok := r.byID[id]; ok {
+		return persona
+	}
+	return r.byID[r.defaultID]
+}
+
+// personaParams converts the generation settings of a persona.
+func personaParams(persona *domain.Persona) GenerationParams {
+	return GenerationParams{
+		Models:      persona.Models,
+		Temperature: persona.Temperature,
+		MaxTokens:   persona.MaxTokens,
+	}
+}
@@ -0,0 +1,193 @@
+// github.com/DauletBai/oilan.org/internal/app/services/phase.go
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// PhaseRegistry holds the guided session flows personas can lead.
+type PhaseRegistry struct {
+	path  string
+	mu    sync.RWMutex
+	flows map[string]*domain.Flow
+}
+
+// LoadPhases reads and validates the flows of guided sessions.
+func LoadPhases(path string) (*PhaseRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read phases: %w", err)
+	}
+	var file struct {
+		Flows []*domain.Flow `json:"flows"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse phases: %w", err)
+	}
+
+	registry := &PhaseRegistry{path: path, flows: make(map[string]*domain.Flow)}
+	for _, flow := range file.Flows {
+		if err := validateFlow(flow); err != nil {
+			return nil, err
+		}
+		if _, exists := registry.flows[flow.ID]; exists {
+			return nil, fmt.Errorf("duplicate flow %q", flow.ID)
+		}
+		registry.flows[flow.ID] = flow
+	}
+	return registry, nil
+}
+
+// validateFlow checks that a flow has phases and that every transition leads to one of them.
+func validateFlow(flow *domain.Flow) error {
+	if flow.ID == "" {
+		return errors.New("every flow needs an id")
+	}
+	if len(flow.Phases) == 0 {
+		return fmt.Errorf("flow %q has no phases", flow.ID)
+	}
+	ids := make(map[string]bool, len(flow.Phases))
+	for _, phase := range flow.Phases {
+		if !phaseIDPattern.MatchString(phase.ID) {
+			return fmt.Errorf("flow %q: invalid phase id %q", flow.ID, phase.ID)
+		}
+		if ids[phase.ID] {
+			return fmt.Errorf("flow %q: duplicate phase %q", flow.ID, phase.ID)
+		}
+		ids[phase.ID] = true
+	}
+	if flow.Initial == "" {
+		flow.Initial = flow.Phases[0].ID
+	}
+	if !ids[flow.Initial] {
+		return fmt.Errorf("flow %q: initial phase %q does not exist", flow.ID, flow.Initial)
+	}
+	for _, phase := range flow.Phases {
+		for _, next := range phase.Next {
+			if !ids[next] {
+				return fmt.Errorf("flow %q: phase %q leads to unknown phase %q", flow.ID, phase.ID, next)
+			}
+		}
+	}
+	return nil
+}
+
+// Reload reads the flows again and swaps them in. If anything is invalid, the flows loaded
+// before stay in use and the error is returned.
+func (r *PhaseRegistry) Reload() error {
+	loaded, err := LoadPhases(r.path)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flows = loaded.flows
+	return nil
+}
+
+// Flow returns the flow with the given ID.
+func (r *PhaseRegistry) Flow(id string) (*domain.Flow, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	flow, ok := r.flows[id]
+	return flow, ok
+}
+
+// findPhase returns the phase of a flow with the given ID.
+func findPhase(flow *domain.Flow, id string) *domain.Phase {
+	for _, phase := range flow.Phases {
+		if phase.ID == id {
+			return phase
+		}
+	}
+	return nil
+}
+
+// The AI asks for a phase transition by ending its reply with a marker such as [[phase:resolution]].
+// Markers are removed before a reply is shown or stored.
+var (
+	phaseIDPattern     = regexp.MustCompile(`^[a-z0-9_]+$`)
+	phaseMarkerPattern = regexp.MustCompile(`\[\[phase:\s*([a-z0-9_]+)\s*\]\]`)
+)
+
+const phaseMarkerPrefix = "[[phase:"
+
+// maxPhaseMarkerLen bounds how much text the stream filter holds back while waiting for a marker to end.
+const maxPhaseMarkerLen = 80
+
+// extractPhaseMarker removes all phase markers from a reply and returns the phase the last one names.
+func extractPhaseMarker(content string) (string, string) {
+	matches := phaseMarkerPattern.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return content, ""
+	}
+	return strings.TrimSpace(phaseMarkerPattern.ReplaceAllString(content, "")), matches[len(matches)-1][1]
+}
+
+// phaseMarkerFilter passes streamed chunks on without the phase markers they contain. Text that
+// may be the start of a marker is held back until it is clear whether it is one.
+type phaseMarkerFilter struct {
+	next    StreamHandler
+	pending string
+}
+
+func (f *phaseMarkerFilter) write(chunk string) error {
+	buf := f.pending + chunk
+	f.pending = ""
+
+	var out strings.Builder
+	for buf != "" {
+		start := strings.Index(buf, "[")
+		if start < 0 {
+			out.WriteString(buf)
+			break
+		}
+		out.WriteString(buf[:start])
+		buf = buf[start:]
+
+		// Not a marker if it stops matching the prefix.
+		if n := min(len(buf), len(phaseMarkerPrefix)); buf[:n] != phaseMarkerPrefix[:n] {
+			out.WriteByte('[')
+			buf = buf[1:]
+			continue
+		}
+		end := strings.Index(buf, "]]")
+		if end < 0 {
+			if len(buf) > maxPhaseMarkerLen {
+				out.WriteByte('[')
+				buf = buf[1:]
+				continue
+			}
+			f.pending = buf // Wait for more text
+			break
+		}
+		if marker := buf[:end+2]; phaseMarkerPattern.MatchString(marker) {
+			buf = buf[end+2:]
+			continue
+		}
+		out.WriteByte('[')
+		buf = buf[1:]
+	}
+
+	if out.Len() == 0 {
+		return nil
+	}
+	return f.next(out.String())
+}
+
+// flush passes on text that was held back but turned out not to be a marker.
+func (f *phaseMarkerFilter) flush() error {
+	if f.pending == "" {
+		return nil
+	}
+	pending := f.pending
+	f.pending = ""
+	return f.next(pending)
+}
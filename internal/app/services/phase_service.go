@@ -0,0 +1,185 @@
+// github.com/DauletBai/oilan.org/internal/app/services/phase_service.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoPhases is returned when a phase is changed in a dialog whose persona has no guided session.
+	ErrNoPhases = errors.New("dialog has no phases")
+	// ErrPhaseTransitionNotAllowed is returned when the rules of the flow do not allow a transition.
+	ErrPhaseTransitionNotAllowed = errors.New("phase transition not allowed")
+	// ErrPhaseChanged is returned when the phase of a dialog changed while a transition was being made.
+	ErrPhaseChanged = errors.New("phase changed concurrently")
+)
+
+// PhaseService runs the state machine of guided sessions: it knows the current phase of a
+// dialog, the guidance the AI gets in it, and which transitions are allowed.
+type PhaseService struct {
+	registry  *PhaseRegistry
+	personas  *PersonaRegistry
+	phaseRepo repository.PhaseRepository
+}
+
+// NewPhaseService creates a new PhaseService. Every flow a persona refers to must exist.
+func NewPhaseService(registry *PhaseRegistry, personas *PersonaRegistry, phaseRepo repository.PhaseRepository) (*PhaseService, error) {
+	for _, persona := range personas.List() {
+		if _, ok := registry.Flow(persona.Flow); persona.Flow != "" && !ok {
+			return nil, fmt.Errorf("persona %q uses unknown flow %q", persona.ID, persona.Flow)
+		}
+	}
+	return &PhaseService{registry: registry, personas: personas, phaseRepo: phaseRepo}, nil
+}
+
+// flow returns the flow the persona of a dialog leads, or nil if it has none.
+func (s *PhaseService) flow(persona *domain.Persona) *domain.Flow {
+	if persona.Flow == "" {
+		return nil
+	}
+	flow, ok := s.registry.Flow(persona.Flow)
+	if !ok {
+		log.Printf("Persona %s uses unknown flow %q; its dialogs run without phases", persona.ID, persona.Flow)
+		return nil
+	}
+	return flow
+}
+
+// InitialPhase returns the phase a new dialog with the persona starts in, or "" without phases.
+func (s *PhaseService) InitialPhase(persona *domain.Persona) string {
+	if flow := s.flow(persona); flow != nil {
+		return flow.Initial
+	}
+	return ""
+}
+
+// Current returns the flow of a dialog and the phase it is in. Dialogs started before their
+// persona had phases, or whose phase has since been removed, are in the initial phase.
+// Both are nil when the dialog has no phases.
+func (s *PhaseService) Current(dialog *domain.Dialog) (*domain.Flow, *domain.Phase) {
+	flow := s.flow(s.personas.Resolve(dialog.PersonaID))
+	if flow == nil {
+		return nil, nil
+	}
+	if phase := findPhase(flow, dialog.Phase); phase != nil {
+		return flow, phase
+	}
+	return flow, findPhase(flow, flow.Initial)
+}
+
+// Guidance returns the instructions for the current phase of a dialog, to be added to its system
+// prompt. They include the phases the AI may move on to and how to signal that, unless the rules
+// want the user to write more in this phase first.
+func (s *PhaseService) Guidance(ctx context.Context, dialog *domain.Dialog) (string, error) {
+	flow, phase := s.Current(dialog)
+	if flow == nil {
+		return "", nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Current stage of this session: %s.\n", phaseName(phase))
+	if phase.Guidance != "" {
+		b.WriteString(strings.TrimSpace(phase.Guidance) + "\n")
+	}
+
+	ready, err := s.mayAdvance(ctx, dialog, phase)
+	if err != nil {
+		return "", err
+	}
+	if !ready || len(phase.Next) == 0 {
+		b.WriteString("Stay in this stage for now.")
+		return b.String(), nil
+	}
+	b.WriteString("\nWhen the goal of this stage is reached and the user is ready, move on by ending your reply with a line that contains only the marker of the next stage:\n")
+	for _, id := range phase.Next {
+		fmt.Fprintf(&b, "- [[phase:%s]] for %s\n", id, phaseName(findPhase(flow, id)))
+	}
+	b.WriteString("Use at most one marker, only when you are sure, and never mention the stages or markers to the user.")
+	return b.String(), nil
+}
+
+// mayAdvance applies the min_user_messages rule: the user must have written enough messages
+// since the dialog entered the phase.
+func (s *PhaseService) mayAdvance(ctx context.Context, dialog *domain.Dialog, phase *domain.Phase) (bool, error) {
+	if phase.MinUserMessages <= 0 {
+		return true, nil
+	}
+	since := dialog.CreatedAt
+	last, err := s.phaseRepo.LastTransition(ctx, dialog.ID)
+	if err != nil {
+		return false, fmt.Errorf("could not load last phase transition: %w", err)
+	}
+	if last != nil {
+		since = last.CreatedAt
+	}
+	count := 0
+	for _, msg := range dialog.Messages {
+		if msg.Role == domain.RoleUser && !msg.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count >= phase.MinUserMessages, nil
+}
+
+// Advance moves a dialog to another phase if the flow allows it. The user may move on to any next
+// phase at any time; the AI only once the min_user_messages rule is met. On success the dialog's
+// Phase is updated.
+func (s *PhaseService) Advance(ctx context.Context, dialog *domain.Dialog, to string, trigger string, messageID *int64) (*domain.PhaseTransition, error) {
+	// 1. Check the transition against the rules of the flow.
+	flow, phase := s.Current(dialog)
+	if flow == nil {
+		return nil, ErrNoPhases
+	}
+	if !slices.Contains(phase.Next, to) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrPhaseTransitionNotAllowed, phase.ID, to)
+	}
+	if trigger == domain.PhaseTriggerLLM {
+		ready, err := s.mayAdvance(ctx, dialog, phase)
+		if err != nil {
+			return nil, err
+		}
+		if !ready {
+			return nil, fmt.Errorf("%w: %s needs %d user messages first", ErrPhaseTransitionNotAllowed, phase.ID, phase.MinUserMessages)
+		}
+	}
+
+	// 2. Store it, unless another transition got there first.
+	transition := &domain.PhaseTransition{
+		DialogID:  dialog.ID,
+		From:      phase.ID,
+		To:        to,
+		Trigger:   trigger,
+		MessageID: messageID,
+		CreatedAt: time.Now(),
+	}
+	ok, err := s.phaseRepo.Transition(ctx, transition, dialog.Phase)
+	if err != nil {
+		return nil, fmt.Errorf("could not change phase: %w", err)
+	}
+	if !ok {
+		return nil, ErrPhaseChanged
+	}
+	dialog.Phase = to
+	return transition, nil
+}
+
+// Transitions returns the phase history of a dialog, oldest first.
+func (s *PhaseService) Transitions(ctx context.Context, dialogID int64) ([]*domain.PhaseTransition, error) {
+	return s.phaseRepo.ListTransitions(ctx, dialogID)
+}
+
+// phaseName is the English name of a phase, used in prompts.
+func phaseName(phase *domain.Phase) string {
+	if name := phase.Name["en"]; name != "" {
+		return name
+	}
+	return phase.ID
+}
@@ -0,0 +1,85 @@
+// github.com/DauletBai/oilan.org/internal/app/services/phase_test.go
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractPhaseMarker(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantContent string
+		wantPhase   string
+	}{
+		{name: "no marker", content: "How did that feel?", wantContent: "How did that feel?"},
+		{name: "marker at the end", content: "Let us look at what helps.\n[[phase:resolution]]", wantContent: "Let us look at what helps.", wantPhase: "resolution"},
+		{name: "spaces inside the marker", content: "Thank you. [[phase: closing ]]", wantContent: "Thank you.", wantPhase: "closing"},
+		{name: "marker in the middle", content: "Before [[phase:exploration]] after", wantContent: "Before  after", wantPhase: "exploration"},
+		{name: "last marker wins", content: "[[phase:exploration]] Text [[phase:resolution]]", wantContent: "Text", wantPhase: "resolution"},
+		{name: "only a marker", content: "[[phase:closing]]", wantContent: "", wantPhase: "closing"},
+		{name: "upper case id", content: "Text [[phase:Resolution]]", wantContent: "Text [[phase:Resolution]]"},
+		{name: "hyphen in the id", content: "Text [[phase:next-step]]", wantContent: "Text [[phase:next-step]]"},
+		{name: "single brackets", content: "Text [phase:resolution]", wantContent: "Text [phase:resolution]"},
+		{name: "unterminated", content: "Text [[phase:resolution", wantContent: "Text [[phase:resolution"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, phase := extractPhaseMarker(tt.content)
+			if content != tt.wantContent || phase != tt.wantPhase {
+				t.Errorf("extractPhaseMarker(%q) = %q, %q, want %q, %q", tt.content, content, phase, tt.wantContent, tt.wantPhase)
+			}
+		})
+	}
+}
+
+func TestPhaseMarkerFilter(t *testing.T) {
+	longText := "[[phase:" + strings.Repeat("a", maxPhaseMarkerLen) + " and more"
+
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{name: "no marker", chunks: []string{"How did ", "that feel?"}, want: "How did that feel?"},
+		{name: "marker in one chunk", chunks: []string{"Let us look ahead. [[phase:resolution]]"}, want: "Let us look ahead. "},
+		{name: "marker split across chunks", chunks: []string{"Let us look ahead. [[pha", "se:resol", "ution]]"}, want: "Let us look ahead. "},
+		{name: "split between the brackets", chunks: []string{"Text [", "[phase:closing]] more"}, want: "Text  more"},
+		{name: "split before the closing brackets", chunks: []string{"Text [[phase:closing]", "]"}, want: "Text "},
+		{name: "one character per chunk", chunks: strings.Split("Ok [[phase:closing]]", ""), want: "Ok "},
+		{name: "two markers", chunks: []string{"[[phase:exploration]]A", "B[[phase:closing]]"}, want: "AB"},
+		{name: "text after the marker in the same chunk", chunks: []string{"A [[phase:closing]] B"}, want: "A  B"},
+		{name: "unterminated marker at the end of the stream", chunks: []string{"Text [[phase:res", "olution"}, want: "Text [[phase:resolution"},
+		{name: "opening bracket at the end of the stream", chunks: []string{"Text ["}, want: "Text ["},
+		{name: "malformed id", chunks: []string{"Text [[phase:Next-Step", "]] more"}, want: "Text [[phase:Next-Step]] more"},
+		{name: "other double brackets", chunks: []string{"See [[note]] and [link]"}, want: "See [[note]] and [link]"},
+		{name: "prefix that stops matching", chunks: []string{"[[ph", "oto]]"}, want: "[[photo]]"},
+		{name: "too long to be a marker", chunks: []string{longText[:40], longText[40:]}, want: longText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got strings.Builder
+			filter := &phaseMarkerFilter{next: func(chunk string) error {
+				if chunk == "" {
+					t.Error("empty chunk passed on")
+				}
+				got.WriteString(chunk)
+				return nil
+			}}
+			for _, chunk := range tt.chunks {
+				if err := filter.write(chunk); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			}
+			if err := filter.flush(); err != nil {
+				t.Fatalf("flush: %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("streamed %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
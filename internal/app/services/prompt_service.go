@@ -0,0 +1,270 @@
+// github.com/DauletBai/oilan.org/internal/app/services/prompt_service.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"log"
+	"strings"
+	"time"
+)
+
+// previousSummaryCount is the number of earlier dialogs whose summaries are available to prompts.
+const previousSummaryCount = 3
+
+var (
+	// ErrPromptVersionNotFound is returned when a prompt version does not exist or belongs to another persona.
+	ErrPromptVersionNotFound = errors.New("prompt version not found")
+	// ErrEmptyPrompt is returned when a prompt without any content is saved.
+	ErrEmptyPrompt = errors.New("prompt cannot be empty")
+	// ErrPromptUnchanged is returned when a saved prompt is identical to the latest version.
+	ErrPromptUnchanged = errors.New("prompt is identical to the latest version")
+)
+
+// PromptService manages the versioned system prompts of all personas.
+type PromptService struct {
+	promptRepo  repository.PromptRepository
+	personas    *PersonaRegistry
+	userRepo    repository.UserRepository
+	dialogRepo  repository.DialogRepository
+	summaryRepo repository.SummaryRepository
+}
+
+// NewPromptService creates a new PromptService. The user, dialog and summary repositories
+// provide the variables prompts are rendered with.
+func NewPromptService(promptRepo repository.PromptRepository, personas *PersonaRegistry, userRepo repository.UserRepository, dialogRepo repository.DialogRepository, summaryRepo repository.SummaryRepository) *PromptService {
+	return &PromptService{
+		promptRepo:  promptRepo,
+		personas:    personas,
+		userRepo:    userRepo,
+		dialogRepo:  dialogRepo,
+		summaryRepo: summaryRepo,
+	}
+}
+
+// Seed imports the prompt file of every persona that has no version in the database yet, and
+// activates the latest version of personas that have versions but none active.
+func (s *PromptService) Seed(ctx context.Context) error {
+	for _, persona := range s.personas.List() {
+		active, err := s.promptRepo.FindActive(ctx, persona.ID)
+		if err != nil {
+			return fmt.Errorf("could not load active prompt of %s: %w", persona.ID, err)
+		}
+		if active != nil {
+			continue
+		}
+
+		versions, err := s.promptRepo.ListVersions(ctx, persona.ID)
+		if err != nil {
+			return fmt.Errorf("could not load prompt versions of %s: %w", persona.ID, err)
+		}
+		var latest *domain.PromptVersion
+		if len(versions) > 0 {
+			latest = versions[0]
+		} else {
+			latest = &domain.PromptVersion{
+				PersonaID: persona.ID,
+				Content:   persona.SystemPrompt,
+				Note:      "Imported from " + persona.PromptFile,
+			}
+			if err := s.promptRepo.CreateVersion(ctx, latest); err != nil {
+				return fmt.Errorf("could not import prompt of %s: %w", persona.ID, err)
+			}
+			log.Printf("Imported the prompt of persona %s from %s", persona.ID, persona.PromptFile)
+		}
+		if err := s.promptRepo.Activate(ctx, persona.ID, latest.ID, nil); err != nil {
+			return fmt.Errorf("could not activate prompt of %s: %w", persona.ID, err)
+		}
+	}
+	return nil
+}
+
+// ActivePrompt returns the prompt version a persona currently uses. A persona without any stored
+// version (e.g. one added to the configuration while the server is running) uses its prompt file,
+// returned as a version with ID 0.
+func (s *PromptService) ActivePrompt(ctx context.Context, persona *domain.Persona) (*domain.PromptVersion, error) {
+	active, err := s.promptRepo.FindActive(ctx, persona.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load active prompt: %w", err)
+	}
+	if active == nil {
+		return &domain.PromptVersion{PersonaID: persona.ID, Content: persona.SystemPrompt}, nil
+	}
+	return active, nil
+}
+
+// Render renders a prompt version for the next turn of a dialog at the given time. Prompts are validated when they
+// are saved, so a failure here is unexpected; it is logged and the unrendered prompt is used
+// rather than failing the conversation.
+func (s *PromptService) Render(ctx context.Context, version *domain.PromptVersion, dialog *domain.Dialog, now time.Time) string {
+	vars, err := s.promptVars(ctx, dialog, now)
+	if err != nil {
+		log.Printf("Could not load prompt variables for dialog %d: %v", dialog.ID, err)
+	}
+	rendered, err := RenderPrompt(version.Content, vars)
+	if err != nil {
+		log.Printf("Could not render prompt version %d of %s: %v", version.ID, version.PersonaID, err)
+		return version.Content
+	}
+	return rendered
+}
+
+// promptVars collects the variables of a dialog. Variables that cannot be loaded keep their zero
+// value, so the returned vars are always usable.
+func (s *PromptService) promptVars(ctx context.Context, dialog *domain.Dialog, now time.Time) (PromptVars, error) {
+	// 1. The user's profile decides the language and the timezone.
+	vars := NewPromptVars("", "", now, dialog.CreatedAt)
+	user, err := s.userRepo.FindByID(ctx, dialog.UserID)
+	if err != nil {
+		return vars, fmt.Errorf("could not load user: %w", err)
+	}
+	if user != nil {
+		vars = NewPromptVars(user.Language, user.Timezone, now, dialog.CreatedAt)
+	}
+
+	// 2. Count the dialogs the user started before this one.
+	dialogs, err := s.dialogRepo.FindAllByUserID(ctx, dialog.UserID)
+	if err != nil {
+		return vars, fmt.Errorf("could not load dialogs: %w", err)
+	}
+	for _, d := range dialogs {
+		if d.ID < dialog.ID {
+			vars.PriorSessions++
+		}
+	}
+
+	// 3. Summarize what the most recent of them were about.
+	summaries, err := s.summaryRepo.FindRecentByUserID(ctx, dialog.UserID, dialog.ID, previousSummaryCount)
+	if err != nil {
+		return vars, fmt.Errorf("could not load previous summaries: %w", err)
+	}
+	parts := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		parts = append(parts, strings.TrimSpace(summary.Content))
+	}
+	vars.PreviousSummary = strings.Join(parts, "\n\n")
+	return vars, nil
+}
+
+// Versions returns all versions of a persona's prompt, newest first.
+func (s *PromptService) Versions(ctx context.Context, personaID string) ([]*domain.PromptVersion, error) {
+	return s.promptRepo.ListVersions(ctx, personaID)
+}
+
+// Version returns a single version of a persona's prompt.
+func (s *PromptService) Version(ctx context.Context, personaID string, versionID int64) (*domain.PromptVersion, error) {
+	version, err := s.promptRepo.FindVersion(ctx, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load prompt version: %w", err)
+	}
+	if version == nil || version.PersonaID != personaID {
+		return nil, ErrPromptVersionNotFound
+	}
+	return version, nil
+}
+
+// SaveVersion stores a new version of a persona's prompt and, if requested, activates it right away.
+func (s *PromptService) SaveVersion(ctx context.Context, personaID, content, note string, authorID int64, activate bool) (*domain.PromptVersion, error) {
+	// 1. Validate the new version.
+	if _, ok := s.personas.Get(personaID); !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPersona, personaID)
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, ErrEmptyPrompt
+	}
+	if err := ValidatePrompt(content); err != nil {
+		return nil, err
+	}
+	versions, err := s.promptRepo.ListVersions(ctx, personaID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load prompt versions: %w", err)
+	}
+	if len(versions) > 0 && versions[0].Content == content {
+		return nil, ErrPromptUnchanged
+	}
+
+	// 2. Store it.
+	version := &domain.PromptVersion{
+		PersonaID: personaID,
+		Content:   content,
+		AuthorID:  &authorID,
+		Note:      strings.TrimSpace(note),
+	}
+	if err := s.promptRepo.CreateVersion(ctx, version); err != nil {
+		return nil, fmt.Errorf("could not save prompt version: %w", err)
+	}
+
+	// 3. Activate it.
+	if activate {
+		if err := s.promptRepo.Activate(ctx, personaID, version.ID, &authorID); err != nil {
+			return nil, fmt.Errorf("could not activate prompt version: %w", err)
+		}
+	}
+	return version, nil
+}
+
+// Activate makes a version the active prompt of its persona; activating an older version is a rollback.
+func (s *PromptService) Activate(ctx context.Context, personaID string, versionID int64, userID int64) error {
+	if _, err := s.Version(ctx, personaID, versionID); err != nil {
+		return err
+	}
+	if err := s.promptRepo.Activate(ctx, personaID, versionID, &userID); err != nil {
+		return fmt.Errorf("could not activate prompt version: %w", err)
+	}
+	return nil
+}
+
+// DiffLine is one line of a line-based diff.
+type DiffLine struct {
+	Op   string // "+" for added, "-" for removed, " " for unchanged lines
+	Text string
+}
+
+// DiffLines compares two texts line by line using their longest common subsequence.
+func DiffLines(from, to string) []DiffLine {
+	a := strings.Split(from, "\n")
+	b := strings.Split(to, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	diff := make([]DiffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, DiffLine{Op: " ", Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, DiffLine{Op: "-", Text: a[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Op: "+", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, DiffLine{Op: "-", Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, DiffLine{Op: "+", Text: b[j]})
+	}
+	return diff
+}
@@ -0,0 +1,327 @@
+// github.com/DauletBai/oilan.org/internal/app/services/prompt_template.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	_ "time/tzdata" // The runtime image has no zoneinfo; without it every timezone would load as UTC
+)
+
+// ErrInvalidPromptTemplate is returned when a prompt is not a valid template or uses unknown variables.
+var ErrInvalidPromptTemplate = errors.New("invalid prompt template")
+
+// PromptVars are the variables available in system prompt templates, e.g. {{.Language}} or
+// {{if .PreviousSummary}}...{{end}}.
+type PromptVars struct {
+	Language        string // The user's preferred language code, e.g. "ru"; empty when unknown
+	LanguageName    string // The same language in English, e.g. "Russian"
+	Date            string // Current date in the user's timezone, e.g. "2025-03-14"
+	Weekday         string // Current weekday in the user's timezone, e.g. "Friday"
+	Time            string // Current time in the user's timezone, e.g. "18:30"
+	Timezone        string // The timezone the values above are given in
+	DialogAgeDays   int    // Full days since the dialog was started
+	PriorSessions   int    // Number of dialogs the user started before this one
+	PreviousSummary string // Summaries of the user's most recent earlier dialogs, if any
+}
+
+// languageNames are the names of the languages our users speak, as the model understands them best.
+var languageNames = map[string]string{
+	"kk": "Kazakh",
+	"ru": "Russian",
+	"en": "English",
+}
+
+// NewPromptVars fills in the time-dependent variables for a user in the given timezone.
+// An unknown or empty timezone falls back to UTC; an unknown one is logged.
+func NewPromptVars(language, timezone string, now time.Time, dialogStarted time.Time) PromptVars {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" {
+		if timezone != "" {
+			log.Printf("WARNING: rendering prompt in UTC instead of unknown timezone %q: %v", timezone, err)
+		}
+		loc, timezone = time.UTC, "UTC"
+	}
+	local := now.In(loc)
+	vars := PromptVars{
+		Language:     language,
+		LanguageName: languageNames[language],
+		Date:         local.Format("2006-01-02"),
+		Weekday:      local.Weekday().String(),
+		Time:         local.Format("15:04"),
+		Timezone:     timezone,
+	}
+	if !dialogStarted.IsZero() && now.After(dialogStarted) {
+		vars.DialogAgeDays = int(now.Sub(dialogStarted).Hours() / 24)
+	}
+	if vars.LanguageName == "" {
+		vars.LanguageName = language
+	}
+	return vars
+}
+
+// samplePromptVars are the variables ValidatePrompt runs a prompt with: one set with every variable
+// filled in and one for a first dialog of a user without a profile, in each of our languages, so that
+// the usual conditionals are executed in both directions.
+func samplePromptVars() []PromptVars {
+	now := time.Now()
+	var samples []PromptVars
+	for _, language := range []string{"ru", "kk", "en"} {
+		full := NewPromptVars(language, "Asia/Almaty", now, now.AddDate(0, 0, -3))
+		full.PriorSessions = 2
+		full.PreviousSummary = "The user talked about trouble sleeping since a conflict at work."
+		samples = append(samples, full, NewPromptVars(language, "", now, now))
+	}
+	return append(samples, NewPromptVars("", "", now, time.Time{}))
+}
+
+// parsePrompt parses a prompt template.
+func parsePrompt(content string) (*template.Template, error) {
+	return template.New("prompt").Option("missingkey=error").Parse(content)
+}
+
+// ValidatePrompt checks that a prompt is a valid template that only uses known variables. Fields are
+// checked in every branch of the template, also in the ones no sample variables take; the samples
+// then catch pipelines that fail when they are executed.
+func ValidatePrompt(content string) error {
+	tpl, err := parsePrompt(content)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+	}
+	for _, t := range tpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		// Templates other than the prompt itself can be called with any data.
+		var dot reflect.Type
+		if t.Name() == tpl.Name() {
+			dot = reflect.TypeOf(PromptVars{})
+		}
+		checker := &promptChecker{tree: t.Tree, vars: map[string]reflect.Type{"$": dot}}
+		if err := checker.list(t.Tree.Root, dot); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+		}
+	}
+	for _, vars := range samplePromptVars() {
+		if err := tpl.Execute(new(strings.Builder), vars); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+		}
+	}
+	return nil
+}
+
+// promptChecker follows the type of dot and of the variables through a template and reports fields
+// that do not exist. A nil type is one that cannot be known without executing the template; fields
+// of it are not checked.
+type promptChecker struct {
+	tree *parse.Tree
+	vars map[string]reflect.Type
+}
+
+func (c *promptChecker) list(list *parse.ListNode, dot reflect.Type) error {
+	if list == nil {
+		return nil
+	}
+	for _, node := range list.Nodes {
+		if err := c.node(node, dot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *promptChecker) node(node parse.Node, dot reflect.Type) error {
+	switch n := node.(type) {
+	case *parse.ActionNode:
+		_, err := c.pipe(n.Pipe, dot)
+		return err
+	case *parse.TemplateNode:
+		if n.Pipe == nil {
+			return nil
+		}
+		_, err := c.pipe(n.Pipe, dot)
+		return err
+	case *parse.IfNode:
+		return c.branch(&n.BranchNode, dot, func(reflect.Type) reflect.Type { return dot })
+	case *parse.WithNode:
+		return c.branch(&n.BranchNode, dot, func(typ reflect.Type) reflect.Type { return typ })
+	case *parse.RangeNode:
+		return c.branch(&n.BranchNode, dot, rangeElem)
+	case *parse.ListNode:
+		return c.list(n, dot)
+	}
+	return nil
+}
+
+// branch checks an if, with or range node; body returns the type of dot inside the branch for the
+// type of the pipeline. Variables declared inside the branch end with it.
+func (c *promptChecker) branch(b *parse.BranchNode, dot reflect.Type, body func(reflect.Type) reflect.Type) error {
+	outer := maps.Clone(c.vars)
+	defer func() { c.vars = outer }()
+
+	typ, err := c.pipe(b.Pipe, dot)
+	if err != nil {
+		return err
+	}
+	if b.NodeType == parse.NodeRange && len(b.Pipe.Decl) > 0 {
+		// {{range $elem := ...}} or {{range $key, $elem := ...}}
+		c.vars[b.Pipe.Decl[len(b.Pipe.Decl)-1].Ident[0]] = rangeElem(typ)
+		if len(b.Pipe.Decl) == 2 {
+			c.vars[b.Pipe.Decl[0].Ident[0]] = rangeKey(typ)
+		}
+	}
+	scope := maps.Clone(c.vars) // Variables of the pipeline are visible in the else branch as well
+	if err := c.list(b.List, body(typ)); err != nil {
+		return err
+	}
+	c.vars = scope
+	return c.list(b.ElseList, dot)
+}
+
+// pipe checks a pipeline and returns the type of its result.
+func (c *promptChecker) pipe(pipe *parse.PipeNode, dot reflect.Type) (reflect.Type, error) {
+	var typ reflect.Type
+	for _, cmd := range pipe.Cmds {
+		var err error
+		if typ, err = c.command(cmd, dot); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range pipe.Decl {
+		c.vars[v.Ident[0]] = typ
+	}
+	return typ, nil
+}
+
+func (c *promptChecker) command(cmd *parse.CommandNode, dot reflect.Type) (reflect.Type, error) {
+	var typ reflect.Type
+	for i, arg := range cmd.Args {
+		argType, err := c.arg(arg, dot)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			typ = argType
+		}
+	}
+	return typ, nil
+}
+
+func (c *promptChecker) arg(arg parse.Node, dot reflect.Type) (reflect.Type, error) {
+	switch n := arg.(type) {
+	case *parse.DotNode:
+		return dot, nil
+	case *parse.FieldNode:
+		return c.field(n, dot, n.Ident)
+	case *parse.VariableNode:
+		return c.field(n, c.vars[n.Ident[0]], n.Ident[1:])
+	case *parse.ChainNode:
+		typ, err := c.arg(n.Node, dot)
+		if err != nil {
+			return nil, err
+		}
+		return c.field(n, typ, n.Field)
+	case *parse.PipeNode:
+		return c.pipe(n, dot)
+	case *parse.IdentifierNode:
+		return builtinResults[n.Ident], nil
+	case *parse.StringNode:
+		return reflect.TypeOf(""), nil
+	case *parse.BoolNode:
+		return reflect.TypeOf(false), nil
+	}
+	return nil, nil
+}
+
+// field follows a chain of field names from a value of the given type.
+func (c *promptChecker) field(node parse.Node, typ reflect.Type, names []string) (reflect.Type, error) {
+	for _, name := range names {
+		if typ == nil {
+			return nil, nil
+		}
+		if method, ok := typ.MethodByName(name); ok {
+			if method.Type.NumOut() == 0 {
+				return nil, nil
+			}
+			typ = method.Type.Out(0)
+			continue
+		}
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		switch typ.Kind() {
+		case reflect.Struct:
+			if f, ok := typ.FieldByName(name); ok && f.IsExported() {
+				typ = f.Type
+				continue
+			}
+		case reflect.Map:
+			typ = typ.Elem()
+			continue
+		case reflect.Interface:
+			return nil, nil
+		}
+		location, _ := c.tree.ErrorContext(node)
+		return nil, fmt.Errorf("%s: can't evaluate field %s in type %s", location, name, typ)
+	}
+	return typ, nil
+}
+
+// builtinResults are the result types of the template functions that always return the same type.
+var builtinResults = map[string]reflect.Type{
+	"eq":       reflect.TypeOf(false),
+	"ne":       reflect.TypeOf(false),
+	"lt":       reflect.TypeOf(false),
+	"le":       reflect.TypeOf(false),
+	"gt":       reflect.TypeOf(false),
+	"ge":       reflect.TypeOf(false),
+	"not":      reflect.TypeOf(false),
+	"len":      reflect.TypeOf(0),
+	"print":    reflect.TypeOf(""),
+	"printf":   reflect.TypeOf(""),
+	"println":  reflect.TypeOf(""),
+	"html":     reflect.TypeOf(""),
+	"js":       reflect.TypeOf(""),
+	"urlquery": reflect.TypeOf(""),
+}
+
+// rangeElem returns the type of dot inside {{range}} over a value of the given type.
+func rangeElem(typ reflect.Type) reflect.Type {
+	if typ == nil {
+		return nil
+	}
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return typ.Elem()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return typ
+	}
+	return nil
+}
+
+// rangeKey returns the type of the key variable of {{range $key, $elem := ...}}.
+func rangeKey(typ reflect.Type) reflect.Type {
+	if typ != nil && typ.Kind() == reflect.Map {
+		return typ.Key()
+	}
+	return reflect.TypeOf(0)
+}
+
+// RenderPrompt renders a prompt template with the variables of the current request.
+func RenderPrompt(content string, vars PromptVars) (string, error) {
+	tpl, err := parsePrompt(content)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tpl.Execute(&out, vars); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
@@ -0,0 +1,98 @@
+// github.com/DauletBai/oilan.org/internal/app/services/prompt_template_test.go
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewPromptVars(t *testing.T) {
+	now := time.Date(2025, time.March, 14, 20, 30, 0, 0, time.UTC)
+	tests := []struct {
+		timezone     string
+		wantTimezone string
+		wantDate     string
+		wantTime     string
+		wantWeekday  string
+	}{
+		{timezone: "Asia/Tokyo", wantTimezone: "Asia/Tokyo", wantDate: "2025-03-15", wantTime: "05:30", wantWeekday: "Saturday"},
+		{timezone: "Europe/Moscow", wantTimezone: "Europe/Moscow", wantDate: "2025-03-14", wantTime: "23:30", wantWeekday: "Friday"},
+		{timezone: "America/New_York", wantTimezone: "America/New_York", wantDate: "2025-03-14", wantTime: "16:30", wantWeekday: "Friday"},
+		{timezone: "", wantTimezone: "UTC", wantDate: "2025-03-14", wantTime: "20:30", wantWeekday: "Friday"},
+		{timezone: "Mars/Olympus_Mons", wantTimezone: "UTC", wantDate: "2025-03-14", wantTime: "20:30", wantWeekday: "Friday"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timezone, func(t *testing.T) {
+			vars := NewPromptVars("kk", tt.timezone, now, now.AddDate(0, 0, -3).Add(time.Hour))
+			if vars.Timezone != tt.wantTimezone || vars.Date != tt.wantDate || vars.Time != tt.wantTime || vars.Weekday != tt.wantWeekday {
+				t.Errorf("NewPromptVars() = %s %s %s %s, want %s %s %s %s", vars.Timezone, vars.Date, vars.Time, vars.Weekday, tt.wantTimezone, tt.wantDate, tt.wantTime, tt.wantWeekday)
+			}
+			if vars.LanguageName != "Kazakh" || vars.DialogAgeDays != 2 {
+				t.Errorf("language name %q and dialog age %d, want Kazakh and 2", vars.LanguageName, vars.DialogAgeDays)
+			}
+		})
+	}
+}
+
+func TestValidatePrompt(t *testing.T) {
+	tests := []struct {
+		name    string
+		prompt  string
+		wantErr string // Part of the error; empty for a valid prompt
+	}{
+		{name: "plain text", prompt: "You are a calm guide."},
+		{name: "variables", prompt: "Answer in {{.LanguageName}}. It is {{.Weekday}}, {{.Date}} {{.Time}} ({{.Timezone}})."},
+		{name: "conditionals", prompt: `{{if eq .Language "kk"}}Сәлем{{else if .PreviousSummary}}Last time: {{.PreviousSummary}}{{else}}Hello{{end}}`},
+		{name: "with", prompt: "{{with .PreviousSummary}}Earlier: {{.}} ({{len .}} characters, day {{$.DialogAgeDays}}){{end}}"},
+		{name: "range over a number", prompt: "{{range $i := .PriorSessions}}{{$i}} {{end}}"},
+		{name: "variable", prompt: "{{$lang := .LanguageName}}{{if $lang}}{{$lang}}{{end}}"},
+		{name: "pipeline", prompt: `{{.Language | printf "%q"}} {{(.Timezone)}}`},
+		{name: "syntax error", prompt: "{{if .Language}}unclosed", wantErr: "unexpected EOF"},
+		{name: "unknown field", prompt: "Hello {{.Name}}", wantErr: "can't evaluate field Name"},
+		{name: "unknown field in an if the samples do not take", prompt: `{{if eq .Language "de"}}Hallo {{.Name}}{{end}}`, wantErr: "can't evaluate field Name"},
+		{name: "unknown field in else", prompt: `{{if true}}ok{{else}}{{.Nmae}}{{end}}`, wantErr: "can't evaluate field Nmae"},
+		{name: "unknown field in a nested if", prompt: `{{if gt .PriorSessions 100}}{{if .PreviousSummary}}{{.Summary}}{{end}}{{end}}`, wantErr: "can't evaluate field Summary"},
+		{name: "field of a string inside with", prompt: "{{with .PreviousSummary}}{{.Text}}{{end}}", wantErr: "can't evaluate field Text in type string"},
+		{name: "field of a number inside range", prompt: "{{range .PriorSessions}}{{.Date}}{{end}}", wantErr: "can't evaluate field Date in type int"},
+		{name: "unknown field through the root variable", prompt: "{{if false}}{{$.User}}{{end}}", wantErr: "can't evaluate field User"},
+		{name: "field of a variable", prompt: "{{$d := .Date}}{{if false}}{{$d.Year}}{{end}}", wantErr: "can't evaluate field Year in type string"},
+		{name: "field of a field", prompt: "{{if false}}{{.Language.Code}}{{end}}", wantErr: "can't evaluate field Code"},
+		{name: "bad pipeline for another language", prompt: `{{if eq .Language "en"}}{{len .PriorSessions}}{{end}}`, wantErr: "len of type int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePrompt(tt.prompt)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidatePrompt() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !errors.Is(err, ErrInvalidPromptTemplate) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidatePrompt() = %v, want ErrInvalidPromptTemplate containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePromptAcceptsShippedPrompts(t *testing.T) {
+	paths, err := filepath.Glob("../../../configs/prompt_*.txt")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no prompts found: %v", err)
+	}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidatePrompt(string(content)); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
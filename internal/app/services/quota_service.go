@@ -0,0 +1,223 @@
+// github.com/DauletBai/oilan.org/internal/app/services/quota_service.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is returned when a user has used up one of their limits.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Names of the limits reported in QuotaExceededError.Limit.
+const (
+	LimitMessagesPerDay        = "messages_per_day"
+	LimitTokensPerMonth        = "tokens_per_month"
+	LimitConcurrentGenerations = "concurrent_generations"
+)
+
+// generationSlotTTL bounds how long a concurrency slot is held if its instance dies before
+// releasing it. It is well above the longest generation the providers allow.
+const generationSlotTTL = 15 * time.Minute
+
+// QuotaExceededError tells which limit was hit and when it resets.
+type QuotaExceededError struct {
+	Limit   string
+	Max     int
+	ResetAt time.Time // Zero for the concurrency limit, which frees up as soon as a reply is done
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded: %s (limit %d)", e.Limit, e.Max)
+}
+
+// Unwrap lets callers match any quota error with errors.Is(err, ErrQuotaExceeded).
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaUsage is a user's effective quota together with what they have used so far.
+type QuotaUsage struct {
+	Quota             domain.Quota
+	Overridden        bool // The quota was set by an admin instead of coming from the role
+	MessagesToday     int
+	TokensThisMonth   int
+	ActiveGenerations int
+}
+
+// QuotaService enforces per-role and per-user usage limits.
+type QuotaService struct {
+	quotaRepo  repository.QuotaRepository
+	userRepo   repository.UserRepository
+	roleQuotas map[string]domain.Quota
+
+	mu sync.Mutex // Guards roleQuotas, which can be swapped while the server runs
+}
+
+// NewQuotaService creates a new QuotaService. Roles missing from roleQuotas are unlimited.
+func NewQuotaService(quotaRepo repository.QuotaRepository, userRepo repository.UserRepository, roleQuotas map[string]domain.Quota) *QuotaService {
+	return &QuotaService{
+		quotaRepo:  quotaRepo,
+		userRepo:   userRepo,
+		roleQuotas: roleQuotas,
+	}
+}
+
+// LoadRoleQuotas reads the default quota of every role from a JSON file.
+func LoadRoleQuotas(path string) (map[string]domain.Quota, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quotas: %w", err)
+	}
+	var quotas map[string]domain.Quota
+	if err := json.Unmarshal(data, &quotas); err != nil {
+		return nil, fmt.Errorf("failed to parse quotas: %w", err)
+	}
+	return quotas, nil
+}
+
+// RoleQuota returns the default quota of a role.
+func (s *QuotaService) RoleQuota(role string) domain.Quota {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.roleQuotas[role]
+}
+
+// SetRoleQuotas replaces the default quotas of all roles, e.g. after the quota file has changed.
+func (s *QuotaService) SetRoleQuotas(roleQuotas map[string]domain.Quota) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roleQuotas = roleQuotas
+}
+
+// EffectiveQuota returns the admin override of a user if there is one, or their role's default.
+func (s *QuotaService) EffectiveQuota(ctx context.Context, user *domain.User) (domain.Quota, bool, error) {
+	override, err := s.quotaRepo.FindOverride(ctx, user.ID)
+	if err != nil {
+		return domain.Quota{}, false, fmt.Errorf("could not load quota override: %w", err)
+	}
+	if override != nil {
+		return *override, true, nil
+	}
+	return s.RoleQuota(user.Role), false, nil
+}
+
+// Usage reports a user's effective quota and current usage.
+func (s *QuotaService) Usage(ctx context.Context, user *domain.User) (*QuotaUsage, error) {
+	quota, overridden, err := s.EffectiveQuota(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	messages, err := s.quotaRepo.CountUserMessagesSince(ctx, user.ID, startOfDay(now))
+	if err != nil {
+		return nil, fmt.Errorf("could not count messages: %w", err)
+	}
+	tokens, err := s.quotaRepo.SumTokensSince(ctx, user.ID, startOfMonth(now))
+	if err != nil {
+		return nil, fmt.Errorf("could not sum tokens: %w", err)
+	}
+	active, err := s.quotaRepo.CountGenerationSlots(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not count generations: %w", err)
+	}
+
+	return &QuotaUsage{
+		Quota:             quota,
+		Overridden:        overridden,
+		MessagesToday:     messages,
+		TokensThisMonth:   tokens,
+		ActiveGenerations: active,
+	}, nil
+}
+
+// SetOverride stores an admin-defined quota for a user.
+func (s *QuotaService) SetOverride(ctx context.Context, userID int64, quota domain.Quota) error {
+	return s.quotaRepo.SaveOverride(ctx, userID, &quota)
+}
+
+// ResetOverride makes the role default apply to the user again.
+func (s *QuotaService) ResetOverride(ctx context.Context, userID int64) error {
+	return s.quotaRepo.DeleteOverride(ctx, userID)
+}
+
+// Acquire checks all limits of a user before a new generation and reserves a concurrency slot.
+// The slot is kept in the store, so the limit holds across all server instances.
+// The returned release function must be called once the generation is finished.
+func (s *QuotaService) Acquire(ctx context.Context, userID int64) (release func(), err error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not find user: %w", err)
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	usage, err := s.Usage(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	quota := usage.Quota
+	now := time.Now().UTC()
+
+	if quota.MessagesPerDay > 0 && usage.MessagesToday >= quota.MessagesPerDay {
+		return nil, &QuotaExceededError{Limit: LimitMessagesPerDay, Max: quota.MessagesPerDay, ResetAt: startOfDay(now).AddDate(0, 0, 1)}
+	}
+	if quota.TokensPerMonth > 0 && usage.TokensThisMonth >= quota.TokensPerMonth {
+		return nil, &QuotaExceededError{Limit: LimitTokensPerMonth, Max: quota.TokensPerMonth, ResetAt: startOfMonth(now).AddDate(0, 1, 0)}
+	}
+
+	// The store checks the concurrency limit and takes the slot in one step, so two requests cannot both slip through.
+	slotID, ok, err := s.quotaRepo.AcquireGenerationSlot(ctx, userID, quota.ConcurrentGenerations, generationSlotTTL)
+	if err != nil {
+		return nil, fmt.Errorf("could not reserve generation slot: %w", err)
+	}
+	if !ok {
+		return nil, &QuotaExceededError{Limit: LimitConcurrentGenerations, Max: quota.ConcurrentGenerations}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			// The request may already be cancelled, but the slot has to be freed anyway.
+			if err := s.quotaRepo.ReleaseGenerationSlot(context.Background(), slotID); err != nil {
+				log.Printf("Could not release generation slot %d of user %d: %v", slotID, userID, err)
+			}
+		})
+	}, nil
+}
+
+// ChargeTokens counts tokens the model spent on a user's behalf outside of a stored answer, e.g. for a
+// summary of their dialog, against the user's monthly token quota.
+func (s *QuotaService) ChargeTokens(ctx context.Context, userID, dialogID int64, purpose string, resp *LLMResponse) error {
+	if resp.PromptTokens == 0 && resp.CompletionTokens == 0 {
+		return nil
+	}
+	usage := &domain.TokenUsage{
+		UserID:           userID,
+		DialogID:         dialogID,
+		Purpose:          purpose,
+		PromptTokens:     resp.PromptTokens,
+		CompletionTokens: resp.CompletionTokens,
+	}
+	if err := s.quotaRepo.RecordTokenUsage(ctx, usage); err != nil {
+		return fmt.Errorf("could not record token usage: %w", err)
+	}
+	return nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,124 @@
+// github.com/DauletBai/oilan.org/internal/app/services/rate_limiter.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Rate limit policies the routes refer to.
+const (
+	RateLimitAuth      = "auth"       // Sign-in routes, per IP
+	RateLimitAPI       = "api"        // Every API call, per user
+	RateLimitMessages  = "messages"   // Chat messages over the API and the WebSocket, per user
+	RateLimitWebSocket = "ws_connect" // Opening a chat WebSocket, per user
+)
+
+// rateLimitPruneInterval is how often buckets that filled up again are removed from the store.
+const rateLimitPruneInterval = 5 * time.Minute
+
+// RateLimiter limits request rates with token buckets, one per policy and client.
+type RateLimiter struct {
+	store repository.RateLimitStore
+	path  string
+
+	mu       sync.RWMutex
+	policies map[string]domain.RateLimitPolicy
+}
+
+// LoadRateLimits reads the rate limit policies, keyed by name. A policy missing from the file
+// does not limit anything.
+func LoadRateLimits(path string, store repository.RateLimitStore) (*RateLimiter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rate limits: %w", err)
+	}
+	var file struct {
+		Policies map[string]domain.RateLimitPolicy `json:"policies"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rate limits: %w", err)
+	}
+	for name, policy := range file.Policies {
+		if policy.PerMinute < 0 {
+			return nil, fmt.Errorf("rate limit %q has a negative per_minute", name)
+		}
+		if !policy.Unlimited() && policy.Burst < 1 {
+			return nil, fmt.Errorf("rate limit %q needs a burst of 1 or more", name)
+		}
+	}
+	return &RateLimiter{store: store, path: path, policies: file.Policies}, nil
+}
+
+// Reload reads the policies again and swaps them in. If the file is invalid, the policies loaded
+// before stay in use and the error is returned. Buckets keep their tokens.
+func (l *RateLimiter) Reload() error {
+	loaded, err := LoadRateLimits(l.path, l.store)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.policies = loaded.policies
+	return nil
+}
+
+// Policy returns the policy with the given name.
+func (l *RateLimiter) Policy(name string) domain.RateLimitPolicy {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.policies[name]
+}
+
+// Allow takes a token from the client's bucket of a policy. When the bucket is empty, it reports
+// false and how long the client has to wait. The client is identified by key, e.g. "user:42".
+func (l *RateLimiter) Allow(ctx context.Context, policyName, key string) (bool, time.Duration, error) {
+	policy := l.Policy(policyName)
+	if policy.Unlimited() {
+		return true, 0, nil
+	}
+	allowed, retryAfter, err := l.store.Take(ctx, policyName+":"+key, policy)
+	if err != nil {
+		return false, 0, fmt.Errorf("could not take rate limit token: %w", err)
+	}
+	return allowed, retryAfter, nil
+}
+
+// StartPruning removes the buckets that filled up again from the store until ctx is done. A full
+// bucket is the same as none, so this only keeps the store small.
+func (l *RateLimiter) StartPruning(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(rateLimitPruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := l.store.Prune(ctx, l.longestRefill()); err != nil {
+					log.Printf("Error pruning rate limit buckets: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// longestRefill returns the time the slowest policy takes to fill an empty bucket.
+func (l *RateLimiter) longestRefill() time.Duration {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	longest := time.Minute
+	for _, policy := range l.policies {
+		if refill := policy.RefillTime(); refill > longest {
+			longest = refill
+		}
+	}
+	return longest
+}
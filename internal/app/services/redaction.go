@@ -0,0 +1,454 @@
+// github.com/DauletBai/oilan.org/internal/app/services/redaction.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// placeholderPattern matches the placeholders that stand in for redacted values, e.g. [PERSON_1].
+var placeholderPattern = regexp.MustCompile(`\[([A-Z][A-Z_]*)_([0-9]+)\]`)
+
+// placeholderPrefix matches text that may still become a placeholder as more chunks arrive.
+var placeholderPrefix = regexp.MustCompile(`^\[[A-Z_]*[0-9]*$`)
+
+// maxPlaceholderLen bounds how much streamed text is held back while waiting for a placeholder to end.
+const maxPlaceholderLen = 40
+
+var detectorLabelPattern = regexp.MustCompile(`^[A-Z][A-Z_]*$`)
+
+// piiValidators check a match beyond its pattern, e.g. the checksum of an ID number.
+var piiValidators = map[string]func(string) bool{
+	"kz_iin": validKazakhIIN,
+}
+
+// piiNormalizers decide which matches are the same value and so share a placeholder.
+var piiNormalizers = map[string]func(string) string{
+	"":       normalizePIIText,
+	"text":   normalizePIIText,
+	"digits": onlyDigits,
+	"phone":  normalizePhone,
+}
+
+// piiDetector finds one kind of personal data.
+type piiDetector struct {
+	label     string
+	pattern   *regexp.Regexp
+	group     int // Index of the "pii" group, or 0 to replace the whole match
+	validate  func(string) bool
+	normalize func(string) string
+}
+
+// Redactor replaces personal data, such as names, phone numbers, emails, addresses and national ID
+// numbers, with placeholders before text is sent to an LLM provider. The detectors are patterns
+// read from a file, so that new kinds of data can be covered without a release.
+type Redactor struct {
+	path      string
+	mu        sync.RWMutex
+	detectors []piiDetector
+}
+
+// LoadRedactor reads the detectors from a file.
+func LoadRedactor(path string) (*Redactor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction config: %w", err)
+	}
+	var file struct {
+		Detectors []struct {
+			Label     string `json:"label"`     // Placeholders are [LABEL_1], [LABEL_2], ...
+			Pattern   string `json:"pattern"`   // A group named "pii" narrows what is replaced
+			Validator string `json:"validator"` // Optional, see piiValidators
+			Normalize string `json:"normalize"` // Optional, see piiNormalizers
+		} `json:"detectors"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse redaction config: %w", err)
+	}
+	if len(file.Detectors) == 0 {
+		return nil, errors.New("no redaction detectors configured")
+	}
+
+	redactor := &Redactor{path: path}
+	for i, cfg := range file.Detectors {
+		if !detectorLabelPattern.MatchString(cfg.Label) {
+			return nil, fmt.Errorf("redaction detector #%d: label %q must be upper case letters and underscores", i+1, cfg.Label)
+		}
+		pattern, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction detector %s: %w", cfg.Label, err)
+		}
+		detector := piiDetector{label: cfg.Label, pattern: pattern}
+		if i := pattern.SubexpIndex("pii"); i > 0 {
+			detector.group = i
+		}
+		if cfg.Validator != "" {
+			if detector.validate = piiValidators[cfg.Validator]; detector.validate == nil {
+				return nil, fmt.Errorf("redaction detector %s: unknown validator %q", cfg.Label, cfg.Validator)
+			}
+		}
+		if detector.normalize = piiNormalizers[cfg.Normalize]; detector.normalize == nil {
+			return nil, fmt.Errorf("redaction detector %s: unknown normalization %q", cfg.Label, cfg.Normalize)
+		}
+		redactor.detectors = append(redactor.detectors, detector)
+	}
+	return redactor, nil
+}
+
+// Reload reads the detectors again and swaps them in. If the file is invalid, the detectors loaded
+// before stay in use and the error is returned.
+func (r *Redactor) Reload() error {
+	loaded, err := LoadRedactor(r.path)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.detectors = loaded.detectors
+	return nil
+}
+
+// Count returns how many values in a text would be redacted.
+func (r *Redactor) Count(text string) int {
+	r.mu.RLock()
+	detectors := r.detectors
+	r.mu.RUnlock()
+	return len(findPII(detectors, text))
+}
+
+// NewSession starts the redaction of one request to the provider. All text redacted in a session
+// shares its placeholders, so the same value gets the same placeholder throughout a conversation.
+func (r *Redactor) NewSession() *Redaction {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return &Redaction{
+		detectors:    r.detectors,
+		placeholders: make(map[string]string),
+		values:       make(map[string]string),
+		counters:     make(map[string]int),
+	}
+}
+
+// Redaction holds the placeholders of one request, so that they can be restored in the reply.
+type Redaction struct {
+	detectors    []piiDetector
+	placeholders map[string]string // Placeholder per label and normalized value
+	values       map[string]string // Original value per placeholder, as first seen
+	counters     map[string]int    // Placeholders handed out per label
+	known        []knownValue      // Every form in which a value with a placeholder was seen
+}
+
+// knownValue is a value that already has a placeholder. It is replaced wherever it occurs again,
+// also where no detector would find it, e.g. a name mentioned without the phrase that introduced it.
+type knownValue struct {
+	pattern     *regexp.Regexp
+	placeholder string
+}
+
+// piiMatch is a value found in a text, either by a detector or as a known value.
+type piiMatch struct {
+	start, end  int
+	detector    *piiDetector
+	placeholder string // Set for known values
+}
+
+// findPII returns the values the detectors find in a text, in order and without overlaps.
+// Where matches overlap, the one that starts first wins, and the longer one if both start together.
+func findPII(detectors []piiDetector, text string) []piiMatch {
+	var matches []piiMatch
+	for i := range detectors {
+		detector := &detectors[i]
+		for _, loc := range detector.pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[2*detector.group], loc[2*detector.group+1]
+			if start < 0 || start == end {
+				continue
+			}
+			if detector.validate != nil && !detector.validate(text[start:end]) {
+				continue
+			}
+			matches = append(matches, piiMatch{start: start, end: end, detector: detector})
+		}
+	}
+	return dropOverlaps(matches)
+}
+
+// dropOverlaps sorts matches by position and keeps one of every group of overlapping matches.
+func dropOverlaps(matches []piiMatch) []piiMatch {
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].end > matches[j].end
+	})
+
+	kept := matches[:0]
+	end := 0
+	for _, match := range matches {
+		if match.start < end {
+			continue
+		}
+		kept = append(kept, match)
+		end = match.end
+	}
+	return kept
+}
+
+// Redact replaces the personal data in a text with placeholders. Values the session has seen
+// before are replaced as well, wherever they occur in the text.
+func (s *Redaction) Redact(text string) string {
+	s.learn(text)
+	return s.replace(text)
+}
+
+// learn hands out placeholders for the values the detectors find in a text.
+func (s *Redaction) learn(text string) {
+	for _, match := range findPII(s.detectors, text) {
+		s.placeholder(match.detector, text[match.start:match.end])
+	}
+}
+
+// replace puts placeholders in place of the values the detectors find and of every known value.
+func (s *Redaction) replace(text string) string {
+	matches := findPII(s.detectors, text)
+	for _, known := range s.known {
+		for _, loc := range known.pattern.FindAllStringIndex(text, -1) {
+			if isWholeWord(text, loc[0], loc[1]) {
+				matches = append(matches, piiMatch{start: loc[0], end: loc[1], placeholder: known.placeholder})
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return text
+	}
+	matches = dropOverlaps(matches)
+
+	var out strings.Builder
+	last := 0
+	for _, match := range matches {
+		out.WriteString(text[last:match.start])
+		if match.placeholder != "" {
+			out.WriteString(match.placeholder)
+		} else {
+			out.WriteString(s.placeholder(match.detector, text[match.start:match.end]))
+		}
+		last = match.end
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+func (s *Redaction) placeholder(detector *piiDetector, value string) string {
+	key := detector.label + "\x00" + detector.normalize(value)
+	placeholder, ok := s.placeholders[key]
+	if !ok {
+		s.counters[detector.label]++
+		placeholder = fmt.Sprintf("[%s_%d]", detector.label, s.counters[detector.label])
+		s.placeholders[key] = placeholder
+		s.values[placeholder] = value
+	}
+	s.remember(value, placeholder)
+	return placeholder
+}
+
+// remember adds a form of a value to the known values. Forms are matched case-sensitively, so
+// that a short name does not also hide the everyday word it is spelled like, but any spacing
+// between the words of a value matches.
+func (s *Redaction) remember(value, placeholder string) {
+	words := strings.Fields(value)
+	if len(words) == 0 {
+		return
+	}
+	for i, word := range words {
+		words[i] = regexp.QuoteMeta(word)
+	}
+	expr := strings.Join(words, `\s+`)
+	for _, known := range s.known {
+		if known.pattern.String() == expr {
+			return
+		}
+	}
+	s.known = append(s.known, knownValue{pattern: regexp.MustCompile(expr), placeholder: placeholder})
+}
+
+// isWholeWord reports whether text[start:end] is neither preceded nor followed by a letter or digit.
+func isWholeWord(text string, start, end int) bool {
+	if before, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && (unicode.IsLetter(before) || unicode.IsDigit(before)) {
+		return false
+	}
+	if after, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && (unicode.IsLetter(after) || unicode.IsDigit(after)) {
+		return false
+	}
+	return true
+}
+
+// RedactRequest redacts everything a request sends to the provider: the history and the system
+// prompt. All of it is searched for personal data before anything is replaced, so that a value
+// gets replaced everywhere in the request, including in turns older than the one it was found in.
+func (s *Redaction) RedactRequest(history []domain.Message, systemPrompt string) ([]domain.Message, string) {
+	for _, msg := range history {
+		s.learn(msg.Content)
+	}
+	s.learn(systemPrompt)
+
+	redacted := make([]domain.Message, len(history))
+	copy(redacted, history)
+	for i := range redacted {
+		redacted[i].Content = s.replace(redacted[i].Content)
+	}
+	return redacted, s.replace(systemPrompt)
+}
+
+// Restore puts the original values back in place of the placeholders of this session. Placeholders
+// the session did not hand out are left alone.
+func (s *Redaction) Restore(text string) string {
+	if len(s.values) == 0 {
+		return text
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if value, ok := s.values[placeholder]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// placeholderFilter restores placeholders in streamed chunks. Text that may be the start of a
+// placeholder is held back until it is clear whether it is one.
+type placeholderFilter struct {
+	redaction *Redaction
+	next      StreamHandler
+	pending   string
+}
+
+func (f *placeholderFilter) write(chunk string) error {
+	buf := f.pending + chunk
+	f.pending = ""
+	if i := strings.LastIndexByte(buf, '['); i >= 0 && len(buf)-i <= maxPlaceholderLen && placeholderPrefix.MatchString(buf[i:]) {
+		f.pending = buf[i:] // Wait for more text
+		buf = buf[:i]
+	}
+	if buf == "" {
+		return nil
+	}
+	return f.next(f.redaction.Restore(buf))
+}
+
+// flush passes on text that was held back but turned out not to be a placeholder.
+func (f *placeholderFilter) flush() error {
+	if f.pending == "" {
+		return nil
+	}
+	pending := f.pending
+	f.pending = ""
+	return f.next(pending)
+}
+
+// RedactingClient redacts personal data in everything sent to the wrapped client and restores it
+// in the responses, including streamed chunks. Wrapping the client, rather than the chat, covers
+// summaries and the crisis classifier as well.
+type RedactingClient struct {
+	next     LLMClient
+	redactor *Redactor
+}
+
+// NewRedactingClient wraps an LLM client with redaction.
+func NewRedactingClient(next LLMClient, redactor *Redactor) *RedactingClient {
+	return &RedactingClient{next: next, redactor: redactor}
+}
+
+// GenerateResponse sends the redacted request and restores the response.
+func (c *RedactingClient) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*LLMResponse, error) {
+	session := c.redactor.NewSession()
+	history, systemPrompt = session.RedactRequest(history, systemPrompt)
+	resp, err := c.next.GenerateResponse(ctx, history, systemPrompt)
+	return restoreResponse(session, resp), err
+}
+
+// StreamResponse sends the redacted request and restores every chunk before it is passed on.
+func (c *RedactingClient) StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk StreamHandler) (*LLMResponse, error) {
+	session := c.redactor.NewSession()
+	history, systemPrompt = session.RedactRequest(history, systemPrompt)
+	filter := &placeholderFilter{redaction: session, next: onChunk}
+	resp, err := c.next.StreamResponse(ctx, history, systemPrompt, filter.write)
+	if err == nil {
+		err = filter.flush()
+	}
+	return restoreResponse(session, resp), err
+}
+
+func restoreResponse(session *Redaction, resp *LLMResponse) *LLMResponse {
+	if resp == nil {
+		return nil
+	}
+	restored := *resp
+	restored.Content = session.Restore(resp.Content)
+	return &restored
+}
+
+// validKazakhIIN checks the date of birth and the check digit of a Kazakhstan individual
+// identification number (ЖСН/ИИН), so that other 12-digit numbers are not redacted.
+func validKazakhIIN(value string) bool {
+	digits := onlyDigits(value)
+	if len(digits) != 12 {
+		return false
+	}
+	d := make([]int, 12)
+	for i, r := range digits {
+		d[i] = int(r - '0')
+	}
+	month, day := d[2]*10+d[3], d[4]*10+d[5]
+	if month < 1 || month > 12 || day < 1 || day > 31 || d[6] > 6 {
+		return false
+	}
+
+	check := 0
+	for i := 0; i < 11; i++ {
+		check += d[i] * (i + 1)
+	}
+	check %= 11
+	if check == 10 {
+		check = 0
+		for i := 0; i < 11; i++ {
+			check += d[i] * ((i+2)%11 + 1)
+		}
+		check %= 11
+		if check == 10 {
+			return false
+		}
+	}
+	return check == d[11]
+}
+
+// normalizePIIText compares values case-insensitively and ignoring spacing.
+func normalizePIIText(value string) string {
+	return strings.Join(strings.Fields(strings.ToLower(value)), " ")
+}
+
+func onlyDigits(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, value)
+}
+
+// normalizePhone compares phone numbers by their digits, treating the Kazakhstan trunk prefix 8
+// like the country code +7.
+func normalizePhone(value string) string {
+	digits := onlyDigits(value)
+	if len(digits) == 11 && digits[0] == '8' {
+		digits = "7" + digits[1:]
+	}
+	return digits
+}
@@ -0,0 +1,191 @@
+// github.com/DauletBai/oilan.org/internal/app/services/redaction_test.go
+package services
+
+import (
+	"context"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"strings"
+	"testing"
+)
+
+func loadTestRedactor(t *testing.T) *Redactor {
+	t.Helper()
+	redactor, err := LoadRedactor("../../../configs/redaction.json")
+	if err != nil {
+		t.Fatalf("LoadRedactor: %v", err)
+	}
+	return redactor
+}
+
+func TestRedactionReplacesLaterMentions(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []string
+		prompt  string
+		want    []string // Redacted history followed by the redacted prompt
+	}{
+		{
+			name:    "second mention in the same message",
+			history: []string{"My name is Aigerim. Aigerim is tired."},
+			want:    []string{"My name is [PERSON_1]. [PERSON_1] is tired.", ""},
+		},
+		{
+			name:    "mention in a later message",
+			history: []string{"меня зовут Айгерим", "Айгерим снова не спала"},
+			want:    []string{"меня зовут [PERSON_1]", "[PERSON_1] снова не спала", ""},
+		},
+		{
+			name:    "mention in an earlier message",
+			history: []string{"Aigerim said hello", "my name is Aigerim"},
+			want:    []string{"[PERSON_1] said hello", "my name is [PERSON_1]", ""},
+		},
+		{
+			name:    "full name in the system prompt",
+			history: []string{"my name is Aigerim Bekova"},
+			prompt:  "The user is Aigerim  Bekova.",
+			want:    []string{"my name is [PERSON_1]", "The user is [PERSON_1]."},
+		},
+		{
+			name:    "name as part of a longer word",
+			history: []string{"call me Ali", "Alice and Ali, again"},
+			want:    []string{"call me [PERSON_1]", "Alice and [PERSON_1], again", ""},
+		},
+		{
+			name:    "two people keep their placeholders",
+			history: []string{"my name is Dana", "call me Arman", "Dana met Arman"},
+			want:    []string{"my name is [PERSON_1]", "call me [PERSON_2]", "[PERSON_1] met [PERSON_2]", ""},
+		},
+	}
+
+	redactor := loadTestRedactor(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := make([]domain.Message, len(tt.history))
+			for i, content := range tt.history {
+				history[i] = domain.Message{Role: domain.RoleUser, Content: content}
+			}
+
+			session := redactor.NewSession()
+			redacted, prompt := session.RedactRequest(history, tt.prompt)
+			var got []string
+			for _, msg := range redacted {
+				got = append(got, msg.Content)
+			}
+			got = append(got, prompt)
+
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("RedactRequest() =\n%q\nwant\n%q", got, tt.want)
+			}
+			if history[0].Content != tt.history[0] {
+				t.Errorf("RedactRequest() changed the history it was given")
+			}
+		})
+	}
+}
+
+func TestRedactionRedactsSecondMention(t *testing.T) {
+	session := loadTestRedactor(t).NewSession()
+	if got := session.Redact("My name is Aigerim."); got != "My name is [PERSON_1]." {
+		t.Fatalf("Redact(first mention) = %q", got)
+	}
+	if got := session.Redact("Aigerim said she was fine."); got != "[PERSON_1] said she was fine." {
+		t.Errorf("Redact(second mention) = %q, want the name replaced", got)
+	}
+}
+
+func TestPlaceholderFilterRestoresSplitPlaceholders(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{name: "whole placeholder", chunks: []string{"Hello, [PERSON_1]!"}, want: "Hello, Aigerim!"},
+		{name: "split inside the label", chunks: []string{"Hello, [PER", "SON_1]!"}, want: "Hello, Aigerim!"},
+		{name: "split after the bracket", chunks: []string{"Hello, [", "PERSON_", "1", "]!"}, want: "Hello, Aigerim!"},
+		{name: "placeholder at the end", chunks: []string{"Bye, [PERSON", "_1]"}, want: "Bye, Aigerim"},
+		{name: "unknown placeholder", chunks: []string{"[PERSON_", "2] stays"}, want: "[PERSON_2] stays"},
+		{name: "bracket that is no placeholder", chunks: []string{"a [b", "] c"}, want: "a [b] c"},
+		{name: "unfinished placeholder at the end", chunks: []string{"text [PERS"}, want: "text [PERS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := loadTestRedactor(t).NewSession()
+			session.Redact("my name is Aigerim")
+
+			var got strings.Builder
+			filter := &placeholderFilter{redaction: session, next: func(chunk string) error {
+				got.WriteString(chunk)
+				return nil
+			}}
+			for _, chunk := range tt.chunks {
+				if err := filter.write(chunk); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			}
+			if err := filter.flush(); err != nil {
+				t.Fatalf("flush: %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("streamed %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+// streamingStub answers with fixed chunks and keeps the request it received.
+type streamingStub struct {
+	chunks  []string
+	history []domain.Message
+	prompt  string
+}
+
+func (s *streamingStub) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*LLMResponse, error) {
+	return s.StreamResponse(ctx, history, systemPrompt, func(string) error { return nil })
+}
+
+func (s *streamingStub) StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk StreamHandler) (*LLMResponse, error) {
+	s.history, s.prompt = history, systemPrompt
+	for _, chunk := range s.chunks {
+		if err := onChunk(chunk); err != nil {
+			return nil, err
+		}
+	}
+	return &LLMResponse{Content: strings.Join(s.chunks, "")}, nil
+}
+
+func TestRedactingClientStreamsRestoredChunks(t *testing.T) {
+	stub := &streamingStub{chunks: []string{"I hear you, [PERS", "ON_1]. Is ", "[PERSON_1] your", " full name?"}}
+	client := NewRedactingClient(stub, loadTestRedactor(t))
+	history := []domain.Message{
+		{Role: domain.RoleUser, Content: "Меня зовут Aigerim"},
+		{Role: domain.RoleAI, Content: "Nice to meet you."},
+		{Role: domain.RoleUser, Content: "Aigerim is my name, write me at a.b@example.com"},
+	}
+
+	var chunks []string
+	resp, err := client.StreamResponse(context.Background(), history, "Be kind to Aigerim.", func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamResponse: %v", err)
+	}
+
+	for _, msg := range stub.history {
+		if strings.Contains(msg.Content, "Aigerim") || strings.Contains(msg.Content, "example.com") {
+			t.Errorf("provider received personal data: %q", msg.Content)
+		}
+	}
+	if strings.Contains(stub.prompt, "Aigerim") {
+		t.Errorf("provider received personal data in the prompt: %q", stub.prompt)
+	}
+
+	want := "I hear you, Aigerim. Is Aigerim your full name?"
+	if got := strings.Join(chunks, ""); got != want {
+		t.Errorf("streamed %q, want %q", got, want)
+	}
+	if resp.Content != want {
+		t.Errorf("response content = %q, want %q", resp.Content, want)
+	}
+}
@@ -0,0 +1,33 @@
+// github.com/DauletBai/oilan.org/internal/domain/consent.go
+package domain
+
+import "time"
+
+// LegalDocument is an immutable version of a document users agree to, such as the terms of
+// service, the medical disclaimer or the research consent.
+type LegalDocument struct {
+	ID          int64             `json:"id"`
+	Kind        string            `json:"kind"`
+	Version     int               `json:"version"`  // 1, 2, ... per kind
+	Required    bool              `json:"required"` // Chat is blocked until the current version is accepted
+	Title       map[string]string `json:"title"`    // Per locale
+	Body        map[string]string `json:"body"`     // Per locale; paragraphs are separated by blank lines
+	PublishedAt time.Time         `json:"published_at"`
+}
+
+// Actions recorded in the consent ledger.
+const (
+	ConsentAccepted  = "accepted"
+	ConsentWithdrawn = "withdrawn"
+)
+
+// Consent is an entry of the consent ledger: a user accepted or withdrew a version of a document.
+type Consent struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	DocumentID int64     `json:"document_id"`
+	Kind       string    `json:"kind"`    // Kind of the document
+	Version    int       `json:"version"` // Version of the document
+	Action     string    `json:"action"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+// github.com/DauletBai/oilan.org/internal/domain/crisis.go
+package domain
+
+import "time"
+
+// CrisisEvent records a user message in which a classifier detected signs of a crisis,
+// such as suicidal thoughts or self-harm.
+type CrisisEvent struct {
+	ID         int64     `json:"id"`
+	DialogID   int64     `json:"dialog_id"`
+	UserID     int64     `json:"user_id"`
+	MessageID  *int64    `json:"message_id,omitempty"` // The flagged user message
+	Classifier string    `json:"classifier"`           // Classifier that detected the crisis, e.g. "keywords"
+	Language   string    `json:"language"`             // Language of the crisis response that was sent
+	Evidence   string    `json:"evidence,omitempty"`   // What the classifier matched
+	CreatedAt  time.Time `json:"created_at"`
+}
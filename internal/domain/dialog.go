@@ -15,17 +15,40 @@ const (
 type Message struct {
 	ID        int64     `json:"id"`
 	DialogID  int64     `json:"dialog_id"`
-	Role      Role      `json:"role"`      // "user" or "ai"
-	Content   string    `json:"content"`   // The text of the message
+	Seq       int64     `json:"seq"`     // Position in the dialog: 1, 2, ...
+	Role      Role      `json:"role"`    // "user" or "ai"
+	Content   string    `json:"content"` // The text of the message
 	CreatedAt time.Time `json:"created_at"`
+
+	Redactions int `json:"redactions,omitempty"` // Personal data replaced with placeholders whenever the message is sent to a provider
+
+	// Generation metadata, only set for AI messages.
+	Provider         string `json:"provider,omitempty"` // LLM provider that produced the message
+	Model            string `json:"model,omitempty"`
+	PromptTokens     int    `json:"prompt_tokens,omitempty"`
+	CompletionTokens int    `json:"completion_tokens,omitempty"`
+	LatencyMs        int64  `json:"latency_ms,omitempty"`
+	FinishReason     string `json:"finish_reason,omitempty"`     // "stop", "max_tokens", "safety" or "other"
+	PromptVersionID  *int64 `json:"prompt_version_id,omitempty"` // Version of the system prompt that produced the message
 }
 
 // Dialog represents a complete conversation session for a user.
 type Dialog struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
-	Title     string    `json:"title"`     // A title for the dialog, can be auto-generated
-	Messages  []Message `json:"messages"`  // The list of all messages in this dialog
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-}
\ No newline at end of file
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Title     string     `json:"title"`               // A title for the dialog, can be auto-generated
+	PersonaID string     `json:"persona_id"`          // The persona the AI takes on in this dialog
+	Phase     string     `json:"phase"`               // Current phase of the persona's guided session, if it has one
+	CrisisAt  *time.Time `json:"crisis_at,omitempty"` // When a crisis was first detected in the dialog
+	Messages  []Message  `json:"messages"`            // The list of all messages in this dialog
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// DialogSummary is an incrementally updated summary of the older part of a dialog.
+type DialogSummary struct {
+	DialogID       int64     `json:"dialog_id"`
+	Content        string    `json:"content"`
+	CoveredUntilID int64     `json:"covered_until_id"` // ID of the last message included in the summary
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,86 @@
+// github.com/DauletBai/oilan.org/internal/domain/moderation.go
+package domain
+
+import "time"
+
+// Sources of moderation items.
+const (
+	ModerationSourceCrisis      = "crisis"       // A crisis was detected in a user message
+	ModerationSourceSafetyBlock = "safety_block" // The provider's safety filters blocked a reply
+	ModerationSourceUserReport  = "user_report"  // The user reported a message
+	ModerationSourceLowFeedback = "low_feedback" // The user gave a message a low score
+)
+
+// Severities of moderation items, from least to most urgent.
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// Severities lists the severities from most to least urgent, the order of the review queue.
+var Severities = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow}
+
+// Statuses of moderation items.
+const (
+	ModerationOpen     = "open"
+	ModerationResolved = "resolved"
+)
+
+// Actions recorded for every change to a moderation item.
+const (
+	ModerationActionCreated    = "created"
+	ModerationActionFlagged    = "flagged" // The same message was flagged again while the item was open
+	ModerationActionAssigned   = "assigned"
+	ModerationActionUnassigned = "unassigned"
+	ModerationActionNoted      = "noted"
+	ModerationActionResolved   = "resolved"
+	ModerationActionReopened   = "reopened"
+)
+
+// ModerationItem is a dialog or message that an admin should review.
+type ModerationItem struct {
+	ID         int64      `json:"id"`
+	DialogID   int64      `json:"dialog_id"`
+	UserID     int64      `json:"user_id"`              // Owner of the dialog
+	MessageID  *int64     `json:"message_id,omitempty"` // The message that triggered the item, if any
+	Source     string     `json:"source"`
+	Severity   string     `json:"severity"`
+	Reason     string     `json:"reason"`
+	Excerpt    string     `json:"excerpt"` // The triggering text
+	Status     string     `json:"status"`
+	AssigneeID *int64     `json:"assignee_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+
+	Events []ModerationEvent `json:"events,omitempty"` // History of the item, oldest first
+}
+
+// ModerationEvent records a change to a moderation item.
+type ModerationEvent struct {
+	ID        int64     `json:"id"`
+	ItemID    int64     `json:"item_id"`
+	ActorID   *int64    `json:"actor_id,omitempty"` // The admin who made the change; nil for the system
+	Action    string    `json:"action"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ModerationFilter selects items of the review queue. Empty fields match everything.
+type ModerationFilter struct {
+	Status     string
+	Severity   string
+	Source     string
+	AssigneeID *int64
+}
+
+// MessageFeedback is the score a user gave an AI message, from 1 (bad) to 5 (good).
+type MessageFeedback struct {
+	MessageID int64     `json:"message_id"`
+	UserID    int64     `json:"user_id"`
+	Score     int       `json:"score"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+// github.com/DauletBai/oilan.org/internal/domain/persona.go
+package domain
+
+// Persona is a character the AI can take on in a dialog: its system prompt together with
+// the model and generation parameters it works best with.
+type Persona struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	PromptFile   string `json:"prompt_file"`
+	SystemPrompt string `json:"-"`              // Loaded from PromptFile
+	Flow         string `json:"flow,omitempty"` // Guided session the persona leads, see configs/phases.json
+
+	// Generation settings; unset values keep the provider's configuration.
+	Models      map[string]string `json:"models,omitempty"` // Default model per provider, e.g. {"gemini": "gemini-1.5-flash"}
+	Temperature *float64          `json:"temperature,omitempty"`
+	MaxTokens   int               `json:"max_tokens,omitempty"`
+}
@@ -0,0 +1,40 @@
+// github.com/DauletBai/oilan.org/internal/domain/phase.go
+package domain
+
+import "time"
+
+// Triggers of a phase transition.
+const (
+	PhaseTriggerLLM  = "llm"  // The AI signalled that the dialog is ready for the next phase
+	PhaseTriggerUser = "user" // The user chose to move on
+)
+
+// Flow is a guided session: the phases a dialog goes through and the allowed transitions between them.
+type Flow struct {
+	ID      string   `json:"id"`
+	Initial string   `json:"initial"` // Phase a new dialog starts in; the first phase if empty
+	Phases  []*Phase `json:"phases"`
+}
+
+// Phase is one stage of a guided session.
+type Phase struct {
+	ID          string            `json:"id"`
+	Name        map[string]string `json:"name"`        // Display name per locale, e.g. {"en": "Symptom onset"}
+	Description map[string]string `json:"description"` // What the phase is about, per locale
+	Guidance    string            `json:"guidance"`    // Instructions added to the system prompt during the phase
+
+	// Transition rules
+	Next            []string `json:"next"`                        // Phases the dialog may move on to
+	MinUserMessages int      `json:"min_user_messages,omitempty"` // Messages the user writes in this phase before the AI may move on
+}
+
+// PhaseTransition records a change of a dialog's phase.
+type PhaseTransition struct {
+	ID        int64     `json:"id"`
+	DialogID  int64     `json:"dialog_id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Trigger   string    `json:"trigger"`              // PhaseTriggerLLM or PhaseTriggerUser
+	MessageID *int64    `json:"message_id,omitempty"` // The AI message that proposed an LLM transition
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+// github.com/DauletBai/oilan.org/internal/domain/prompt.go
+package domain
+
+import "time"
+
+// PromptVersion is an immutable version of a persona's system prompt.
+type PromptVersion struct {
+	ID          int64     `json:"id"`
+	PersonaID   string    `json:"persona_id"`
+	Version     int       `json:"version"` // 1, 2, ... per persona
+	Content     string    `json:"content"`
+	AuthorID    *int64    `json:"author_id,omitempty"` // Nil for versions imported from configs
+	AuthorEmail string    `json:"author_email,omitempty"`
+	Note        string    `json:"note"` // What changed and why
+	CreatedAt   time.Time `json:"created_at"`
+}
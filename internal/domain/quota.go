@@ -0,0 +1,28 @@
+// github.com/DauletBai/oilan.org/internal/domain/quota.go
+package domain
+
+import "time"
+
+// Quota limits how much a user may use the AI. A zero value means "unlimited".
+type Quota struct {
+	MessagesPerDay        int `json:"messages_per_day"`
+	TokensPerMonth        int `json:"tokens_per_month"`
+	ConcurrentGenerations int `json:"concurrent_generations"`
+}
+
+// Purposes of TokenUsage records.
+const (
+	TokenUsageSummary = "summary"
+)
+
+// TokenUsage records tokens spent on a user's behalf that are not stored with a message,
+// e.g. by the history summarizer. They count towards the user's monthly token quota.
+type TokenUsage struct {
+	ID               int64
+	UserID           int64
+	DialogID         int64
+	Purpose          string
+	PromptTokens     int
+	CompletionTokens int
+	CreatedAt        time.Time
+}
@@ -0,0 +1,61 @@
+// github.com/DauletBai/oilan.org/internal/domain/ratelimit.go
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimitPolicy limits how often a client may call a group of routes. Every client has a bucket
+// of Burst tokens per policy; a request takes one, and PerMinute tokens flow back every minute.
+// A zero PerMinute means "unlimited".
+type RateLimitPolicy struct {
+	PerMinute float64 `json:"per_minute"`
+	Burst     int     `json:"burst"`
+}
+
+// Unlimited reports whether the policy lets every request through.
+func (p RateLimitPolicy) Unlimited() bool {
+	return p.PerMinute <= 0
+}
+
+// RefillTime is how long an empty bucket takes to fill up again. A bucket left alone for longer
+// is full and need not be stored.
+func (p RateLimitPolicy) RefillTime() time.Duration {
+	if p.Unlimited() {
+		return 0
+	}
+	return time.Duration(float64(p.Burst) / p.PerMinute * float64(time.Minute))
+}
+
+// TokenBucket is the state of one client's bucket for one policy.
+type TokenBucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// NewTokenBucket returns a full bucket.
+func NewTokenBucket(policy RateLimitPolicy, now time.Time) TokenBucket {
+	return TokenBucket{Tokens: float64(policy.Burst), UpdatedAt: now}
+}
+
+// Take refills the bucket up to now and takes a token from it. When the bucket is empty, nothing
+// is taken and Take returns how long the client has to wait for the next token.
+func (b *TokenBucket) Take(policy RateLimitPolicy, now time.Time) (bool, time.Duration) {
+	if policy.Unlimited() {
+		return true, 0
+	}
+	if elapsed := now.Sub(b.UpdatedAt); elapsed > 0 {
+		b.Tokens += elapsed.Minutes() * policy.PerMinute
+	}
+	// The burst may have been lowered since the bucket was filled.
+	b.Tokens = math.Min(b.Tokens, float64(policy.Burst))
+	b.UpdatedAt = now
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return true, 0
+	}
+	wait := (1 - b.Tokens) / policy.PerMinute * float64(time.Minute)
+	return false, time.Duration(math.Ceil(wait))
+}
@@ -0,0 +1,101 @@
+// github.com/DauletBai/oilan.org/internal/domain/ratelimit_test.go
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	type step struct {
+		at        time.Duration // Time since the bucket was filled
+		allowed   bool
+		retryWait time.Duration
+	}
+	tests := []struct {
+		name   string
+		policy RateLimitPolicy
+		lower  int // Burst the policy is lowered to after the bucket was filled, if set
+		steps  []step
+	}{
+		{
+			name:   "burst then refused",
+			policy: RateLimitPolicy{PerMinute: 60, Burst: 2},
+			steps: []step{
+				{at: 0, allowed: true},
+				{at: 0, allowed: true},
+				{at: 0, allowed: false, retryWait: time.Second},
+			},
+		},
+		{
+			name:   "refills over time",
+			policy: RateLimitPolicy{PerMinute: 6, Burst: 1},
+			steps: []step{
+				{at: 0, allowed: true},
+				{at: 4 * time.Second, allowed: false, retryWait: 6 * time.Second},
+				{at: 10 * time.Second, allowed: true},
+				{at: 10 * time.Second, allowed: false, retryWait: 10 * time.Second},
+			},
+		},
+		{
+			name:   "never more than the burst",
+			policy: RateLimitPolicy{PerMinute: 60, Burst: 2},
+			steps: []step{
+				{at: time.Hour, allowed: true},
+				{at: time.Hour, allowed: true},
+				{at: time.Hour, allowed: false, retryWait: time.Second},
+			},
+		},
+		{
+			name:   "lowered burst caps a full bucket",
+			policy: RateLimitPolicy{PerMinute: 60, Burst: 5},
+			lower:  1,
+			steps: []step{
+				{at: 0, allowed: true},
+				{at: 0, allowed: false, retryWait: time.Second},
+			},
+		},
+		{
+			name:   "unlimited",
+			policy: RateLimitPolicy{},
+			steps: []step{
+				{at: 0, allowed: true},
+				{at: 0, allowed: true},
+				{at: 0, allowed: true},
+			},
+		},
+	}
+
+	start := time.Date(2025, time.March, 14, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := NewTokenBucket(tt.policy, start)
+			policy := tt.policy
+			if tt.lower > 0 {
+				policy.Burst = tt.lower
+			}
+			for i, s := range tt.steps {
+				allowed, wait := bucket.Take(policy, start.Add(s.at))
+				if allowed != s.allowed || wait != s.retryWait {
+					t.Errorf("step %d: Take() = %v, %v, want %v, %v", i, allowed, wait, s.allowed, s.retryWait)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitPolicyRefillTime(t *testing.T) {
+	tests := []struct {
+		policy RateLimitPolicy
+		want   time.Duration
+	}{
+		{policy: RateLimitPolicy{PerMinute: 60, Burst: 10}, want: 10 * time.Second},
+		{policy: RateLimitPolicy{PerMinute: 2, Burst: 4}, want: 2 * time.Minute},
+		{policy: RateLimitPolicy{Burst: 4}, want: 0},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.RefillTime(); got != tt.want {
+			t.Errorf("%+v.RefillTime() = %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+}
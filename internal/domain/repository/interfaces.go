@@ -4,6 +4,7 @@ package repository
 import (
 	"context"
 	"github.com/DauletBai/oilan.org/internal/domain"
+	"time"
 )
 
 // UserRepository defines the interface for user data storage.
@@ -23,4 +24,94 @@ type DialogRepository interface {
 	FindAllByUserID(ctx context.Context, userID int64) ([]*domain.Dialog, error)
 	GetAll(ctx context.Context) ([]*domain.Dialog, error) 
 	AddMessage(ctx context.Context, message *domain.Message) error
-}
\ No newline at end of file
+}
+
+// ModerationRepository defines the interface for the admin review queue.
+type ModerationRepository interface {
+	// Create stores a new item together with its "created" event.
+	Create(ctx context.Context, item *domain.ModerationItem, event *domain.ModerationEvent) error
+	// Update stores the changed fields of an item together with the event that describes the change.
+	Update(ctx context.Context, item *domain.ModerationItem, event *domain.ModerationEvent) error
+	FindByID(ctx context.Context, id int64) (*domain.ModerationItem, error)
+	// FindOpen returns the open item for a message and source, or nil.
+	FindOpen(ctx context.Context, dialogID int64, messageID *int64, source string) (*domain.ModerationItem, error)
+	List(ctx context.Context, filter domain.ModerationFilter) ([]*domain.ModerationItem, error)
+	ListByDialog(ctx context.Context, dialogID int64) ([]*domain.ModerationItem, error)
+}
+
+// FeedbackRepository defines the interface for the scores users give AI messages.
+type FeedbackRepository interface {
+	// Save stores a score, replacing the one the user gave the message before.
+	Save(ctx context.Context, feedback *domain.MessageFeedback) error
+}
+
+// SummaryRepository defines the interface for storing rolling dialog summaries.
+type SummaryRepository interface {
+	FindByDialogID(ctx context.Context, dialogID int64) (*domain.DialogSummary, error)
+	FindRecentByUserID(ctx context.Context, userID int64, beforeDialogID int64, limit int) ([]*domain.DialogSummary, error)
+	Save(ctx context.Context, summary *domain.DialogSummary) error
+}
+
+// QuotaRepository defines the interface for quota overrides and usage accounting.
+type QuotaRepository interface {
+	FindOverride(ctx context.Context, userID int64) (*domain.Quota, error)
+	SaveOverride(ctx context.Context, userID int64, quota *domain.Quota) error
+	DeleteOverride(ctx context.Context, userID int64) error
+	CountUserMessagesSince(ctx context.Context, userID int64, since time.Time) (int, error)
+	SumTokensSince(ctx context.Context, userID int64, since time.Time) (int, error)
+	RecordTokenUsage(ctx context.Context, usage *domain.TokenUsage) error
+	AcquireGenerationSlot(ctx context.Context, userID int64, limit int, ttl time.Duration) (slotID int64, ok bool, err error)
+	ReleaseGenerationSlot(ctx context.Context, slotID int64) error
+	CountGenerationSlots(ctx context.Context, userID int64) (int, error)
+}
+
+// PromptRepository defines the interface for versioned system prompts.
+type PromptRepository interface {
+	CreateVersion(ctx context.Context, version *domain.PromptVersion) error
+	FindVersion(ctx context.Context, id int64) (*domain.PromptVersion, error)
+	ListVersions(ctx context.Context, personaID string) ([]*domain.PromptVersion, error)
+	FindActive(ctx context.Context, personaID string) (*domain.PromptVersion, error)
+	Activate(ctx context.Context, personaID string, versionID int64, activatedBy *int64) error
+}
+
+// PhaseRepository defines the interface for the phases of guided sessions.
+type PhaseRepository interface {
+	// Transition moves a dialog to transition.To if it is still in currentPhase and records the
+	// transition. It reports false when another transition happened first.
+	Transition(ctx context.Context, transition *domain.PhaseTransition, currentPhase string) (bool, error)
+	ListTransitions(ctx context.Context, dialogID int64) ([]*domain.PhaseTransition, error)
+	LastTransition(ctx context.Context, dialogID int64) (*domain.PhaseTransition, error)
+}
+
+// CrisisRepository defines the interface for detected crises.
+type CrisisRepository interface {
+	// Record stores a crisis event and flags its dialog, unless it is flagged already.
+	Record(ctx context.Context, event *domain.CrisisEvent) error
+	ListByDialog(ctx context.Context, dialogID int64) ([]*domain.CrisisEvent, error)
+}
+
+// ConsentRepository defines the interface for legal documents and the consent ledger.
+type ConsentRepository interface {
+	// SaveDocument stores a version of a document and returns the stored version, which is the
+	// one published before if the kind and version already exist.
+	SaveDocument(ctx context.Context, doc *domain.LegalDocument) (*domain.LegalDocument, error)
+	FindDocument(ctx context.Context, kind string, version int) (*domain.LegalDocument, error)
+	Record(ctx context.Context, consent *domain.Consent) error
+	// ListByUser returns a user's ledger, oldest entry first.
+	ListByUser(ctx context.Context, userID int64) ([]*domain.Consent, error)
+}
+
+// RateLimitStore defines the interface for the token buckets of the rate limiter.
+type RateLimitStore interface {
+	// Take takes a token from the bucket under key, creating a full bucket on first use. When the
+	// bucket is empty, it reports false and how long the client has to wait.
+	Take(ctx context.Context, key string, policy domain.RateLimitPolicy) (bool, time.Duration, error)
+	// Prune removes the buckets that were not used for longer than idle.
+	Prune(ctx context.Context, idle time.Duration) error
+}
+
+// DialogLocker defines the interface for locks that let one server instance at a time work on a dialog.
+type DialogLocker interface {
+	// Lock blocks until the dialog is locked or ctx is done. The returned function releases the lock.
+	Lock(ctx context.Context, dialogID int64) (func(), error)
+}
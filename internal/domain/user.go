@@ -12,5 +12,7 @@ type User struct {
 	ProviderID string   `json:"provider_id"`// User ID from the provider
 	Email     string    `json:"email"`      // User's email, verified by provider
 	Role      string    `json:"role"`       // "user", "admin")
+	Language  string    `json:"language"`   // Preferred language, e.g. "kk", "ru" or "en"; empty if unknown
+	Timezone  string    `json:"timezone"`   // IANA timezone, e.g. "Asia/Almaty"; empty if unknown
 	CreatedAt time.Time `json:"created_at"` // Timestamp of user creation
 }
\ No newline at end of file
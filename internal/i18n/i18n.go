@@ -0,0 +1,149 @@
+// github.com/DauletBai/oilan.org/internal/i18n/i18n.go
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the locale used when nothing the user prefers is supported.
+const Default = "en"
+
+// Supported lists the locales we have catalogs for, in the order they are offered to users.
+var Supported = []string{"kk", "ru", "en"}
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// catalogs maps a locale to its messages; it is filled once at startup and never changed.
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	catalogs := make(map[string]map[string]string, len(Supported))
+	for _, locale := range Supported {
+		data, err := localeFiles.ReadFile("locales/" + locale + ".json")
+		if err != nil {
+			panic(fmt.Sprintf("i18n: missing catalog for %s: %v", locale, err))
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: invalid catalog for %s: %v", locale, err))
+		}
+		catalogs[locale] = messages
+	}
+	return catalogs
+}
+
+// IsSupported reports whether there is a catalog for the locale.
+func IsSupported(locale string) bool {
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// T translates a message key into the given locale. Messages missing from a catalog fall back
+// to the default locale and then to the key itself, so a forgotten translation is visible but
+// never breaks a page. Arguments are formatted into the message with fmt.Sprintf.
+func T(locale, key string, args ...interface{}) string {
+	message, ok := catalogs[locale][key]
+	if !ok {
+		message, ok = catalogs[Default][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// Negotiate picks the locale of a request: the language the user chose (stored in a cookie),
+// then the browser's Accept-Language header. Empty or unsupported values are skipped.
+func Negotiate(chosen, acceptLanguage string) string {
+	if locale := normalize(chosen); IsSupported(locale) {
+		return locale
+	}
+	for _, locale := range parseAcceptLanguage(acceptLanguage) {
+		if IsSupported(locale) {
+			return locale
+		}
+	}
+	return Default
+}
+
+// parseAcceptLanguage returns the languages of an Accept-Language header, most preferred first.
+// Only the primary subtag is kept, since our catalogs do not differ by region.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			langs = append(langs, weighted{locale: normalize(tag), q: q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	locales := make([]string, len(langs))
+	for i, lang := range langs {
+		locales[i] = lang.locale
+	}
+	return locales
+}
+
+// normalize reduces a language tag such as "ru-KZ" to its primary subtag.
+func normalize(tag string) string {
+	primary, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	return strings.ToLower(primary)
+}
+
+type contextKey struct{}
+
+// WithLocale returns a context carrying the locale of the request.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, contextKey{}, locale)
+}
+
+// FromContext returns the locale of the request, or the default locale if none was negotiated.
+func FromContext(ctx context.Context) string {
+	if locale, ok := ctx.Value(contextKey{}).(string); ok {
+		return locale
+	}
+	return Default
+}
+
+// Localized picks the text for a locale from a map of translations, falling back to the
+// default locale and then to any translation there is.
+func Localized(texts map[string]string, locale string) string {
+	if text, ok := texts[locale]; ok {
+		return text
+	}
+	if text, ok := texts[Default]; ok {
+		return text
+	}
+	for _, candidate := range Supported {
+		if text, ok := texts[candidate]; ok {
+			return text
+		}
+	}
+	return ""
+}
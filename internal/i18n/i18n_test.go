@@ -0,0 +1,58 @@
+// github.com/DauletBai/oilan.org/internal/i18n/i18n_test.go
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCatalogsTranslateEveryKey(t *testing.T) {
+	for key, message := range catalogs[Default] {
+		for _, locale := range Supported {
+			translated, ok := catalogs[locale][key]
+			if !ok {
+				t.Errorf("%s: missing %q", locale, key)
+				continue
+			}
+			if strings.Count(translated, "%") != strings.Count(message, "%") {
+				t.Errorf("%s: %q has other format verbs than in %s", locale, key, Default)
+			}
+		}
+	}
+}
+
+func TestChatErrorsAreTranslated(t *testing.T) {
+	keys := []string{"chat.dialog_busy", "chat.response_blocked", "chat.llm_unavailable", "chat.generation_failed"}
+	for _, key := range keys {
+		seen := make(map[string]string)
+		for _, locale := range Supported {
+			message := T(locale, key)
+			if message == key {
+				t.Errorf("%s: %q is not in the catalog", locale, key)
+			}
+			if other, ok := seen[message]; ok {
+				t.Errorf("%q is the same in %s and %s", key, locale, other)
+			}
+			seen[message] = locale
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		chosen string
+		accept string
+		want   string
+	}{
+		{chosen: "kk", accept: "ru", want: "kk"},
+		{chosen: "RU-kz", want: "ru"},
+		{chosen: "de", accept: "de-DE, ru;q=0.5, en;q=0.7", want: "en"},
+		{accept: "kk-KZ", want: "kk"},
+		{accept: "fr", want: Default},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.chosen, tt.accept); got != tt.want {
+			t.Errorf("Negotiate(%q, %q) = %q, want %q", tt.chosen, tt.accept, got, tt.want)
+		}
+	}
+}
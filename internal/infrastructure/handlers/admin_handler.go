@@ -2,9 +2,14 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
 	"github.com/DauletBai/oilan.org/internal/domain/repository" 
+	"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
 	"github.com/DauletBai/oilan.org/internal/view"
 	"strconv"
 
@@ -13,12 +18,24 @@ import (
 
 // AdminHandlers holds dependencies for admin page handlers.
 type AdminHandlers struct {
-	DashboardTemplate  *view.Template
-	UsersTemplate      *view.Template 
-	DialogsTemplate    *view.Template
-	DialogViewTemplate *view.Template
-	UserRepo           repository.UserRepository
-	DialogRepo         repository.DialogRepository
+	DashboardTemplate      *view.Template
+	UsersTemplate          *view.Template
+	DialogsTemplate        *view.Template
+	DialogViewTemplate     *view.Template
+	UserQuotaTemplate      *view.Template
+	PromptsTemplate        *view.Template
+	PromptEditTemplate     *view.Template
+	PromptDiffTemplate     *view.Template
+	ModerationTemplate     *view.Template
+	ModerationItemTemplate *view.Template
+	UserRepo               repository.UserRepository
+	DialogRepo             repository.DialogRepository
+	ChatService            *services.ChatService
+	QuotaService           *services.QuotaService
+	PromptService          *services.PromptService
+	PhaseService           *services.PhaseService
+	CrisisService          *services.CrisisService
+	ModerationService      *services.ModerationService
 }
 
 // DashboardHandler renders the main admin dashboard page.
@@ -101,8 +118,352 @@ func (h *AdminHandlers) DialogViewHandler(w http.ResponseWriter, r *http.Request
 		"dialog": dialog,
 	}
 
+	// Show where a guided session stands and how it got there.
+	if flow, phase := h.PhaseService.Current(dialog); flow != nil {
+		transitions, err := h.PhaseService.Transitions(r.Context(), dialogID)
+		if err != nil {
+			log.Printf("Error loading phase transitions: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		data["flow"] = flow
+		data["phase"] = phase
+		data["transitions"] = transitions
+	}
+
+	if dialog.CrisisAt != nil {
+		events, err := h.CrisisService.Events(r.Context(), dialogID)
+		if err != nil {
+			log.Printf("Error loading crisis events: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		data["crisisEvents"] = events
+	}
+
+	items, err := h.ModerationService.DialogItems(r.Context(), dialogID)
+	if err != nil {
+		log.Printf("Error loading moderation items: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data["moderationItems"] = items
+
 	err = h.DialogViewTemplate.Render(w, "base.html", data)
 	if err != nil {
 		log.Printf("Error rendering dialog view template: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+// DialogContextHandler returns, as JSON, the context window that the next turn of a dialog
+// would send to the model: the system prompt with the summary and the verbatim recent turns.
+func (h *AdminHandlers) DialogContextHandler(w http.ResponseWriter, r *http.Request) {
+	dialogID, err := strconv.ParseInt(chi.URLParam(r, "dialogID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid dialog ID", http.StatusBadRequest)
+		return
+	}
+
+	window, err := h.ChatService.PreviewContext(r.Context(), dialogID)
+	if err != nil {
+		if errors.Is(err, services.ErrDialogNotFound) {
+			http.Error(w, "Dialog not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error building dialog context: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(window)
+}
+
+// UserQuotaHandler shows a user's effective quota and usage and lets admins override it.
+func (h *AdminHandlers) UserQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	user, err := h.UserRepo.FindByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error finding user by ID: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	usage, err := h.QuotaService.Usage(r.Context(), user)
+	if err != nil {
+		log.Printf("Error loading quota usage: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"title":     "User Quota",
+		"user":      user,
+		"usage":     usage,
+		"roleQuota": h.QuotaService.RoleQuota(user.Role),
+	}
+	err = h.UserQuotaTemplate.Render(w, "base.html", data)
+	if err != nil {
+		log.Printf("Error rendering user quota template: %v", err)
+	}
+}
+
+// UpdateUserQuotaHandler saves or resets a user's quota override.
+func (h *AdminHandlers) UpdateUserQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	if r.PostForm.Get("action") == "reset" {
+		err = h.QuotaService.ResetOverride(r.Context(), userID)
+	} else {
+		var quota domain.Quota
+		fields := map[string]*int{
+			"messages_per_day":       &quota.MessagesPerDay,
+			"tokens_per_month":       &quota.TokensPerMonth,
+			"concurrent_generations": &quota.ConcurrentGenerations,
+		}
+		for name, target := range fields {
+			value, convErr := strconv.Atoi(r.PostForm.Get(name))
+			if convErr != nil || value < 0 {
+				http.Error(w, "Invalid value for "+name, http.StatusBadRequest)
+				return
+			}
+			*target = value
+		}
+		err = h.QuotaService.SetOverride(r.Context(), userID, quota)
+	}
+	if err != nil {
+		log.Printf("Error updating user quota: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users/"+strconv.FormatInt(userID, 10)+"/quota", http.StatusSeeOther)
+}
+
+// PromptsHandler lists all personas together with the prompt version each of them uses.
+func (h *AdminHandlers) PromptsHandler(w http.ResponseWriter, r *http.Request) {
+	type personaPrompt struct {
+		Persona *domain.Persona
+		Active  *domain.PromptVersion
+	}
+	var rows []personaPrompt
+	for _, persona := range h.ChatService.Personas() {
+		active, err := h.PromptService.ActivePrompt(r.Context(), persona)
+		if err != nil {
+			log.Printf("Error loading active prompt: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		rows = append(rows, personaPrompt{Persona: persona, Active: active})
+	}
+
+	data := map[string]interface{}{
+		"title":    "Prompts",
+		"personas": rows,
+	}
+	err := h.PromptsTemplate.Render(w, "base.html", data)
+	if err != nil {
+		log.Printf("Error rendering prompts template: %v", err)
+	}
+}
+
+// PromptEditHandler shows the version history of a persona's prompt and a form for a new version.
+// The form starts from the active version, or from the version given in the "from" query parameter.
+func (h *AdminHandlers) PromptEditHandler(w http.ResponseWriter, r *http.Request) {
+	personaID := chi.URLParam(r, "personaID")
+	content := ""
+	if from := r.URL.Query().Get("from"); from != "" {
+		versionID, err := strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid version ID", http.StatusBadRequest)
+			return
+		}
+		version, err := h.PromptService.Version(r.Context(), personaID, versionID)
+		if err != nil {
+			h.promptError(w, err)
+			return
+		}
+		content = version.Content
+	}
+	h.renderPromptEdit(w, r, personaID, content, "", "")
+}
+
+// SavePromptHandler stores a new version of a persona's prompt.
+func (h *AdminHandlers) SavePromptHandler(w http.ResponseWriter, r *http.Request) {
+	personaID := chi.URLParam(r, "personaID")
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	adminID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+	content := r.PostForm.Get("content")
+	note := r.PostForm.Get("note")
+
+	_, err := h.PromptService.SaveVersion(r.Context(), personaID, content, note, adminID, r.PostForm.Get("activate") == "on")
+	if errors.Is(err, services.ErrEmptyPrompt) || errors.Is(err, services.ErrPromptUnchanged) || errors.Is(err, services.ErrInvalidPromptTemplate) {
+		// Keep what the admin typed, so nothing is lost.
+		h.renderPromptEdit(w, r, personaID, content, note, err.Error())
+		return
+	}
+	if err != nil {
+		h.promptError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/prompts/"+personaID, http.StatusSeeOther)
+}
+
+// ActivatePromptHandler activates a version of a persona's prompt; activating an older version rolls back.
+func (h *AdminHandlers) ActivatePromptHandler(w http.ResponseWriter, r *http.Request) {
+	personaID := chi.URLParam(r, "personaID")
+	versionID, err := strconv.ParseInt(chi.URLParam(r, "versionID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid version ID", http.StatusBadRequest)
+		return
+	}
+	adminID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+
+	if err := h.PromptService.Activate(r.Context(), personaID, versionID, adminID); err != nil {
+		h.promptError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/prompts/"+personaID, http.StatusSeeOther)
+}
+
+// PromptDiffHandler shows the line-by-line difference between two versions of a persona's prompt.
+// Without parameters it compares the active version with the one before it.
+func (h *AdminHandlers) PromptDiffHandler(w http.ResponseWriter, r *http.Request) {
+	personaID := chi.URLParam(r, "personaID")
+	persona, ok := h.personaByID(personaID)
+	if !ok {
+		http.Error(w, "Persona not found", http.StatusNotFound)
+		return
+	}
+
+	versions, err := h.PromptService.Versions(r.Context(), personaID)
+	if err != nil {
+		h.promptError(w, err)
+		return
+	}
+	active, err := h.PromptService.ActivePrompt(r.Context(), persona)
+	if err != nil {
+		h.promptError(w, err)
+		return
+	}
+
+	var from, to *domain.PromptVersion
+	for i, version := range versions {
+		if version.ID == active.ID && i+1 < len(versions) {
+			to, from = version, versions[i+1] // Versions are listed newest first
+		}
+	}
+	for param, target := range map[string]**domain.PromptVersion{"from": &from, "to": &to} {
+		value := r.URL.Query().Get(param)
+		if value == "" {
+			continue
+		}
+		versionID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid version ID", http.StatusBadRequest)
+			return
+		}
+		if *target, err = h.PromptService.Version(r.Context(), personaID, versionID); err != nil {
+			h.promptError(w, err)
+			return
+		}
+	}
+
+	data := map[string]interface{}{
+		"title":    "Prompt Diff",
+		"persona":  persona,
+		"versions": versions,
+		"from":     from,
+		"to":       to,
+	}
+	if from != nil && to != nil {
+		data["diff"] = services.DiffLines(from.Content, to.Content)
+	}
+	err = h.PromptDiffTemplate.Render(w, "base.html", data)
+	if err != nil {
+		log.Printf("Error rendering prompt diff template: %v", err)
+	}
+}
+
+// renderPromptEdit renders the prompt editor. An empty content starts from the active version.
+func (h *AdminHandlers) renderPromptEdit(w http.ResponseWriter, r *http.Request, personaID, content, note, formError string) {
+	persona, ok := h.personaByID(personaID)
+	if !ok {
+		http.Error(w, "Persona not found", http.StatusNotFound)
+		return
+	}
+	active, err := h.PromptService.ActivePrompt(r.Context(), persona)
+	if err != nil {
+		h.promptError(w, err)
+		return
+	}
+	versions, err := h.PromptService.Versions(r.Context(), personaID)
+	if err != nil {
+		h.promptError(w, err)
+		return
+	}
+	if content == "" {
+		content = active.Content
+	}
+
+	data := map[string]interface{}{
+		"title":    "Edit Prompt",
+		"persona":  persona,
+		"active":   active,
+		"versions": versions,
+		"content":  content,
+		"note":     note,
+		"error":    formError,
+	}
+	if formError != "" {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	err = h.PromptEditTemplate.Render(w, "base.html", data)
+	if err != nil {
+		log.Printf("Error rendering prompt edit template: %v", err)
+	}
+}
+
+func (h *AdminHandlers) personaByID(personaID string) (*domain.Persona, bool) {
+	for _, persona := range h.ChatService.Personas() {
+		if persona.ID == personaID {
+			return persona, true
+		}
+	}
+	return nil, false
+}
+
+// promptError maps errors of the prompt service to HTTP responses.
+func (h *AdminHandlers) promptError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnknownPersona):
+		http.Error(w, "Persona not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrPromptVersionNotFound):
+		http.Error(w, "Prompt version not found", http.StatusNotFound)
+	default:
+		log.Printf("Error handling prompt: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,239 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/handlers/admin_moderation_handler.go
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// moderationRow is a queue item together with the email of its assignee.
+type moderationRow struct {
+	Item     *domain.ModerationItem
+	Assignee string
+}
+
+// moderationEventRow is a change to an item together with the email of the admin who made it.
+type moderationEventRow struct {
+	Event domain.ModerationEvent
+	Actor string
+}
+
+// ModerationQueueHandler lists the review queue. It shows the open items unless the query asks
+// for another status; severity, source and assignee ("me" or a user ID) narrow it down.
+func (h *AdminHandlers) ModerationQueueHandler(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+	query := r.URL.Query()
+	filter := domain.ModerationFilter{
+		Status:   domain.ModerationOpen,
+		Severity: query.Get("severity"),
+		Source:   query.Get("source"),
+	}
+	if query.Has("status") {
+		filter.Status = query.Get("status") // Empty shows all
+	}
+	switch assignee := query.Get("assignee"); assignee {
+	case "":
+	case "me":
+		filter.AssigneeID = &adminID
+	default:
+		id, err := strconv.ParseInt(assignee, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid assignee", http.StatusBadRequest)
+			return
+		}
+		filter.AssigneeID = &id
+	}
+
+	items, err := h.ModerationService.Queue(r.Context(), filter)
+	if err != nil {
+		log.Printf("Error loading moderation queue: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	emails, _, err := h.adminEmails(r)
+	if err != nil {
+		log.Printf("Error loading admins: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	rows := make([]moderationRow, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, moderationRow{Item: item, Assignee: userLabel(emails, item.AssigneeID)})
+	}
+
+	data := map[string]interface{}{
+		"title":      "Review Queue",
+		"rows":       rows,
+		"filter":     filter,
+		"assignee":   query.Get("assignee"),
+		"severities": domain.Severities,
+		"sources": []string{
+			domain.ModerationSourceCrisis, domain.ModerationSourceSafetyBlock,
+			domain.ModerationSourceUserReport, domain.ModerationSourceLowFeedback,
+		},
+	}
+	err = h.ModerationTemplate.Render(w, "base.html", data)
+	if err != nil {
+		log.Printf("Error rendering moderation template: %v", err)
+	}
+}
+
+// ModerationItemHandler shows an item with its history and the forms to work on it.
+func (h *AdminHandlers) ModerationItemHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "itemID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+	item, err := h.ModerationService.Item(r.Context(), itemID)
+	if err != nil {
+		h.moderationError(w, err)
+		return
+	}
+	emails, admins, err := h.adminEmails(r)
+	if err != nil {
+		log.Printf("Error loading admins: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	events := make([]moderationEventRow, 0, len(item.Events))
+	for _, event := range item.Events {
+		actor := "system"
+		if event.ActorID != nil {
+			actor = userLabel(emails, event.ActorID)
+		}
+		events = append(events, moderationEventRow{Event: event, Actor: actor})
+	}
+
+	var assigneeID int64 // Preselects the assignee in the form; 0 for none
+	if item.AssigneeID != nil {
+		assigneeID = *item.AssigneeID
+	}
+
+	data := map[string]interface{}{
+		"title":      fmt.Sprintf("Review Item #%d", item.ID),
+		"item":       item,
+		"assignee":   userLabel(emails, item.AssigneeID),
+		"assigneeID": assigneeID,
+		"events":     events,
+		"admins":     admins,
+	}
+	err = h.ModerationItemTemplate.Render(w, "base.html", data)
+	if err != nil {
+		log.Printf("Error rendering moderation item template: %v", err)
+	}
+}
+
+// AssignModerationItemHandler assigns an item to the admin in the "assignee_id" field, or
+// unassigns it when the field is empty.
+func (h *AdminHandlers) AssignModerationItemHandler(w http.ResponseWriter, r *http.Request) {
+	h.changeModerationItem(w, r, func(itemID, adminID int64) error {
+		var assigneeID *int64
+		if value := r.PostForm.Get("assignee_id"); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return errInvalidForm
+			}
+			assigneeID = &id
+		}
+		return h.ModerationService.Assign(r.Context(), itemID, adminID, assigneeID)
+	})
+}
+
+// AnnotateModerationItemHandler adds the note in the "note" field to an item.
+func (h *AdminHandlers) AnnotateModerationItemHandler(w http.ResponseWriter, r *http.Request) {
+	h.changeModerationItem(w, r, func(itemID, adminID int64) error {
+		return h.ModerationService.Annotate(r.Context(), itemID, adminID, r.PostForm.Get("note"))
+	})
+}
+
+// ResolveModerationItemHandler resolves an item, with an optional note on what was done.
+func (h *AdminHandlers) ResolveModerationItemHandler(w http.ResponseWriter, r *http.Request) {
+	h.changeModerationItem(w, r, func(itemID, adminID int64) error {
+		return h.ModerationService.Resolve(r.Context(), itemID, adminID, r.PostForm.Get("note"))
+	})
+}
+
+// ReopenModerationItemHandler puts a resolved item back in the queue.
+func (h *AdminHandlers) ReopenModerationItemHandler(w http.ResponseWriter, r *http.Request) {
+	h.changeModerationItem(w, r, func(itemID, adminID int64) error {
+		return h.ModerationService.Reopen(r.Context(), itemID, adminID, r.PostForm.Get("note"))
+	})
+}
+
+var errInvalidForm = errors.New("invalid form")
+
+// changeModerationItem parses the form of a change to an item, applies it as the signed-in admin
+// and returns to the item.
+func (h *AdminHandlers) changeModerationItem(w http.ResponseWriter, r *http.Request, change func(itemID, adminID int64) error) {
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "itemID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	adminID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+
+	if err := change(itemID, adminID); err != nil {
+		h.moderationError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/moderation/"+strconv.FormatInt(itemID, 10), http.StatusSeeOther)
+}
+
+// adminEmails returns the email of every user by ID, for labelling assignees and actors,
+// together with the admins an item can be assigned to.
+func (h *AdminHandlers) adminEmails(r *http.Request) (map[int64]string, []*domain.User, error) {
+	users, err := h.UserRepo.GetAll(r.Context())
+	if err != nil {
+		return nil, nil, err
+	}
+	emails := make(map[int64]string, len(users))
+	var admins []*domain.User
+	for _, user := range users {
+		emails[user.ID] = user.Email
+		if user.Role == "admin" {
+			admins = append(admins, user)
+		}
+	}
+	return emails, admins, nil
+}
+
+// userLabel returns the email of a user, or "" for nil.
+func userLabel(emails map[int64]string, userID *int64) string {
+	if userID == nil {
+		return ""
+	}
+	if email, ok := emails[*userID]; ok {
+		return email
+	}
+	return fmt.Sprintf("user %d", *userID)
+}
+
+// moderationError maps errors of the moderation service to HTTP responses.
+func (h *AdminHandlers) moderationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrModerationItemNotFound):
+		http.Error(w, "Item not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrModerationItemResolved):
+		http.Error(w, "The item is resolved; reopen it first", http.StatusConflict)
+	case errors.Is(err, services.ErrModerationItemOpen):
+		http.Error(w, "The item is still open", http.StatusConflict)
+	case errors.Is(err, services.ErrEmptyNote), errors.Is(err, errInvalidForm):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error handling moderation item: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
@@ -2,32 +2,50 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
 	"github.com/DauletBai/oilan.org/internal/app/services"
 	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"github.com/DauletBai/oilan.org/internal/i18n"
 	"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
+const (
+	// postMessageTimeout bounds how long a message posted to the API may take, from waiting for the
+	// messages posted before it to saving the reply. A slow model can take far longer than the
+	// server's write timeout, so the route extends that to postMessageTimeout + responseWriteMargin.
+	postMessageTimeout = 3 * time.Minute
+	// postMessageLockTimeout bounds the wait for the messages posted before, so that a busy dialog
+	// is reported while the client still listens and leaves time to answer once it is free.
+	postMessageLockTimeout = 30 * time.Second
+	// responseWriteMargin is the time left to write the response after postMessageTimeout.
+	responseWriteMargin = 5 * time.Second
+)
+
 // APIHandlers holds all dependencies for API handlers.
 type APIHandlers struct {
 	chatService *services.ChatService
 	userRepo    repository.UserRepository
 	dialogRepo  repository.DialogRepository
+	rateLimiter *services.RateLimiter
+	lockTimeout time.Duration // See postMessageLockTimeout
 }
 
 // NewAPIHandlers creates a new instance of APIHandlers.
-func NewAPIHandlers(cs *services.ChatService, ur repository.UserRepository, dr repository.DialogRepository) *APIHandlers {
+func NewAPIHandlers(cs *services.ChatService, ur repository.UserRepository, dr repository.DialogRepository, rl *services.RateLimiter) *APIHandlers {
 	return &APIHandlers{
 		chatService: cs,
 		userRepo:    ur,
 		dialogRepo:  dr,
+		rateLimiter: rl,
+		lockTimeout: postMessageLockTimeout,
 	}
 }
 
@@ -45,6 +63,30 @@ func (h *APIHandlers) writeError(w http.ResponseWriter, status int, message stri
 	h.writeJSON(w, status, map[string]string{"error": message})
 }
 
+// writeQuotaError sends a 429 response that the frontend can tell apart from other errors.
+func (h *APIHandlers) writeQuotaError(w http.ResponseWriter, r *http.Request, quotaErr *services.QuotaExceededError) {
+	body := map[string]interface{}{
+		"error": quotaMessage(i18n.FromContext(r.Context()), quotaErr),
+		"code":  "quota_exceeded",
+		"limit": quotaErr.Limit,
+	}
+	if !quotaErr.ResetAt.IsZero() {
+		body["reset_at"] = quotaErr.ResetAt
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(quotaErr.ResetAt).Seconds())+1))
+	}
+	h.writeJSON(w, http.StatusTooManyRequests, body)
+}
+
+// quotaMessage returns a user-facing explanation of a quota error in the given locale.
+func quotaMessage(locale string, quotaErr *services.QuotaExceededError) string {
+	switch quotaErr.Limit {
+	case services.LimitMessagesPerDay, services.LimitTokensPerMonth, services.LimitConcurrentGenerations:
+		return i18n.T(locale, "quota."+quotaErr.Limit)
+	default:
+		return i18n.T(locale, "quota.exceeded")
+	}
+}
+
 // GetSessionInfoHandler provides the frontend with essential session data.
 func (h *APIHandlers) GetSessionInfoHandler(w http.ResponseWriter, r *http.Request) {
 	// The AuthMiddleware has already run and placed the user ID in the context.
@@ -86,7 +128,8 @@ func (h *APIHandlers) CreateDialogHandler(w http.ResponseWriter, r *http.Request
 	// }
 
 	var requestBody struct {
-		Title string `json:"title"`
+		Title     string `json:"title"`
+		PersonaID string `json:"persona_id"` // Optional; the default persona is used when empty
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
@@ -94,8 +137,12 @@ func (h *APIHandlers) CreateDialogHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	dialog, err := h.chatService.StartNewDialog(r.Context(), userID, requestBody.Title)
+	dialog, err := h.chatService.StartNewDialog(r.Context(), userID, requestBody.Title, requestBody.PersonaID)
 	if err != nil {
+		if errors.Is(err, services.ErrUnknownPersona) {
+			h.writeError(w, http.StatusBadRequest, "Unknown persona")
+			return
+		}
 		log.Printf("Error creating dialog: %v", err)
 		h.writeError(w, http.StatusInternalServerError, "Could not create dialog")
 		return
@@ -104,6 +151,28 @@ func (h *APIHandlers) CreateDialogHandler(w http.ResponseWriter, r *http.Request
 	h.writeJSON(w, http.StatusCreated, dialog)
 }
 
+// GetPersonasHandler lists the personas a new dialog can be started with.
+func (h *APIHandlers) GetPersonasHandler(w http.ResponseWriter, r *http.Request) {
+	type personaInfo struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Default     bool   `json:"default"`
+	}
+
+	defaultID := h.chatService.DefaultPersona().ID
+	personas := make([]personaInfo, 0, len(h.chatService.Personas()))
+	for _, persona := range h.chatService.Personas() {
+		personas = append(personas, personaInfo{
+			ID:          persona.ID,
+			Name:        persona.Name,
+			Description: persona.Description,
+			Default:     persona.ID == defaultID,
+		})
+	}
+	h.writeJSON(w, http.StatusOK, personas)
+}
+
 // PostMessageHandler now gets the user ID from the request context.
 func (h *APIHandlers) PostMessageHandler(w http.ResponseWriter, r *http.Request) {
 	userID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
@@ -134,12 +203,43 @@ func (h *APIHandlers) PostMessageHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	aiMessage, err := h.chatService.PostMessage(r.Context(), dialogID, userID, requestBody.Content)
+	// The reply is sent in the response, so the route outlasts the server's write timeout; the
+	// request is cut off in time for an error to reach the client.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(postMessageTimeout + responseWriteMargin)); err != nil {
+		log.Printf("Could not extend the write deadline of a message to dialog %d: %v", dialogID, err)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), postMessageTimeout)
+	defer cancel()
+	ctx = services.WithLockTimeout(ctx, h.lockTimeout)
+
+	aiMessage, err := h.chatService.PostMessage(ctx, dialogID, userID, requestBody.Content)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, services.ErrDialogNotFound) {
 			h.writeError(w, http.StatusNotFound, "Dialog not found")
 			return
 		}
+		if errors.Is(err, services.ErrDialogAccessDenied) {
+			h.writeError(w, http.StatusForbidden, "Access denied")
+			return
+		}
+		var quotaErr *services.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			h.writeQuotaError(w, r, quotaErr)
+			return
+		}
+		if errors.Is(err, services.ErrDialogBusy) {
+			h.writeError(w, http.StatusConflict, "The previous message is still being answered, please try again")
+			return
+		}
+		if errors.Is(err, services.ErrResponseBlocked) {
+			h.writeError(w, http.StatusUnprocessableEntity, "The AI could not respond to this message")
+			return
+		}
+		if errors.Is(err, services.ErrLLMUnavailable) {
+			log.Printf("Error posting message: %v", err)
+			h.writeError(w, http.StatusServiceUnavailable, "The AI is temporarily unavailable, please try again later")
+			return
+		}
 		log.Printf("Error posting message: %v", err)
 		h.writeError(w, http.StatusInternalServerError, "Failed to process message")
 		return
@@ -0,0 +1,97 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/handlers/api_handlers_test.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// slowLLM answers every request after a delay.
+type slowLLM struct {
+	delay time.Duration
+}
+
+func (c *slowLLM) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*services.LLMResponse, error) {
+	select {
+	case <-time.After(c.delay):
+		return &services.LLMResponse{Content: "I hear you.", Provider: "stub", FinishReason: services.FinishReasonStop}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *slowLLM) StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk services.StreamHandler) (*services.LLMResponse, error) {
+	return c.GenerateResponse(ctx, history, systemPrompt)
+}
+
+func TestPostMessageHandler(t *testing.T) {
+	const userID = 7
+	repo := newMemoryDialogRepo()
+	locks := services.NewDialogLocks(nil)
+	llm := &slowLLM{delay: 300 * time.Millisecond}
+	chatService, err := services.NewChatService(repo, nil, llm, services.DefaultContextBudget(), nil, newTestPersonas(t), nil, nil, nil, nil, nil, locks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialog, err := chatService.StartNewDialog(context.Background(), userID, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewAPIHandlers(chatService, nil, repo, nil)
+	h.lockTimeout = 50 * time.Millisecond
+	router := chi.NewRouter()
+	router.Method(http.MethodPost, "/dialogs/{dialogID}/messages", asUser(userID, h.PostMessageHandler))
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = 100 * time.Millisecond // Shorter than a reply takes
+	server.Start()
+	defer server.Close()
+
+	post := func(t *testing.T) (*http.Response, map[string]interface{}) {
+		t.Helper()
+		url := server.URL + "/dialogs/" + strconv.FormatInt(dialog.ID, 10) + "/messages"
+		resp, err := http.Post(url, "application/json", strings.NewReader(`{"content": "Hello"}`))
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		defer resp.Body.Close()
+		var body map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return resp, body
+	}
+
+	t.Run("busy dialog", func(t *testing.T) {
+		unlock, err := locks.Lock(context.Background(), dialog.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer unlock()
+
+		resp, body := post(t)
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("status = %d (%v), want %d", resp.StatusCode, body, http.StatusConflict)
+		}
+	})
+
+	t.Run("reply slower than the write timeout", func(t *testing.T) {
+		resp, body := post(t)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d (%v), want %d", resp.StatusCode, body, http.StatusOK)
+		}
+		if body["content"] != "I hear you." {
+			t.Errorf("reply = %v, want the AI message", body)
+		}
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"github.com/DauletBai/oilan.org/internal/auth" 
 	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
 	"time"
 
 	"github.com/markbates/goth/gothic"
@@ -47,6 +48,11 @@ func (h *APIHandlers) AuthCallbackHandler(w http.ResponseWriter, r *http.Request
 	} else {
 		user = existingUser
 	}
+
+	// The language of the profile applies in every browser the user signs in with.
+	if user.Language != "" {
+		middleware.SetLocaleCookie(w, user.Language)
+	}
 	
 	tokenString, err := auth.GenerateToken(user)
 	if err != nil {
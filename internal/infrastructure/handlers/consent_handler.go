@@ -0,0 +1,226 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/handlers/consent_handler.go
+package handlers
+
+import (
+	"errors"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/i18n"
+	"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// documentView is a legal document in the language of the request, together with where the
+// user stands with it.
+type documentView struct {
+	Kind        string
+	Version     int
+	Required    bool
+	Title       string
+	Paragraphs  []string
+	PublishedAt time.Time
+
+	Accepted        bool      // Some version is accepted
+	Current         bool      // The current version is accepted
+	AcceptedVersion int       // The version the latest ledger entry refers to
+	DecidedAt       time.Time // When the latest ledger entry was made; zero without one
+}
+
+func newDocumentView(doc *domain.LegalDocument, locale string) documentView {
+	var paragraphs []string
+	for _, paragraph := range strings.Split(i18n.Localized(doc.Body, locale), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	return documentView{
+		Kind:        doc.Kind,
+		Version:     doc.Version,
+		Required:    doc.Required,
+		Title:       i18n.Localized(doc.Title, locale),
+		Paragraphs:  paragraphs,
+		PublishedAt: doc.PublishedAt,
+	}
+}
+
+func newStatusView(status services.ConsentStatus, locale string) documentView {
+	view := newDocumentView(status.Document, locale)
+	view.Accepted = status.Accepted()
+	view.Current = status.Current()
+	if status.Consent != nil {
+		view.AcceptedVersion = status.Consent.Version
+		view.DecidedAt = status.Consent.CreatedAt
+	}
+	return view
+}
+
+// safeNext returns the page to return to after the consent page, which must be on this site.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/chat"
+	}
+	return next
+}
+
+// ConsentHandler shows the documents a user has to accept before chatting, together with the
+// optional ones they have not decided on yet.
+func (h *PageHandlers) ConsentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+	locale := i18n.FromContext(r.Context())
+	next := safeNext(r.URL.Query().Get("next"))
+
+	statuses, err := h.ConsentService.Statuses(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error loading consents: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	var documents []documentView
+	pending := false
+	for _, status := range statuses {
+		if status.Document.Required && !status.Current() {
+			pending = true
+		} else if status.Document.Required || status.Consent != nil {
+			continue // Accepted, or an optional document the user has decided on
+		}
+		documents = append(documents, newStatusView(status, locale))
+	}
+	if !pending {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	data := map[string]interface{}{
+		"title":     i18n.T(locale, "consent.title"),
+		"locale":    locale,
+		"documents": documents,
+		"next":      next,
+		"missing":   r.URL.Query().Has("missing"),
+	}
+	if err := h.ConsentTemplate.Render(w, "base.html", data); err != nil {
+		log.Printf("Error rendering consent template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// AcceptConsentHandler records the documents checked in the "kind" fields of the form as accepted,
+// then returns to the page in "next". While a required document is still missing, the user is
+// sent back to the consent page.
+func (h *PageHandlers) AcceptConsentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	next := safeNext(r.PostForm.Get("next"))
+
+	if err := h.ConsentService.Accept(r.Context(), userID, r.PostForm["kind"]); err != nil {
+		if errors.Is(err, services.ErrUnknownDocument) {
+			http.Error(w, "Unknown document", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error recording consent: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	pending, err := h.ConsentService.Pending(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error checking consents: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if len(pending) > 0 {
+		http.Redirect(w, r, middleware.ConsentPath+"?missing=1&next="+url.QueryEscape(next), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// ConsentsHandler lists every document with the version the user accepted and when, and lets
+// them accept or withdraw the optional ones.
+func (h *PageHandlers) ConsentsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+	locale := i18n.FromContext(r.Context())
+
+	statuses, err := h.ConsentService.Statuses(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error loading consents: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	documents := make([]documentView, 0, len(statuses))
+	for _, status := range statuses {
+		documents = append(documents, newStatusView(status, locale))
+	}
+
+	data := map[string]interface{}{
+		"title":     i18n.T(locale, "consents.title"),
+		"locale":    locale,
+		"documents": documents,
+	}
+	if err := h.ConsentsTemplate.Render(w, "base.html", data); err != nil {
+		log.Printf("Error rendering consents template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// WithdrawConsentHandler withdraws the user's consent to an optional document.
+func (h *PageHandlers) WithdrawConsentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+
+	err := h.ConsentService.Withdraw(r.Context(), userID, chi.URLParam(r, "kind"))
+	switch {
+	case errors.Is(err, services.ErrUnknownDocument):
+		http.Error(w, "Unknown document", http.StatusNotFound)
+	case errors.Is(err, services.ErrConsentRequired):
+		http.Error(w, "This document is required and cannot be withdrawn", http.StatusBadRequest)
+	case err != nil:
+		log.Printf("Error withdrawing consent: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	default:
+		http.Redirect(w, r, "/consents", http.StatusSeeOther)
+	}
+}
+
+// LegalDocumentHandler shows the current version of a document, or the one in the "version" query.
+func (h *PageHandlers) LegalDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	locale := i18n.FromContext(r.Context())
+	version := 0
+	if v := r.URL.Query().Get("version"); v != "" {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil || version < 1 {
+			http.Error(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+	}
+
+	doc, err := h.ConsentService.Document(r.Context(), chi.URLParam(r, "kind"), version)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownDocument) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Error loading legal document: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	document := newDocumentView(doc, locale)
+	data := map[string]interface{}{
+		"title":    document.Title,
+		"locale":   locale,
+		"document": document,
+	}
+	if err := h.LegalTemplate.Render(w, "base.html", data); err != nil {
+		log.Printf("Error rendering legal document template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
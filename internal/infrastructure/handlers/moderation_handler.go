@@ -0,0 +1,91 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/handlers/moderation_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// messageParams reads the IDs of the dialog and message a request refers to.
+func messageParams(r *http.Request) (int64, int64, bool) {
+	dialogID, err := strconv.ParseInt(chi.URLParam(r, "dialogID"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	messageID, err := strconv.ParseInt(chi.URLParam(r, "messageID"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return dialogID, messageID, true
+}
+
+// ReportMessageHandler lets a user report a message of their dialog to the admins.
+func (h *APIHandlers) ReportMessageHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+	dialogID, messageID, ok := messageParams(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "Invalid dialog or message ID")
+		return
+	}
+	var requestBody struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := h.chatService.ReportMessage(r.Context(), dialogID, userID, messageID, requestBody.Reason); err != nil {
+		h.writeModerationError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+// RateMessageHandler stores the score, from 1 to 5, a user gave an AI message.
+func (h *APIHandlers) RateMessageHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+	dialogID, messageID, ok := messageParams(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "Invalid dialog or message ID")
+		return
+	}
+	var requestBody struct {
+		Score   int    `json:"score"`
+		Comment string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := h.chatService.RateMessage(r.Context(), dialogID, userID, messageID, requestBody.Score, requestBody.Comment); err != nil {
+		h.writeModerationError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+// writeModerationError maps the errors of reports and scores to status codes.
+func (h *APIHandlers) writeModerationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrDialogNotFound):
+		h.writeError(w, http.StatusNotFound, "Dialog not found")
+	case errors.Is(err, services.ErrDialogAccessDenied):
+		h.writeError(w, http.StatusForbidden, "Access denied")
+	case errors.Is(err, services.ErrMessageNotFound):
+		h.writeError(w, http.StatusNotFound, "Message not found")
+	case errors.Is(err, services.ErrInvalidScore):
+		h.writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("Error handling moderation request: %v", err)
+		h.writeError(w, http.StatusInternalServerError, "Could not process request")
+	}
+}
@@ -2,6 +2,8 @@
 package handlers
 
 import (
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/i18n"
 	"log"
 	"net/http"
 	"github.com/DauletBai/oilan.org/internal/view"
@@ -9,13 +11,18 @@ import (
 
 // PageHandlers holds dependencies for page rendering handlers.
 type PageHandlers struct {
-	WelcomeTemplate *view.Template
-	ChatTemplate    *view.Template // <-- Add the chat template
+	WelcomeTemplate  *view.Template
+	ChatTemplate     *view.Template // <-- Add the chat template
+	ConsentTemplate  *view.Template
+	ConsentsTemplate *view.Template
+	LegalTemplate    *view.Template
+	ConsentService   *services.ConsentService
 }
 
 // WelcomeHandler renders the main welcome page.
 func (h *PageHandlers) WelcomeHandler(w http.ResponseWriter, r *http.Request) {
-	data := map[string]interface{}{"title": "Welcome"}
+	locale := i18n.FromContext(r.Context())
+	data := map[string]interface{}{"title": i18n.T(locale, "welcome.title"), "locale": locale}
 	err := h.WelcomeTemplate.Render(w, "base.html", data)
 	if err != nil {
 		log.Printf("Error rendering welcome template: %v", err)
@@ -25,7 +32,8 @@ func (h *PageHandlers) WelcomeHandler(w http.ResponseWriter, r *http.Request) {
 
 // ChatHandler renders the chat page.
 func (h *PageHandlers) ChatHandler(w http.ResponseWriter, r *http.Request) {
-	data := map[string]interface{}{"title": "Chat"}
+	locale := i18n.FromContext(r.Context())
+	data := map[string]interface{}{"title": i18n.T(locale, "chat.title"), "locale": locale}
 	err := h.ChatTemplate.Render(w, "base.html", data)
 	if err != nil {
 		log.Printf("Error rendering chat template: %v", err)
@@ -0,0 +1,123 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/handlers/phase_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/i18n"
+	"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// phaseInfo describes a phase in the user's language.
+type phaseInfo struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// phaseState is where a guided session stands. Phase is nil for dialogs without phases.
+type phaseState struct {
+	Flow   string      `json:"flow,omitempty"`
+	Phase  *phaseInfo  `json:"phase"`
+	Next   []phaseInfo `json:"next"`   // Phases the user can move on to
+	Phases []phaseInfo `json:"phases"` // All phases of the flow, in order
+}
+
+func newPhaseInfo(phase *domain.Phase, locale string) phaseInfo {
+	return phaseInfo{
+		ID:          phase.ID,
+		Name:        i18n.Localized(phase.Name, locale),
+		Description: i18n.Localized(phase.Description, locale),
+	}
+}
+
+func newPhaseState(flow *domain.Flow, phase *domain.Phase, locale string) phaseState {
+	state := phaseState{Next: []phaseInfo{}, Phases: []phaseInfo{}}
+	if flow == nil {
+		return state
+	}
+	current := newPhaseInfo(phase, locale)
+	state.Flow = flow.ID
+	state.Phase = &current
+	for _, p := range flow.Phases {
+		info := newPhaseInfo(p, locale)
+		state.Phases = append(state.Phases, info)
+		for _, next := range phase.Next {
+			if next == p.ID {
+				state.Next = append(state.Next, info)
+			}
+		}
+	}
+	return state
+}
+
+// GetPhaseHandler returns the current phase of a dialog and the phases the user can move on to.
+func (h *APIHandlers) GetPhaseHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+	dialogID, err := strconv.ParseInt(chi.URLParam(r, "dialogID"), 10, 64)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid dialog ID")
+		return
+	}
+
+	flow, phase, err := h.chatService.DialogPhase(r.Context(), dialogID, userID)
+	if err != nil {
+		h.writePhaseError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newPhaseState(flow, phase, i18n.FromContext(r.Context())))
+}
+
+// ChangePhaseHandler moves a dialog to the phase the user chose.
+func (h *APIHandlers) ChangePhaseHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+	dialogID, err := strconv.ParseInt(chi.URLParam(r, "dialogID"), 10, 64)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid dialog ID")
+		return
+	}
+	var requestBody struct {
+		Phase string `json:"phase"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if _, err := h.chatService.ChangePhase(r.Context(), dialogID, userID, requestBody.Phase); err != nil {
+		h.writePhaseError(w, err)
+		return
+	}
+	flow, phase, err := h.chatService.DialogPhase(r.Context(), dialogID, userID)
+	if err != nil {
+		h.writePhaseError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newPhaseState(flow, phase, i18n.FromContext(r.Context())))
+}
+
+// writePhaseError maps the errors of phase requests to status codes.
+func (h *APIHandlers) writePhaseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrDialogNotFound):
+		h.writeError(w, http.StatusNotFound, "Dialog not found")
+	case errors.Is(err, services.ErrDialogAccessDenied):
+		h.writeError(w, http.StatusForbidden, "Access denied")
+	case errors.Is(err, services.ErrNoPhases):
+		h.writeError(w, http.StatusBadRequest, "This dialog has no phases")
+	case errors.Is(err, services.ErrPhaseTransitionNotAllowed):
+		h.writeError(w, http.StatusBadRequest, "This phase cannot be chosen now")
+	case errors.Is(err, services.ErrPhaseChanged):
+		h.writeError(w, http.StatusConflict, "The phase has just changed, please try again")
+	default:
+		log.Printf("Error handling phase request: %v", err)
+		h.writeError(w, http.StatusInternalServerError, "Could not process phase request")
+	}
+}
@@ -0,0 +1,78 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/handlers/profile_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/DauletBai/oilan.org/internal/i18n"
+	"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	_ "time/tzdata" // The runtime image has no zoneinfo; without it only "UTC" would be accepted
+)
+
+// profileResponse is the part of the user's profile the user can see and change.
+type profileResponse struct {
+	Email    string `json:"email"`
+	Language string `json:"language"`
+	Timezone string `json:"timezone"`
+}
+
+// GetProfileHandler returns the profile settings of the authenticated user.
+func (h *APIHandlers) GetProfileHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+	user, err := h.userRepo.FindByID(r.Context(), userID)
+	if err != nil || user == nil {
+		h.writeError(w, http.StatusInternalServerError, "Could not retrieve profile")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, profileResponse{Email: user.Email, Language: user.Language, Timezone: user.Timezone})
+}
+
+// UpdateProfileHandler changes the preferred language and the timezone of the authenticated user.
+// Fields that are left out of the request keep their current value.
+func (h *APIHandlers) UpdateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middleware.UserIDContextKey).(int64)
+
+	var requestBody struct {
+		Language *string `json:"language"`
+		Timezone *string `json:"timezone"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := h.userRepo.FindByID(r.Context(), userID)
+	if err != nil || user == nil {
+		h.writeError(w, http.StatusInternalServerError, "Could not retrieve profile")
+		return
+	}
+	if requestBody.Language != nil {
+		language := strings.ToLower(strings.TrimSpace(*requestBody.Language))
+		if language != "" && !i18n.IsSupported(language) {
+			h.writeError(w, http.StatusBadRequest, "Unsupported language")
+			return
+		}
+		user.Language = language
+	}
+	if requestBody.Timezone != nil {
+		timezone := strings.TrimSpace(*requestBody.Timezone)
+		if _, err := time.LoadLocation(timezone); err != nil || timezone == "Local" {
+			h.writeError(w, http.StatusBadRequest, "Unknown timezone")
+			return
+		}
+		user.Timezone = timezone
+	}
+
+	if err := h.userRepo.Update(r.Context(), user); err != nil {
+		log.Printf("Error updating profile of user %d: %v", userID, err)
+		h.writeError(w, http.StatusInternalServerError, "Could not update profile")
+		return
+	}
+	if requestBody.Language != nil {
+		middleware.SetLocaleCookie(w, user.Language)
+	}
+	h.writeJSON(w, http.StatusOK, profileResponse{Email: user.Email, Language: user.Language, Timezone: user.Timezone})
+}
@@ -3,6 +3,7 @@ package handlers
 
 import (
 	"net/http"
+	"github.com/DauletBai/oilan.org/internal/app/services"
 	"github.com/DauletBai/oilan.org/internal/domain/repository"
 	"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
 
@@ -10,31 +11,56 @@ import (
 )
 
 // RegisterRoutes now uses a cleaner structure for middleware.
-func RegisterRoutes(api *APIHandlers, pages *PageHandlers, admin *AdminHandlers, userRepo repository.UserRepository) http.Handler {
+func RegisterRoutes(api *APIHandlers, pages *PageHandlers, admin *AdminHandlers, userRepo repository.UserRepository, proxies middleware.TrustedProxies) http.Handler {
 	r := chi.NewRouter()
+	locale := middleware.LocaleMiddleware
+	consent := middleware.ConsentMiddleware(pages.ConsentService)
+	limit := func(policy string, key middleware.RateLimitKey) func(http.Handler) http.Handler {
+		return middleware.RateLimitMiddleware(api.rateLimiter, policy, key)
+	}
+	limitAuth := limit(services.RateLimitAuth, middleware.ByIP(proxies))
 
 	// Public Routes
 	r.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.Dir("./web/static"))))
-	r.Get("/", pages.WelcomeHandler)
-	r.Get("/auth/{provider}", api.BeginAuthHandler)
-	r.Get("/auth/{provider}/callback", api.AuthCallbackHandler)
+	r.With(locale).Get("/", pages.WelcomeHandler)
+	r.With(locale).Get("/legal/{kind}", pages.LegalDocumentHandler)
+	r.With(limitAuth).Get("/auth/{provider}", api.BeginAuthHandler)
+	r.With(limitAuth).Get("/auth/{provider}/callback", api.AuthCallbackHandler)
 
 	// Authenticated Routes
 	// All routes inside this group will first pass through AuthMiddleware.
 	r.Group(func(r chi.Router) {
 		r.Use(middleware.AuthMiddleware)
+		r.Use(locale)
 
-		// Regular authenticated pages
-		r.Get("/chat", pages.ChatHandler)
-		r.Get("/ws/chat", api.ServeWs)
+		// Legal documents every user has to accept, and the optional consents they can withdraw
+		r.Get("/consent", pages.ConsentHandler)
+		r.Post("/consent", pages.AcceptConsentHandler)
+		r.Get("/consents", pages.ConsentsHandler)
+		r.Post("/consents/{kind}/withdraw", pages.WithdrawConsentHandler)
+
+		// Regular authenticated pages; chatting requires the current required documents to be accepted
+		r.With(consent).Get("/chat", pages.ChatHandler)
+		r.With(limit(services.RateLimitWebSocket, middleware.ByUser), consent).Get("/ws/chat", api.ServeWs)
 		
 		// Authenticated API endpoints
 		r.Route("/api/v1", func(r chi.Router) {
+			r.Use(limit(services.RateLimitAPI, middleware.ByUser))
 			r.Get("/session", api.GetSessionInfoHandler)
-			r.Post("/dialogs", api.CreateDialogHandler)
-			r.Get("/dialogs", api.GetDialogsHandler)
-			r.Post("/dialogs/{dialogID}/messages", api.PostMessageHandler)
-			r.Get("/dialogs/{dialogID}", api.GetDialogByIDHandler)
+			r.Get("/personas", api.GetPersonasHandler)
+			r.Get("/profile", api.GetProfileHandler)
+			r.Put("/profile", api.UpdateProfileHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(consent)
+				r.Post("/dialogs", api.CreateDialogHandler)
+				r.Get("/dialogs", api.GetDialogsHandler)
+				r.With(limit(services.RateLimitMessages, middleware.ByUser)).Post("/dialogs/{dialogID}/messages", api.PostMessageHandler)
+				r.Get("/dialogs/{dialogID}", api.GetDialogByIDHandler)
+				r.Get("/dialogs/{dialogID}/phase", api.GetPhaseHandler)
+				r.Post("/dialogs/{dialogID}/phase", api.ChangePhaseHandler)
+				r.Post("/dialogs/{dialogID}/messages/{messageID}/report", api.ReportMessageHandler)
+				r.Post("/dialogs/{dialogID}/messages/{messageID}/feedback", api.RateMessageHandler)
+			})
 		})
 
 		// --- Admin Routes ---
@@ -43,8 +69,22 @@ func RegisterRoutes(api *APIHandlers, pages *PageHandlers, admin *AdminHandlers,
 			r.Use(middleware.AdminMiddleware(userRepo))
 			r.Get("/dashboard", admin.DashboardHandler)
 			r.Get("/users", admin.UsersHandler)
+			r.Get("/users/{userID}/quota", admin.UserQuotaHandler)
+			r.Post("/users/{userID}/quota", admin.UpdateUserQuotaHandler)
 			r.Get("/dialogs", admin.DialogsHandler)
 			r.Get("/dialogs/{dialogID}", admin.DialogViewHandler)
+			r.Get("/dialogs/{dialogID}/context", admin.DialogContextHandler)
+			r.Get("/prompts", admin.PromptsHandler)
+			r.Get("/prompts/{personaID}", admin.PromptEditHandler)
+			r.Post("/prompts/{personaID}", admin.SavePromptHandler)
+			r.Get("/prompts/{personaID}/diff", admin.PromptDiffHandler)
+			r.Post("/prompts/{personaID}/versions/{versionID}/activate", admin.ActivatePromptHandler)
+			r.Get("/moderation", admin.ModerationQueueHandler)
+			r.Get("/moderation/{itemID}", admin.ModerationItemHandler)
+			r.Post("/moderation/{itemID}/assign", admin.AssignModerationItemHandler)
+			r.Post("/moderation/{itemID}/notes", admin.AnnotateModerationItemHandler)
+			r.Post("/moderation/{itemID}/resolve", admin.ResolveModerationItemHandler)
+			r.Post("/moderation/{itemID}/reopen", admin.ReopenModerationItemHandler)
 		})
 	})
 
@@ -2,10 +2,17 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/i18n"
 	"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
-	//"strconv"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/websocket"
 )
@@ -16,6 +23,38 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin:     func(r *http.Request) bool { return true },
 }
 
+// resolveDialog attaches the connection to the dialog requested in the "dialogID" query parameter,
+// or starts a new dialog with the persona from the optional "persona" parameter when none is given.
+func (h *APIHandlers) resolveDialog(r *http.Request, userID int64) (*domain.Dialog, int, error) {
+	dialogIDStr := r.URL.Query().Get("dialogID")
+	if dialogIDStr == "" {
+		dialog, err := h.chatService.StartNewDialog(r.Context(), userID, "New WebSocket Chat", r.URL.Query().Get("persona"))
+		if errors.Is(err, services.ErrUnknownPersona) {
+			return nil, http.StatusBadRequest, err
+		}
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return dialog, http.StatusOK, nil
+	}
+
+	dialogID, err := strconv.ParseInt(dialogIDStr, 10, 64)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	dialog, err := h.chatService.GetDialog(r.Context(), dialogID, userID)
+	switch {
+	case errors.Is(err, services.ErrDialogNotFound):
+		return nil, http.StatusNotFound, err
+	case errors.Is(err, services.ErrDialogAccessDenied):
+		return nil, http.StatusForbidden, err
+	case err != nil:
+		return nil, http.StatusInternalServerError, err
+	}
+	return dialog, http.StatusOK, nil
+}
+
 func (h *APIHandlers) ServeWs(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int64)
 	if !ok {
@@ -23,6 +62,15 @@ func (h *APIHandlers) ServeWs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The dialog is resolved before the upgrade so that errors can still be reported with a status code.
+	dialog, status, err := h.resolveDialog(r, userID)
+	if err != nil {
+		log.Printf("Failed to bind dialog for user %d: %v", userID, err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	dialogID := dialog.ID
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
@@ -30,35 +78,143 @@ func (h *APIHandlers) ServeWs(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	dialog, err := h.chatService.StartNewDialog(r.Context(), userID, "New WebSocket Chat")
-	if err != nil {
-		log.Printf("Failed to create new dialog for user %d: %v", userID, err)
+	log.Printf("User %d connected to dialog %d via WebSocket", userID, dialogID)
+	bound := newFrame(FrameDialogBound)
+	bound.DialogID = dialogID
+	if err := conn.WriteJSON(bound); err != nil {
+		log.Println("Write error:", err)
 		return
 	}
-	dialogID := dialog.ID
-	log.Printf("User %d connected to new dialog %d via WebSocket", userID, dialogID)
-	
-	conn.WriteMessage(websocket.TextMessage, []byte("Hello! I am ready. How can I help you today?"))
+
+	// Only greet the user in an empty dialog; a resumed dialog already shows its history.
+	if len(dialog.Messages) == 0 {
+		greeting := newFrame(FrameGreeting)
+		greeting.DialogID = dialogID
+		greeting.Content = i18n.T(i18n.FromContext(r.Context()), "chat.greeting")
+		conn.WriteJSON(greeting)
+	}
 
 	for {
-		_, msg, err := conn.ReadMessage()
-		if err != nil {
+		var in Frame
+		if err := conn.ReadJSON(&in); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) || errors.As(err, new(*json.UnmarshalTypeError)) {
+				writeWsError(conn, "", ErrCodeBadFrame, "Malformed frame.")
+				continue
+			}
 			log.Printf("User %d disconnected from dialog %d", userID, dialogID)
 			break
 		}
 
-		// --- THE CRITICAL FIX ---
-		// We now call PostMessage, which is designed to handle the full cycle.
-		aiResponse, err := h.chatService.PostMessage(r.Context(), dialogID, userID, string(msg))
+		switch {
+		case in.V != ProtocolVersion:
+			writeWsError(conn, in.ClientID, ErrCodeUnsupportedVersion, "Unsupported protocol version.")
+			continue
+		case in.Type != FrameUserMessage:
+			writeWsError(conn, in.ClientID, ErrCodeBadFrame, "Unexpected frame type.")
+			continue
+		case strings.TrimSpace(in.Content) == "":
+			writeWsError(conn, in.ClientID, ErrCodeEmptyMessage, "Message cannot be empty.")
+			continue
+		}
+
+		// Messages over the WebSocket draw from the same bucket as those posted to the API.
+		client, _ := middleware.ByUser(r)
+		allowed, retryAfter, err := h.rateLimiter.Allow(r.Context(), services.RateLimitMessages, client)
 		if err != nil {
-			log.Println("ChatService error:", err)
-			conn.WriteMessage(websocket.TextMessage, []byte("Sorry, an error occurred."))
+			log.Printf("Error checking message rate of user %d: %v", userID, err)
+		} else if !allowed {
+			seconds := middleware.RetryAfterSeconds(retryAfter)
+			frame := newFrame(FrameError)
+			frame.ClientID = in.ClientID
+			frame.Code = ErrCodeRateLimited
+			frame.Content = i18n.T(i18n.FromContext(r.Context()), "ratelimit.exceeded", seconds)
+			frame.RetryAfter = seconds
+			if err := conn.WriteJSON(frame); err != nil {
+				log.Println("Write error:", err)
+				break
+			}
 			continue
 		}
 
-		if err := conn.WriteMessage(websocket.TextMessage, []byte(aiResponse.Content)); err != nil {
+		if err := h.handleUserMessage(r.Context(), conn, userID, dialogID, in); err != nil {
 			log.Println("Write error:", err)
 			break
 		}
 	}
-}
\ No newline at end of file
+}
+
+// handleUserMessage runs one user message through the chat service and streams the reply back.
+// It only returns an error when the connection itself is broken.
+func (h *APIHandlers) handleUserMessage(ctx context.Context, conn *websocket.Conn, userID, dialogID int64, in Frame) error {
+	var replyTo int64
+	hooks := services.MessageHooks{
+		OnUserMessage: func(msg *domain.Message) error {
+			replyTo = msg.ID
+			ack := newFrame(FrameAck)
+			ack.ClientID = in.ClientID
+			ack.DialogID = dialogID
+			ack.MessageID = msg.ID
+			if err := conn.WriteJSON(ack); err != nil {
+				return err
+			}
+
+			typing := newFrame(FrameTyping)
+			typing.ClientID = in.ClientID
+			typing.ReplyTo = msg.ID
+			return conn.WriteJSON(typing)
+		},
+		// Partial chunks are forwarded to the browser as soon as the LLM produces them.
+		OnChunk: func(chunk string) error {
+			frame := newFrame(FrameAIChunk)
+			frame.ClientID = in.ClientID
+			frame.ReplyTo = replyTo
+			frame.Content = chunk
+			return conn.WriteJSON(frame)
+		},
+		// The client reloads the phase bar; a broken connection shows up with the next frame.
+		OnPhaseChange: func(transition *domain.PhaseTransition) {
+			frame := newFrame(FramePhase)
+			frame.ClientID = in.ClientID
+			frame.DialogID = dialogID
+			frame.Phase = transition.To
+			conn.WriteJSON(frame)
+		},
+	}
+
+	aiResponse, err := h.chatService.PostMessageStream(ctx, dialogID, userID, in.Content, hooks)
+	if err != nil {
+		log.Println("ChatService error:", err)
+		locale := i18n.FromContext(ctx)
+		var quotaErr *services.QuotaExceededError
+		switch {
+		case errors.As(err, &quotaErr):
+			return writeWsError(conn, in.ClientID, ErrCodeQuotaExceeded, quotaMessage(locale, quotaErr))
+		case errors.Is(err, services.ErrDialogBusy):
+			return writeWsError(conn, in.ClientID, ErrCodeDialogBusy, i18n.T(locale, "chat.dialog_busy"))
+		case errors.Is(err, services.ErrResponseBlocked):
+			return writeWsError(conn, in.ClientID, ErrCodeResponseBlocked, i18n.T(locale, "chat.response_blocked"))
+		case errors.Is(err, services.ErrLLMUnavailable):
+			return writeWsError(conn, in.ClientID, ErrCodeLLMUnavailable, i18n.T(locale, "chat.llm_unavailable"))
+		default:
+			return writeWsError(conn, in.ClientID, ErrCodeGenerationFailed, i18n.T(locale, "chat.generation_failed"))
+		}
+	}
+
+	done := newFrame(FrameAIDone)
+	done.ClientID = in.ClientID
+	done.DialogID = dialogID
+	done.MessageID = aiResponse.ID
+	done.ReplyTo = replyTo
+	done.Content = aiResponse.Content
+	return conn.WriteJSON(done)
+}
+
+// writeWsError sends an error frame, which the client renders as a system notice.
+func writeWsError(conn *websocket.Conn, clientID string, code ErrorCode, message string) error {
+	frame := newFrame(FrameError)
+	frame.ClientID = clientID
+	frame.Code = code
+	frame.Content = message
+	return conn.WriteJSON(frame)
+}
@@ -0,0 +1,182 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/handlers/websocket_handler_test.go
+package handlers
+
+import (
+	"context"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/i18n"
+	"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// memoryDialogRepo is an in-memory DialogRepository.
+type memoryDialogRepo struct {
+	mu      sync.Mutex
+	dialogs map[int64]*domain.Dialog
+	nextID  int64
+}
+
+func newMemoryDialogRepo() *memoryDialogRepo {
+	return &memoryDialogRepo{dialogs: make(map[int64]*domain.Dialog)}
+}
+
+func (r *memoryDialogRepo) Save(ctx context.Context, dialog *domain.Dialog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if dialog.ID == 0 {
+		r.nextID++
+		dialog.ID = r.nextID
+	}
+	stored := *dialog
+	stored.Messages = append([]domain.Message(nil), dialog.Messages...)
+	r.dialogs[dialog.ID] = &stored
+	return nil
+}
+
+func (r *memoryDialogRepo) FindByID(ctx context.Context, id int64) (*domain.Dialog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.dialogs[id]
+	if !ok {
+		return nil, nil
+	}
+	dialog := *stored
+	dialog.Messages = append([]domain.Message(nil), stored.Messages...)
+	return &dialog, nil
+}
+
+func (r *memoryDialogRepo) FindAllByUserID(ctx context.Context, userID int64) ([]*domain.Dialog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var dialogs []*domain.Dialog
+	for _, dialog := range r.dialogs {
+		if dialog.UserID == userID {
+			dialogs = append(dialogs, dialog)
+		}
+	}
+	return dialogs, nil
+}
+
+func (r *memoryDialogRepo) GetAll(ctx context.Context) ([]*domain.Dialog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var dialogs []*domain.Dialog
+	for _, dialog := range r.dialogs {
+		dialogs = append(dialogs, dialog)
+	}
+	return dialogs, nil
+}
+
+func (r *memoryDialogRepo) AddMessage(ctx context.Context, message *domain.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dialog := r.dialogs[message.DialogID]
+	r.nextID++
+	message.ID = r.nextID
+	message.Seq = int64(len(dialog.Messages) + 1)
+	dialog.Messages = append(dialog.Messages, *message)
+	return nil
+}
+
+// newTestPersonas writes a single persona with its prompt to a temporary directory and loads it.
+func newTestPersonas(t *testing.T) *services.PersonaRegistry {
+	t.Helper()
+	dir := t.TempDir()
+	prompt := filepath.Join(dir, "prompt.txt")
+	if err := os.WriteFile(prompt, []byte("You are a calm listener."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"personas": [{"id": "listener", "name": "Oilan", "prompt_file": "` + filepath.ToSlash(prompt) + `"}]}`
+	path := filepath.Join(dir, "personas.json")
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	personas, err := services.LoadPersonas(path)
+	if err != nil {
+		t.Fatalf("LoadPersonas: %v", err)
+	}
+	return personas
+}
+
+// asUser serves a handler as if the auth middleware had signed in the user.
+func asUser(userID int64, handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), middleware.UserIDContextKey, userID)
+		handler(w, r.WithContext(ctx))
+	})
+}
+
+func dialWs(t *testing.T, server *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func readFrame(t *testing.T, conn *websocket.Conn) Frame {
+	t.Helper()
+	var frame Frame
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	return frame
+}
+
+func TestServeWsGreeting(t *testing.T) {
+	const userID = 7
+	repo := newMemoryDialogRepo()
+	chatService, err := services.NewChatService(repo, nil, nil, services.DefaultContextBudget(), nil, newTestPersonas(t), nil, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewAPIHandlers(chatService, nil, repo, nil)
+	server := httptest.NewServer(asUser(userID, h.ServeWs))
+	defer server.Close()
+
+	t.Run("new dialog is greeted", func(t *testing.T) {
+		conn := dialWs(t, server, "")
+		bound := readFrame(t, conn)
+		if bound.Type != FrameDialogBound || bound.DialogID == 0 {
+			t.Fatalf("first frame = %+v, want dialog_bound with a dialog", bound)
+		}
+
+		greeting := readFrame(t, conn)
+		want := Frame{V: ProtocolVersion, Type: FrameGreeting, DialogID: bound.DialogID, Content: i18n.T(i18n.Default, "chat.greeting")}
+		if greeting != want {
+			t.Errorf("greeting = %+v, want %+v", greeting, want)
+		}
+	})
+
+	t.Run("resumed dialog is not greeted", func(t *testing.T) {
+		dialog := &domain.Dialog{UserID: userID, PersonaID: "listener"}
+		repo.Save(context.Background(), dialog)
+		repo.AddMessage(context.Background(), &domain.Message{DialogID: dialog.ID, Role: domain.RoleUser, Content: "Hello"})
+
+		conn := dialWs(t, server, "?dialogID="+strconv.FormatInt(dialog.ID, 10))
+		if bound := readFrame(t, conn); bound.Type != FrameDialogBound || bound.DialogID != dialog.ID {
+			t.Fatalf("first frame = %+v, want dialog_bound to dialog %d", bound, dialog.ID)
+		}
+
+		// The next frame must answer this one; a greeting would come first.
+		conn.WriteJSON(Frame{V: ProtocolVersion + 1, Type: FrameUserMessage, ClientID: "c1", Content: "Hi"})
+		if frame := readFrame(t, conn); frame.Type != FrameError || frame.Code != ErrCodeUnsupportedVersion {
+			t.Errorf("frame = %+v, want the unsupported_version error", frame)
+		}
+	})
+}
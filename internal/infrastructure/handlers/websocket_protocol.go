@@ -0,0 +1,64 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/handlers/websocket_protocol.go
+package handlers
+
+// ProtocolVersion is the version of the chat WebSocket protocol spoken by this server.
+// web/static/js/main.js must send the same value in the "v" field of every frame.
+const ProtocolVersion = 1
+
+// FrameType identifies the kind of a WebSocket frame.
+type FrameType string
+
+const (
+	// Client -> server
+	FrameUserMessage FrameType = "user_message" // A message typed by the user
+
+	// Server -> client
+	FrameDialogBound FrameType = "dialog_bound" // The connection is attached to DialogID
+	FrameGreeting    FrameType = "greeting"     // A welcome shown in an empty dialog; it is not persisted and has no MessageID
+	FrameAck         FrameType = "ack"          // The user message was persisted as MessageID
+	FrameTyping      FrameType = "typing"       // The AI has started working on a reply
+	FrameAIChunk     FrameType = "ai_chunk"     // A partial piece of the AI reply
+	FrameAIDone      FrameType = "ai_done"      // The AI reply is complete and persisted as MessageID
+	FramePhase       FrameType = "phase"        // The dialog moved on to Phase
+	FrameError       FrameType = "error"        // A system notice, never model output
+)
+
+// ErrorCode tells the client why an error frame was sent.
+type ErrorCode string
+
+const (
+	ErrCodeBadFrame           ErrorCode = "bad_frame"
+	ErrCodeUnsupportedVersion ErrorCode = "unsupported_version"
+	ErrCodeEmptyMessage       ErrorCode = "empty_message"
+	ErrCodeGenerationFailed   ErrorCode = "generation_failed"
+	ErrCodeLLMUnavailable     ErrorCode = "llm_unavailable"
+	ErrCodeResponseBlocked    ErrorCode = "response_blocked"
+	ErrCodeQuotaExceeded      ErrorCode = "quota_exceeded"
+	ErrCodeRateLimited        ErrorCode = "rate_limited"
+	ErrCodeDialogBusy         ErrorCode = "dialog_busy"
+)
+
+// Frame is the envelope for every message exchanged over the chat WebSocket.
+type Frame struct {
+	V    int       `json:"v"`
+	Type FrameType `json:"type"`
+
+	// ClientID is chosen by the client for a user_message and echoed back in every
+	// frame that belongs to it, so replies can be correlated with what was sent.
+	ClientID string `json:"client_id,omitempty"`
+
+	DialogID  int64     `json:"dialog_id,omitempty"`
+	MessageID int64     `json:"message_id,omitempty"` // ID of the persisted message (ack, ai_done)
+	ReplyTo   int64     `json:"reply_to,omitempty"`   // ID of the user message an AI frame answers
+	Content   string    `json:"content,omitempty"`
+	Phase     string    `json:"phase,omitempty"` // ID of the phase the dialog is now in (phase)
+	Code      ErrorCode `json:"code,omitempty"`
+
+	// RetryAfter is how many seconds the client has to wait before sending again (rate_limited).
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+// newFrame creates a frame of the given type stamped with the current protocol version.
+func newFrame(t FrameType) Frame {
+	return Frame{V: ProtocolVersion, Type: t}
+}
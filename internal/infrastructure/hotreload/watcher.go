@@ -0,0 +1,106 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/hotreload/watcher.go
+package hotreload
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"maps"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Watcher polls directories for changed files and calls a reload function when something changed.
+// Polling needs no platform support and works the same on bind mounts and network file systems,
+// where change notifications are often missing.
+type Watcher struct {
+	interval time.Duration
+	watches  []*watch
+}
+
+type watch struct {
+	dir      string
+	onChange func()
+	files    map[string]fileState
+}
+
+// fileState is what tells us a file has changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher creates a watcher that checks all watched directories once per interval.
+func NewWatcher(interval time.Duration) *Watcher {
+	return &Watcher{interval: interval}
+}
+
+// Watch calls onChange whenever a file below dir is created, changed or removed. Several changes
+// within one interval, like an editor saving a whole directory, result in a single call.
+func (w *Watcher) Watch(dir string, onChange func()) error {
+	files, err := scan(dir)
+	if err != nil {
+		return err
+	}
+	w.watches = append(w.watches, &watch{dir: dir, onChange: onChange, files: files})
+	return nil
+}
+
+// Run polls until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, wt := range w.watches {
+				w.check(wt)
+			}
+		}
+	}
+}
+
+func (w *Watcher) check(wt *watch) {
+	files, err := scan(wt.dir)
+	if err != nil {
+		log.Printf("Hot reload: could not scan %s: %v", wt.dir, err)
+		return
+	}
+	if maps.Equal(files, wt.files) {
+		return
+	}
+	wt.files = files
+	log.Printf("Hot reload: %s changed, reloading", wt.dir)
+	wt.onChange()
+}
+
+// scan records the state of every file below dir. Hidden files and editor backups are skipped,
+// so that swap files written while a file is open do not trigger reloads.
+func scan(dir string) (map[string]fileState, error) {
+	files := make(map[string]fileState)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if path != dir && (strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~")) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,66 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/breaker.go
+package llm
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitBreaker stops sending requests to a provider after repeated failures.
+// After the cooldown a single trial request is let through: if it succeeds the
+// breaker closes again, otherwise it stays open for another cooldown.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	failures int
+	openedAt time.Time // Zero while the breaker is closed
+	trial    bool      // A trial request is in flight
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow reports whether a request may be sent to the provider.
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return true
+	}
+	if b.trial || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// Success closes the breaker.
+func (b *circuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openedAt = time.Time{}
+	b.trial = false
+}
+
+// Failure records a failed request and opens the breaker once the threshold is reached.
+func (b *circuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.trial || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+	b.trial = false
+}
+
+// Abandon releases a trial request whose outcome is unknown, e.g. because the caller went away.
+func (b *circuitBreaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
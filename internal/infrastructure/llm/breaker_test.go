@@ -0,0 +1,50 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/breaker_test.go
+package llm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+
+	// Each step is "allow" or "deny" (the expected result of Allow), "fail", "succeed",
+	// "abandon" or "wait" (for the cooldown to pass).
+	tests := []struct {
+		name  string
+		steps []string
+	}{
+		{name: "closed below the threshold", steps: []string{"allow", "fail", "allow", "fail", "allow"}},
+		{name: "opens at the threshold", steps: []string{"fail", "fail", "fail", "deny"}},
+		{name: "success resets the failures", steps: []string{"fail", "fail", "succeed", "fail", "fail", "allow"}},
+		{name: "one trial after the cooldown", steps: []string{"fail", "fail", "fail", "deny", "wait", "allow", "deny"}},
+		{name: "successful trial closes", steps: []string{"fail", "fail", "fail", "wait", "allow", "succeed", "allow", "allow"}},
+		{name: "failed trial reopens", steps: []string{"fail", "fail", "fail", "wait", "allow", "fail", "deny", "wait", "allow"}},
+		{name: "abandoned trial lets the next one through", steps: []string{"fail", "fail", "fail", "wait", "allow", "abandon", "allow", "deny"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := newCircuitBreaker(3, cooldown)
+			for i, step := range tt.steps {
+				switch step {
+				case "allow", "deny":
+					if got := breaker.Allow(); got != (step == "allow") {
+						t.Fatalf("step %d: Allow() = %v, want %v", i, got, step == "allow")
+					}
+				case "fail":
+					breaker.Failure()
+				case "succeed":
+					breaker.Success()
+				case "abandon":
+					breaker.Abandon()
+				case "wait":
+					time.Sleep(cooldown + 5*time.Millisecond)
+				default:
+					t.Fatalf("unknown step %q", step)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/cassette.go
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cassetteClock is the time prompts are rendered for while a cassette is recorded or replayed, see
+// PromptClockFromEnv. With the real clock, the date and time in a system prompt would make a
+// cassette recorded on one day miss on the next.
+var cassetteClock = time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// ErrUnexpectedRequest is returned in replay mode when a request is not on the cassette.
+var ErrUnexpectedRequest = errors.New("request not found on cassette")
+
+// CassetteMode tells a cassette client whether to record real exchanges or play them back.
+type CassetteMode string
+
+const (
+	CassetteRecord CassetteMode = "record"
+	CassetteReplay CassetteMode = "replay"
+)
+
+// Cassette is the file format of recorded LLM exchanges. It is written as indented JSON with the
+// system prompt split into lines, so that a prompt change shows up as a readable diff.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request together with its outcome.
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest is everything an LLMClient receives.
+type CassetteRequest struct {
+	SystemPrompt []string                   `json:"system_prompt"`
+	History      []CassetteMessage          `json:"history"`
+	Params       *services.GenerationParams `json:"params,omitempty"`
+}
+
+// CassetteMessage is the part of a domain.Message that is sent to the model.
+type CassetteMessage struct {
+	Role    domain.Role `json:"role"`
+	Content string      `json:"content"`
+}
+
+// CassetteResponse is a recorded response, or a recorded error when Error is set.
+type CassetteResponse struct {
+	Content          string   `json:"content,omitempty"`
+	Chunks           []string `json:"chunks,omitempty"` // Only for streamed responses, also before an error
+	Provider         string   `json:"provider,omitempty"`
+	Model            string   `json:"model,omitempty"`
+	PromptTokens     int      `json:"prompt_tokens,omitempty"`
+	CompletionTokens int      `json:"completion_tokens,omitempty"`
+	FinishReason     string   `json:"finish_reason,omitempty"`
+	Error            string   `json:"error,omitempty"`
+	ErrorClass       string   `json:"error_class,omitempty"`
+}
+
+func newCassetteRequest(ctx context.Context, history []domain.Message, systemPrompt string) CassetteRequest {
+	req := CassetteRequest{
+		SystemPrompt: strings.Split(systemPrompt, "\n"),
+		History:      make([]CassetteMessage, 0, len(history)),
+	}
+	if params := services.GenerationParamsFromContext(ctx); !params.IsZero() {
+		req.Params = &params
+	}
+	for _, msg := range history {
+		req.History = append(req.History, CassetteMessage{Role: msg.Role, Content: msg.Content})
+	}
+	return req
+}
+
+// key returns a canonical representation of the request used for matching.
+func (r CassetteRequest) key() string {
+	data, _ := json.Marshal(r)
+	return string(data)
+}
+
+// LoadCassette reads a cassette file. A missing file is an empty cassette.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Cassette{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette: %w", err)
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette atomically, so an interrupted run never leaves a truncated file.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cassette-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// NewCassetteClient wraps an LLM client according to the mode: in record mode every exchange with
+// next is appended to the cassette at path; in replay mode next is not used at all and answers are
+// served from the cassette.
+func NewCassetteClient(next services.LLMClient, path string, mode CassetteMode) (services.LLMClient, error) {
+	switch mode {
+	case CassetteRecord:
+		if next == nil {
+			return nil, errors.New("record mode needs a real llm client")
+		}
+		// Every recording session starts with a fresh cassette.
+		return &cassetteRecorder{next: next, path: path, cassette: &Cassette{}}, nil
+	case CassetteReplay:
+		cassette, err := LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		return &cassetteReplayer{cassette: cassette, used: make([]bool, len(cassette.Interactions))}, nil
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q", mode)
+	}
+}
+
+// cassetteRecorder records every exchange with the wrapped client.
+type cassetteRecorder struct {
+	next     services.LLMClient
+	path     string
+	mu       sync.Mutex
+	cassette *Cassette
+}
+
+func (r *cassetteRecorder) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*services.LLMResponse, error) {
+	resp, err := r.next.GenerateResponse(ctx, history, systemPrompt)
+	r.record(newCassetteRequest(ctx, history, systemPrompt), resp, nil, err)
+	return resp, err
+}
+
+func (r *cassetteRecorder) StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk services.StreamHandler) (*services.LLMResponse, error) {
+	var chunks []string
+	resp, err := r.next.StreamResponse(ctx, history, systemPrompt, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return onChunk(chunk)
+	})
+	r.record(newCassetteRequest(ctx, history, systemPrompt), resp, chunks, err)
+	return resp, err
+}
+
+func (r *cassetteRecorder) record(req CassetteRequest, resp *services.LLMResponse, chunks []string, err error) {
+	// A request the caller gave up on says nothing about the provider.
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	var recorded CassetteResponse
+	if err != nil {
+		// A stream that broke off keeps the chunks sent before the error, so it replays alike.
+		recorded.Chunks = chunks
+		recorded.Error = err.Error()
+		recorded.ErrorClass = ClassifyError(err).String()
+	} else {
+		recorded = CassetteResponse{
+			Content:          resp.Content,
+			Chunks:           chunks,
+			Provider:         resp.Provider,
+			Model:            resp.Model,
+			PromptTokens:     resp.PromptTokens,
+			CompletionTokens: resp.CompletionTokens,
+			FinishReason:     resp.FinishReason,
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{Request: req, Response: recorded})
+	if saveErr := r.cassette.Save(r.path); saveErr != nil {
+		// Recording must never break the conversation itself.
+		log.Printf("Could not save cassette %s: %v", r.path, saveErr)
+	}
+}
+
+// cassetteReplayer serves recorded responses. Each interaction is used once, in recording order
+// among identical requests, so a conversation that repeats itself replays faithfully.
+type cassetteReplayer struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+func (r *cassetteReplayer) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*services.LLMResponse, error) {
+	recorded, err := r.take(newCassetteRequest(ctx, history, systemPrompt))
+	if err != nil {
+		return nil, err
+	}
+	return recorded.result()
+}
+
+func (r *cassetteReplayer) StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk services.StreamHandler) (*services.LLMResponse, error) {
+	recorded, err := r.take(newCassetteRequest(ctx, history, systemPrompt))
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := recorded.Chunks
+	if len(chunks) == 0 && recorded.Content != "" {
+		chunks = []string{recorded.Content}
+	}
+	for _, chunk := range chunks {
+		if err := onChunk(chunk); err != nil {
+			return nil, err
+		}
+	}
+	return recorded.result()
+}
+
+func (r *cassetteReplayer) take(req CassetteRequest) (*CassetteResponse, error) {
+	key := req.key()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if !r.used[i] && interaction.Request.key() == key {
+			r.used[i] = true
+			return &interaction.Response, nil
+		}
+	}
+
+	lastUserMessage := ""
+	for _, msg := range req.History {
+		if msg.Role == domain.RoleUser {
+			lastUserMessage = msg.Content
+		}
+	}
+	return nil, &ProviderError{
+		Class: ErrorFatal,
+		Err:   fmt.Errorf("%w: %d messages, last user message %q", ErrUnexpectedRequest, len(req.History), lastUserMessage),
+	}
+}
+
+// result turns a recorded response back into what the real client returned.
+func (c *CassetteResponse) result() (*services.LLMResponse, error) {
+	if c.Error != "" {
+		class, err := ParseErrorClass(c.ErrorClass)
+		if err != nil {
+			class = ErrorFatal
+		}
+		// Cassettes record what ChatService saw, so replayed errors unwrap to the same sentinels.
+		sentinel := services.ErrLLMUnavailable
+		if class == ErrorSafetyBlocked {
+			sentinel = services.ErrResponseBlocked
+		}
+		return nil, &ProviderError{Class: class, Err: &replayedError{message: c.Error, sentinel: sentinel}}
+	}
+	return &services.LLMResponse{
+		Content:          c.Content,
+		Provider:         c.Provider,
+		Model:            c.Model,
+		PromptTokens:     c.PromptTokens,
+		CompletionTokens: c.CompletionTokens,
+		FinishReason:     c.FinishReason,
+	}, nil
+}
+
+// replayedError carries the recorded error message and unwraps to the matching services error.
+type replayedError struct {
+	message  string
+	sentinel error
+}
+
+func (e *replayedError) Error() string {
+	return e.message
+}
+
+func (e *replayedError) Unwrap() error {
+	return e.sentinel
+}
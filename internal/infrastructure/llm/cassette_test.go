@@ -0,0 +1,181 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/cassette_test.go
+package llm
+
+import (
+	"context"
+	"errors"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cassetteStep is one request sent through a cassette client.
+type cassetteStep struct {
+	name         string
+	stream       bool
+	history      []domain.Message
+	systemPrompt string
+	temperature  float64 // Sent as a generation parameter when set
+}
+
+// cassetteResult is everything a caller can observe about a response.
+type cassetteResult struct {
+	content string
+	chunks  string
+	err     error
+}
+
+func (s cassetteStep) run(t *testing.T, client services.LLMClient) cassetteResult {
+	t.Helper()
+	ctx := context.Background()
+	if s.temperature != 0 {
+		ctx = services.WithGenerationParams(ctx, services.GenerationParams{Temperature: &s.temperature})
+	}
+
+	var resp *services.LLMResponse
+	var chunks []string
+	var err error
+	if s.stream {
+		resp, err = client.StreamResponse(ctx, s.history, s.systemPrompt, func(chunk string) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+	} else {
+		resp, err = client.GenerateResponse(ctx, s.history, s.systemPrompt)
+	}
+
+	result := cassetteResult{chunks: strings.Join(chunks, "|"), err: err}
+	if resp != nil {
+		result.content = resp.Content
+	}
+	return result
+}
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	steps := []cassetteStep{
+		{name: "generate", history: userTurns("hi")},
+		{name: "stream", stream: true, history: userTurns("hi", "go on"), systemPrompt: "You are a coach.\nBe brief."},
+		{name: "same request again", stream: true, history: userTurns("hi", "go on"), systemPrompt: "You are a coach.\nBe brief."},
+		{name: "with parameters", history: userTurns("hi"), temperature: 0.3},
+		{name: "rate limited", history: userTurns("test rate limit")},
+		{name: "safety blocked", stream: true, history: userTurns("test safety")},
+		{name: "broken stream", stream: true, history: userTurns("test broken stream")},
+	}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder, err := NewCassetteClient(newTestScriptedMock(t, testScript), path, CassetteRecord)
+	if err != nil {
+		t.Fatalf("NewCassetteClient(record): %v", err)
+	}
+	recorded := make([]cassetteResult, len(steps))
+	for i, step := range steps {
+		recorded[i] = step.run(t, recorder)
+	}
+
+	replayer, err := NewCassetteClient(nil, path, CassetteReplay)
+	if err != nil {
+		t.Fatalf("NewCassetteClient(replay): %v", err)
+	}
+	for i, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			want, got := recorded[i], step.run(t, replayer)
+			if got.content != want.content || got.chunks != want.chunks {
+				t.Errorf("replayed %q in chunks %q, recorded %q in chunks %q", got.content, got.chunks, want.content, want.chunks)
+			}
+			if (got.err == nil) != (want.err == nil) {
+				t.Fatalf("replayed error %v, recorded %v", got.err, want.err)
+			}
+			if want.err == nil {
+				return
+			}
+			if got.err.Error() != want.err.Error() {
+				t.Errorf("replayed error %q, recorded %q", got.err, want.err)
+			}
+			if ClassifyError(got.err) != ClassifyError(want.err) {
+				t.Errorf("replayed error class %v, recorded %v", ClassifyError(got.err), ClassifyError(want.err))
+			}
+			if ClassifyError(got.err) == ErrorSafetyBlocked && !errors.Is(got.err, services.ErrResponseBlocked) {
+				t.Errorf("replayed safety block does not unwrap to ErrResponseBlocked: %v", got.err)
+			}
+		})
+	}
+}
+
+func TestCassetteReplayMisses(t *testing.T) {
+	recordedStep := cassetteStep{history: userTurns("hi"), systemPrompt: "You are a guide."}
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder, err := NewCassetteClient(newTestScriptedMock(t, testScript), path, CassetteRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordedStep.run(t, recorder)
+
+	tests := []struct {
+		name string
+		step cassetteStep
+	}{
+		{name: "other user message", step: cassetteStep{history: userTurns("hello"), systemPrompt: "You are a guide."}},
+		{name: "other system prompt", step: cassetteStep{history: userTurns("hi"), systemPrompt: "You are a coach."}},
+		{name: "other parameters", step: cassetteStep{history: userTurns("hi"), systemPrompt: "You are a guide.", temperature: 0.9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replayer, err := NewCassetteClient(nil, path, CassetteReplay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := tt.step.run(t, replayer)
+			if !errors.Is(got.err, ErrUnexpectedRequest) {
+				t.Errorf("error = %v, want ErrUnexpectedRequest", got.err)
+			}
+		})
+	}
+
+	t.Run("used up", func(t *testing.T) {
+		replayer, err := NewCassetteClient(nil, path, CassetteReplay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := recordedStep.run(t, replayer); got.err != nil {
+			t.Fatalf("first replay: %v", got.err)
+		}
+		if got := recordedStep.run(t, replayer); !errors.Is(got.err, ErrUnexpectedRequest) {
+			t.Errorf("second replay error = %v, want ErrUnexpectedRequest", got.err)
+		}
+	})
+}
+
+func TestPromptClockFromEnv(t *testing.T) {
+	t.Run("cassette", func(t *testing.T) {
+		t.Setenv("LLM_CASSETTE", filepath.Join(t.TempDir(), "cassette.json"))
+		now := PromptClockFromEnv()
+		if got := now(); !got.Equal(cassetteClock) {
+			t.Fatalf("clock = %v, want the cassette clock %v", got, cassetteClock)
+		}
+
+		// Dialogs started on different days render the same prompt.
+		template := "Today is {{.Date}}, {{.Time}}; day {{.DialogAgeDays}} of the dialog."
+		today, err := services.RenderPrompt(template, services.NewPromptVars("en", "Asia/Almaty", now(), time.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		later, err := services.RenderPrompt(template, services.NewPromptVars("en", "Asia/Almaty", now(), time.Now().AddDate(0, 0, 3)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if today != later {
+			t.Errorf("prompt changed between days: %q and %q", today, later)
+		}
+	})
+
+	t.Run("no cassette", func(t *testing.T) {
+		t.Setenv("LLM_CASSETTE", "")
+		before := time.Now()
+		if got := PromptClockFromEnv()(); got.Before(before) {
+			t.Errorf("clock = %v, want the current time", got)
+		}
+	})
+}
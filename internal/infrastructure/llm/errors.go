@@ -0,0 +1,196 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/errors.go
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/sashabaranov/go-openai"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorClass describes how a provider error should be handled.
+type ErrorClass int
+
+const (
+	// ErrorFatal means retrying the same provider is pointless (bad request, invalid key, ...).
+	ErrorFatal ErrorClass = iota
+	// ErrorRetryable means the request may succeed if it is sent again (timeouts, 5xx, network errors).
+	ErrorRetryable
+	// ErrorRateLimited means the provider asked us to slow down.
+	ErrorRateLimited
+	// ErrorSafetyBlocked means the provider refused to answer; other providers must not be tried.
+	ErrorSafetyBlocked
+)
+
+// String returns a human-readable name of the class for logs.
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorRetryable:
+		return "retryable"
+	case ErrorRateLimited:
+		return "rate-limited"
+	case ErrorSafetyBlocked:
+		return "safety-blocked"
+	default:
+		return "fatal"
+	}
+}
+
+// ProviderError is an error that already knows its class, e.g. one returned through classify or
+// one injected by the scripted mock.
+type ProviderError struct {
+	Class ErrorClass
+	Err   error
+}
+
+func (e *ProviderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Failures the clients find in an answer themselves, so that each gets the same class whichever
+// provider it came from.
+var (
+	// errEmptyResponse means the provider finished without any text; asking again usually helps.
+	errEmptyResponse = errors.New("empty response")
+	// errMalformedResponse means the answer could not be decoded or its stream ended early.
+	errMalformedResponse = errors.New("malformed response")
+	// errOutputLimit means the output token limit was hit before any text; the same request hits it again.
+	errOutputLimit = errors.New("output token limit reached")
+)
+
+// emptyResponseError explains why a provider finished without any text, given the normalized finish reason.
+func emptyResponseError(finishReason string) error {
+	switch finishReason {
+	case services.FinishReasonMaxTokens:
+		return fmt.Errorf("%w: no text", errOutputLimit)
+	case services.FinishReasonSafety:
+		return fmt.Errorf("%w: filtered before any text", services.ErrResponseBlocked)
+	}
+	return fmt.Errorf("%w: finish reason %s", errEmptyResponse, finishReason)
+}
+
+// httpStatusError is a failed call to a provider that speaks a plain HTTP protocol.
+type httpStatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *httpStatusError) Error() string {
+	return e.Message
+}
+
+// classify wraps an error of a provider in a ProviderError with the class ClassifyError gives it
+// and the provider's name in front of the message. Every client returns its errors through classify,
+// so that the fallback chain handles the same failure alike for all providers. Errors that already
+// carry a class keep it.
+func classify(provider string, err error) error {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return err
+	}
+	return &ProviderError{Class: ClassifyError(err), Err: fmt.Errorf("%s: %w", provider, err)}
+}
+
+// ParseErrorClass converts the name returned by ErrorClass.String back into a class.
+func ParseErrorClass(name string) (ErrorClass, error) {
+	for _, class := range []ErrorClass{ErrorFatal, ErrorRetryable, ErrorRateLimited, ErrorSafetyBlocked} {
+		if class.String() == name {
+			return class, nil
+		}
+	}
+	return ErrorFatal, fmt.Errorf("unknown error class %q", name)
+}
+
+// ClassifyError inspects an error returned by any of our LLM clients.
+func ClassifyError(err error) ErrorClass {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.Class
+	}
+	if errors.Is(err, services.ErrResponseBlocked) {
+		return ErrorSafetyBlocked
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorFatal
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorRetryable
+	}
+	if errors.Is(err, errOutputLimit) {
+		return ErrorFatal
+	}
+	if errors.Is(err, errEmptyResponse) || errors.Is(err, errMalformedResponse) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorRetryable
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return classifyHTTPStatus(statusErr.StatusCode)
+	}
+
+	// Gemini
+	var blockedErr *genai.BlockedError
+	if errors.As(err, &blockedErr) {
+		return ErrorSafetyBlocked
+	}
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) {
+		return classifyHTTPStatus(googleErr.Code)
+	}
+	if st, ok := status.FromError(err); ok && st.Code() != codes.OK {
+		return classifyGRPCCode(st.Code())
+	}
+
+	// OpenAI and compatible servers
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		if code, ok := apiErr.Code.(string); ok && code == "content_filter" {
+			return ErrorSafetyBlocked
+		}
+		return classifyHTTPStatus(apiErr.HTTPStatusCode)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return classifyHTTPStatus(reqErr.HTTPStatusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorRetryable
+	}
+	return ErrorFatal
+}
+
+func classifyHTTPStatus(code int) ErrorClass {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return ErrorRateLimited
+	case code == http.StatusRequestTimeout, code >= 500:
+		return ErrorRetryable
+	default:
+		return ErrorFatal
+	}
+}
+
+func classifyGRPCCode(code codes.Code) ErrorClass {
+	switch code {
+	case codes.ResourceExhausted:
+		return ErrorRateLimited
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Aborted:
+		return ErrorRetryable
+	default:
+		return ErrorFatal
+	}
+}
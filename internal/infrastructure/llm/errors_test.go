@@ -0,0 +1,140 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/errors_test.go
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/sashabaranov/go-openai"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{name: "class already known", err: fmt.Errorf("wrapped: %w", &ProviderError{Class: ErrorRateLimited, Err: errors.New("slow down")}), want: ErrorRateLimited},
+		{name: "blocked response", err: fmt.Errorf("%w: safety", services.ErrResponseBlocked), want: ErrorSafetyBlocked},
+		{name: "canceled", err: fmt.Errorf("call: %w", context.Canceled), want: ErrorFatal},
+		{name: "deadline", err: fmt.Errorf("call: %w", context.DeadlineExceeded), want: ErrorRetryable},
+		{name: "empty response", err: errEmptyResponse, want: ErrorRetryable},
+		{name: "malformed response", err: fmt.Errorf("%w: bad json", errMalformedResponse), want: ErrorRetryable},
+		{name: "stream cut off", err: io.ErrUnexpectedEOF, want: ErrorRetryable},
+		{name: "output limit", err: errOutputLimit, want: ErrorFatal},
+		{name: "http 429", err: &httpStatusError{StatusCode: http.StatusTooManyRequests}, want: ErrorRateLimited},
+		{name: "http 503", err: &httpStatusError{StatusCode: http.StatusServiceUnavailable}, want: ErrorRetryable},
+		{name: "http 408", err: &httpStatusError{StatusCode: http.StatusRequestTimeout}, want: ErrorRetryable},
+		{name: "http 400", err: &httpStatusError{StatusCode: http.StatusBadRequest}, want: ErrorFatal},
+		{name: "http 401", err: &httpStatusError{StatusCode: http.StatusUnauthorized}, want: ErrorFatal},
+		{name: "gemini blocked", err: &genai.BlockedError{}, want: ErrorSafetyBlocked},
+		{name: "google api 429", err: &googleapi.Error{Code: http.StatusTooManyRequests}, want: ErrorRateLimited},
+		{name: "google api 500", err: &googleapi.Error{Code: http.StatusInternalServerError}, want: ErrorRetryable},
+		{name: "google api 403", err: &googleapi.Error{Code: http.StatusForbidden}, want: ErrorFatal},
+		{name: "grpc exhausted", err: status.Error(codes.ResourceExhausted, "quota"), want: ErrorRateLimited},
+		{name: "grpc unavailable", err: status.Error(codes.Unavailable, "down"), want: ErrorRetryable},
+		{name: "grpc invalid argument", err: status.Error(codes.InvalidArgument, "bad"), want: ErrorFatal},
+		{name: "openai content filter", err: &openai.APIError{Code: "content_filter", HTTPStatusCode: http.StatusBadRequest}, want: ErrorSafetyBlocked},
+		{name: "openai 429", err: &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, want: ErrorRateLimited},
+		{name: "openai 502", err: &openai.RequestError{HTTPStatusCode: http.StatusBadGateway, Err: errors.New("bad gateway")}, want: ErrorRetryable},
+		{name: "openai 401", err: &openai.APIError{HTTPStatusCode: http.StatusUnauthorized}, want: ErrorFatal},
+		{name: "network error", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: ErrorRetryable},
+		{name: "unknown error", err: errors.New("something else"), want: ErrorFatal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	err := classify("ollama", &httpStatusError{StatusCode: http.StatusServiceUnavailable, Message: "model is loading"})
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Class != ErrorRetryable {
+		t.Fatalf("classify() = %#v, want a retryable ProviderError", err)
+	}
+	if err.Error() != "ollama: model is loading" {
+		t.Errorf("message = %q, want the provider name in front", err.Error())
+	}
+
+	known := &ProviderError{Class: ErrorRateLimited, Err: errors.New("mock: slow down")}
+	if got := classify("mock", known); got != known {
+		t.Errorf("classify() = %v, want the ProviderError unchanged", got)
+	}
+}
+
+// TestClientsClassifyAlike sends the same failures to the HTTP based clients and checks that each
+// gets the same class from both of them.
+func TestClientsClassifyAlike(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		delay  time.Duration // Longer than the request timeout when set
+		want   ErrorClass
+	}{
+		{name: "overloaded", status: http.StatusServiceUnavailable, want: ErrorRetryable},
+		{name: "server error", status: http.StatusInternalServerError, want: ErrorRetryable},
+		{name: "rate limited", status: http.StatusTooManyRequests, want: ErrorRateLimited},
+		{name: "bad request", status: http.StatusBadRequest, want: ErrorFatal},
+		{name: "invalid key", status: http.StatusUnauthorized, want: ErrorFatal},
+		{name: "timeout", status: http.StatusOK, delay: 200 * time.Millisecond, want: ErrorRetryable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.delay > 0 {
+					select {
+					case <-time.After(tt.delay):
+					case <-r.Context().Done():
+					}
+					return
+				}
+				http.Error(w, "failure", tt.status)
+			}))
+			defer server.Close()
+
+			ollama, err := NewOllamaClient(Config{BaseURL: server.URL, Model: "llama3.1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			openAI, err := NewOpenAIClient(Config{APIKey: "test", BaseURL: server.URL + "/v1", Model: "gpt-4o-mini"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for name, client := range map[string]services.LLMClient{"ollama": ollama, "openai": openAI} {
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				_, err := client.GenerateResponse(ctx, []domain.Message{{Role: domain.RoleUser, Content: "hi"}}, "")
+				cancel()
+				if err == nil {
+					t.Fatalf("%s: GenerateResponse() succeeded, want an error", name)
+				}
+				if got := ClassifyError(err); got != tt.want {
+					t.Errorf("%s: class = %v, want %v (%v)", name, got, tt.want, err)
+				}
+				if !strings.HasPrefix(err.Error(), name+": ") {
+					t.Errorf("%s: error %q does not name the provider", name, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/factory.go
+package llm
+
+import (
+	"context"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config describes which LLM provider to use and how to reach it.
+type Config struct {
+	Provider string // Registered provider name, e.g. "gemini", "openai" or "mock"
+	Model    string // Model name; each provider has its own default
+	APIKey   string
+	BaseURL  string // Optional endpoint override, e.g. a local OpenAI-compatible server
+	Fixtures string // Script file played back by the mock provider
+
+	// Model parameters, currently used by the ollama provider. Zero values keep the server defaults.
+	Temperature *float64
+	NumCtx      int    // Context window in tokens
+	KeepAlive   string // How long the model stays loaded after a request, e.g. "10m" or "-1"
+}
+
+// HealthChecker is implemented by clients that can verify their backend before serving requests.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// Factory creates an LLM client from a configuration.
+type Factory func(cfg Config) (services.LLMClient, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		"gemini": NewGeminiClient,
+		"openai": NewOpenAIClient,
+		"ollama": func(cfg Config) (services.LLMClient, error) {
+			client, err := NewOllamaClient(cfg)
+			if err != nil {
+				return nil, err
+			}
+			// The server may still be starting next to us, so a failed check is only a warning.
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := client.(HealthChecker).HealthCheck(ctx); err != nil {
+				log.Printf("WARNING: %v", err)
+			}
+			return client, nil
+		},
+		"mock": func(cfg Config) (services.LLMClient, error) {
+			if cfg.Fixtures != "" {
+				return NewScriptedMockClient(cfg.Fixtures)
+			}
+			return NewMockLLMClient(), nil
+		},
+	}
+)
+
+// Register makes a provider available under the given name, replacing any existing one.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(name)] = factory
+}
+
+// Providers returns the names of all registered providers in alphabetical order.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewClient creates the LLM client for the configured provider.
+func NewClient(cfg Config) (services.LLMClient, error) {
+	registryMu.RLock()
+	factory, ok := registry[strings.ToLower(cfg.Provider)]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown llm provider %q (available: %s)", cfg.Provider, strings.Join(Providers(), ", "))
+	}
+	return factory(cfg)
+}
+
+// ConfigFromEnv reads the primary LLM configuration from the environment:
+//
+//	LLM_PROVIDER  provider name (default "gemini")
+//	LLM_MODEL     model name (default depends on the provider)
+//	LLM_API_KEY   API key; falls back to GEMINI_API_KEY or OPENAI_API_KEY
+//	LLM_BASE_URL  optional endpoint, e.g. http://localhost:11434/v1 for Ollama
+//	LLM_FIXTURES  script file for the mock provider, e.g. configs/mock_fixtures.example.json
+//	LLM_TEMPERATURE, LLM_NUM_CTX, LLM_KEEP_ALIVE  model parameters for the ollama provider
+func ConfigFromEnv() Config {
+	provider := strings.ToLower(os.Getenv("LLM_PROVIDER"))
+	if provider == "" {
+		provider = "gemini"
+	}
+	return providerConfigFromEnv(provider, "LLM_")
+}
+
+// ChainConfigFromEnv returns the primary configuration followed by the fallback providers listed,
+// in order, in LLM_FALLBACK (e.g. "openai,mock"). A fallback provider NAME is configured with
+// LLM_NAME_MODEL, LLM_NAME_API_KEY, LLM_NAME_BASE_URL, LLM_NAME_FIXTURES and so on.
+func ChainConfigFromEnv() []Config {
+	configs := []Config{ConfigFromEnv()}
+	for _, name := range strings.Split(os.Getenv("LLM_FALLBACK"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		configs = append(configs, NamedConfigFromEnv(name))
+	}
+	return configs
+}
+
+// NamedConfigFromEnv returns the configuration of provider NAME the way fallback providers are
+// configured: from LLM_NAME_MODEL, LLM_NAME_API_KEY, LLM_NAME_BASE_URL and so on.
+func NamedConfigFromEnv(name string) Config {
+	name = strings.ToLower(name)
+	return providerConfigFromEnv(name, "LLM_"+strings.ToUpper(name)+"_")
+}
+
+// NewClientChain creates a FallbackClient over all configured providers.
+func NewClientChain(configs []Config, policy FallbackPolicy) (services.LLMClient, error) {
+	clients := make([]NamedClient, 0, len(configs))
+	for _, cfg := range configs {
+		client, err := NewClient(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.Provider, err)
+		}
+		clients = append(clients, NamedClient{Name: cfg.Provider, Client: client})
+	}
+	return NewFallbackClient(policy, clients...), nil
+}
+
+func providerConfigFromEnv(provider, prefix string) Config {
+	cfg := Config{
+		Provider:  provider,
+		Model:     os.Getenv(prefix + "MODEL"),
+		APIKey:    os.Getenv(prefix + "API_KEY"),
+		BaseURL:   os.Getenv(prefix + "BASE_URL"),
+		Fixtures:  os.Getenv(prefix + "FIXTURES"),
+		NumCtx:    envInt(prefix + "NUM_CTX"),
+		KeepAlive: os.Getenv(prefix + "KEEP_ALIVE"),
+	}
+	if value := os.Getenv(prefix + "TEMPERATURE"); value != "" {
+		if temperature, err := strconv.ParseFloat(value, 64); err == nil {
+			cfg.Temperature = &temperature
+		} else {
+			log.Printf("WARNING: ignoring invalid %sTEMPERATURE %q", prefix, value)
+		}
+	}
+
+	// Keep the provider-specific variables working for existing deployments.
+	if cfg.APIKey == "" {
+		switch cfg.Provider {
+		case "gemini":
+			cfg.APIKey = os.Getenv("GEMINI_API_KEY")
+		case "openai":
+			cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+		}
+	}
+	return cfg
+}
+
+func envInt(key string) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// WrapWithCassetteFromEnv wraps the client for recording or replaying LLM exchanges when
+// LLM_CASSETTE (the cassette file) and LLM_CASSETTE_MODE ("record" or "replay") are set.
+// In replay mode next may be nil, since the real providers are never called.
+func WrapWithCassetteFromEnv(next services.LLMClient) (services.LLMClient, error) {
+	path := os.Getenv("LLM_CASSETTE")
+	if path == "" {
+		return next, nil
+	}
+	return NewCassetteClient(next, path, CassetteMode(strings.ToLower(os.Getenv("LLM_CASSETTE_MODE"))))
+}
+
+// PromptClockFromEnv returns the clock system prompts should be rendered with. While LLM_CASSETTE
+// is set it always returns the same time, so that the date, time and dialog age in a prompt do
+// not change the recorded requests between runs; otherwise it is time.Now.
+func PromptClockFromEnv() func() time.Time {
+	if os.Getenv("LLM_CASSETTE") == "" {
+		return time.Now
+	}
+	return func() time.Time { return cassetteClock }
+}
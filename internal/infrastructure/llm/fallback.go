@@ -0,0 +1,167 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/fallback.go
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"log"
+	"math/rand/v2"
+	"time"
+)
+
+// FallbackPolicy controls retries and circuit breaking in a FallbackClient.
+type FallbackPolicy struct {
+	MaxAttempts      int           // Attempts per provider, including the first one
+	BaseDelay        time.Duration // Backoff before the first retry; doubled on every further retry
+	MaxDelay         time.Duration // Upper bound for a single backoff
+	FailureThreshold int           // Failed requests in a row that open a provider's circuit breaker
+	Cooldown         time.Duration // How long an open circuit breaker rejects requests
+}
+
+// DefaultFallbackPolicy returns the policy used by the server.
+func DefaultFallbackPolicy() FallbackPolicy {
+	return FallbackPolicy{
+		MaxAttempts:      3,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         8 * time.Second,
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// NamedClient pairs an LLM client with the provider name it is reported under.
+type NamedClient struct {
+	Name   string
+	Client services.LLMClient
+}
+
+type fallbackProvider struct {
+	NamedClient
+	breaker *circuitBreaker
+}
+
+// FallbackClient is an LLMClient that tries several providers in order.
+// Each provider is retried with exponential backoff and protected by its own circuit breaker.
+type FallbackClient struct {
+	policy    FallbackPolicy
+	providers []*fallbackProvider
+}
+
+// NewFallbackClient creates a client that tries the given providers in order.
+func NewFallbackClient(policy FallbackPolicy, clients ...NamedClient) services.LLMClient {
+	providers := make([]*fallbackProvider, 0, len(clients))
+	for _, c := range clients {
+		providers = append(providers, &fallbackProvider{
+			NamedClient: c,
+			breaker:     newCircuitBreaker(policy.FailureThreshold, policy.Cooldown),
+		})
+	}
+	return &FallbackClient{policy: policy, providers: providers}
+}
+
+// GenerateResponse returns the response of the first provider that succeeds.
+func (c *FallbackClient) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*services.LLMResponse, error) {
+	return c.run(ctx, nil, func(client services.LLMClient) (*services.LLMResponse, error) {
+		return client.GenerateResponse(ctx, history, systemPrompt)
+	})
+}
+
+// StreamResponse streams the response of the first provider that succeeds.
+// Once a chunk has reached onChunk the request is no longer retried, since that would repeat text.
+func (c *FallbackClient) StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk services.StreamHandler) (*services.LLMResponse, error) {
+	streamed := false
+	forward := func(chunk string) error {
+		streamed = true
+		return onChunk(chunk)
+	}
+	return c.run(ctx, &streamed, func(client services.LLMClient) (*services.LLMResponse, error) {
+		return client.StreamResponse(ctx, history, systemPrompt, forward)
+	})
+}
+
+func (c *FallbackClient) run(ctx context.Context, streamed *bool, call func(services.LLMClient) (*services.LLMResponse, error)) (*services.LLMResponse, error) {
+	var lastErr error
+	for _, p := range c.providers {
+		if !p.breaker.Allow() {
+			log.Printf("LLM provider %s skipped: circuit breaker is open", p.Name)
+			continue
+		}
+
+		var class ErrorClass
+		for attempt := 1; attempt <= c.policy.MaxAttempts; attempt++ {
+			if attempt > 1 {
+				if err := sleepContext(ctx, c.backoff(attempt, class)); err != nil {
+					p.breaker.Abandon()
+					return nil, err
+				}
+			}
+
+			resp, err := call(p.Client)
+			if err == nil {
+				p.breaker.Success()
+				resp.Provider = p.Name
+				return resp, nil
+			}
+
+			class = ClassifyError(err)
+			lastErr = fmt.Errorf("%s: %w", p.Name, err)
+			log.Printf("LLM provider %s failed (attempt %d, %s): %v", p.Name, attempt, class, err)
+
+			// The provider is healthy, it just refused to answer. Asking another model is not an option.
+			if class == ErrorSafetyBlocked {
+				p.breaker.Success()
+				if errors.Is(lastErr, services.ErrResponseBlocked) {
+					return nil, lastErr
+				}
+				return nil, fmt.Errorf("%w: %v", services.ErrResponseBlocked, lastErr)
+			}
+			if ctx.Err() != nil {
+				p.breaker.Abandon()
+				return nil, ctx.Err()
+			}
+			if streamed != nil && *streamed {
+				p.breaker.Failure()
+				return nil, lastErr
+			}
+			if class == ErrorFatal {
+				break
+			}
+		}
+		p.breaker.Failure()
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("all circuit breakers are open")
+	}
+	return nil, fmt.Errorf("%w: %v", services.ErrLLMUnavailable, lastErr)
+}
+
+// backoff returns the delay before the given attempt: exponential, capped and with jitter.
+// Rate-limited providers get twice as much time to recover.
+func (c *FallbackClient) backoff(attempt int, class ErrorClass) time.Duration {
+	delay := c.policy.BaseDelay << (attempt - 2)
+	if class == ErrorRateLimited {
+		delay *= 2
+	}
+	if delay <= 0 || delay > c.policy.MaxDelay {
+		delay = c.policy.MaxDelay
+	}
+	// "Equal jitter": keep half of the delay and randomize the other half.
+	half := delay / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// sleepContext waits for d or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,170 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/fallback_test.go
+package llm
+
+import (
+	"context"
+	"errors"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProvider answers each call with the next scripted outcome; a nil error is a success.
+// The last outcome is repeated once the script is used up.
+type fakeProvider struct {
+	outcomes []fakeOutcome
+	calls    int
+}
+
+type fakeOutcome struct {
+	chunks []string // Streamed before the outcome
+	err    error
+}
+
+func (p *fakeProvider) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*services.LLMResponse, error) {
+	return p.StreamResponse(ctx, history, systemPrompt, func(string) error { return nil })
+}
+
+func (p *fakeProvider) StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk services.StreamHandler) (*services.LLMResponse, error) {
+	outcome := p.outcomes[min(p.calls, len(p.outcomes)-1)]
+	p.calls++
+	for _, chunk := range outcome.chunks {
+		if err := onChunk(chunk); err != nil {
+			return nil, err
+		}
+	}
+	if outcome.err != nil {
+		return nil, outcome.err
+	}
+	return &services.LLMResponse{Content: "answer", FinishReason: services.FinishReasonStop}, nil
+}
+
+func failWith(class ErrorClass) fakeOutcome {
+	return fakeOutcome{err: &ProviderError{Class: class, Err: errors.New(class.String())}}
+}
+
+var succeed = fakeOutcome{}
+
+func testFallbackPolicy() FallbackPolicy {
+	return FallbackPolicy{
+		MaxAttempts:      3,
+		BaseDelay:        time.Millisecond,
+		MaxDelay:         2 * time.Millisecond,
+		FailureThreshold: 2,
+		Cooldown:         time.Minute,
+	}
+}
+
+func TestFallbackClient(t *testing.T) {
+	tests := []struct {
+		name         string
+		stream       bool
+		primary      []fakeOutcome
+		secondary    []fakeOutcome
+		wantProvider string
+		wantErr      error
+		wantCalls    [2]int // Calls of the primary and the secondary provider
+	}{
+		{name: "success", primary: []fakeOutcome{succeed}, secondary: []fakeOutcome{succeed}, wantProvider: "primary", wantCalls: [2]int{1, 0}},
+		{name: "retryable then success", primary: []fakeOutcome{failWith(ErrorRetryable), succeed}, secondary: []fakeOutcome{succeed}, wantProvider: "primary", wantCalls: [2]int{2, 0}},
+		{name: "fatal falls through", primary: []fakeOutcome{failWith(ErrorFatal)}, secondary: []fakeOutcome{succeed}, wantProvider: "secondary", wantCalls: [2]int{1, 1}},
+		{name: "rate limited uses up the attempts", primary: []fakeOutcome{failWith(ErrorRateLimited)}, secondary: []fakeOutcome{succeed}, wantProvider: "secondary", wantCalls: [2]int{3, 1}},
+		{name: "safety block stops the chain", primary: []fakeOutcome{failWith(ErrorSafetyBlocked)}, secondary: []fakeOutcome{succeed}, wantErr: services.ErrResponseBlocked, wantCalls: [2]int{1, 0}},
+		{name: "all providers fail", primary: []fakeOutcome{failWith(ErrorFatal)}, secondary: []fakeOutcome{failWith(ErrorRetryable)}, wantErr: services.ErrLLMUnavailable, wantCalls: [2]int{1, 3}},
+		{name: "stream retried before the first chunk", stream: true, primary: []fakeOutcome{failWith(ErrorRetryable), succeed}, secondary: []fakeOutcome{succeed}, wantProvider: "primary", wantCalls: [2]int{2, 0}},
+		{
+			name:      "broken stream is not retried",
+			stream:    true,
+			primary:   []fakeOutcome{{chunks: []string{"half an "}, err: &ProviderError{Class: ErrorRetryable, Err: errors.New("connection reset")}}},
+			secondary: []fakeOutcome{succeed},
+			wantCalls: [2]int{1, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, secondary := &fakeProvider{outcomes: tt.primary}, &fakeProvider{outcomes: tt.secondary}
+			client := NewFallbackClient(testFallbackPolicy(), NamedClient{Name: "primary", Client: primary}, NamedClient{Name: "secondary", Client: secondary})
+
+			var resp *services.LLMResponse
+			var err error
+			if tt.stream {
+				resp, err = client.StreamResponse(context.Background(), userTurns("hi"), "", func(string) error { return nil })
+			} else {
+				resp, err = client.GenerateResponse(context.Background(), userTurns("hi"), "")
+			}
+
+			if calls := [2]int{primary.calls, secondary.calls}; calls != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if tt.wantProvider == "" {
+				if err == nil {
+					t.Fatalf("succeeded with %s, want an error", resp.Provider)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Provider != tt.wantProvider {
+				t.Errorf("provider = %q, want %q", resp.Provider, tt.wantProvider)
+			}
+		})
+	}
+}
+
+func TestFallbackClientSkipsOpenBreaker(t *testing.T) {
+	primary := &fakeProvider{outcomes: []fakeOutcome{failWith(ErrorFatal)}}
+	secondary := &fakeProvider{outcomes: []fakeOutcome{succeed}}
+	client := NewFallbackClient(testFallbackPolicy(), NamedClient{Name: "primary", Client: primary}, NamedClient{Name: "secondary", Client: secondary})
+
+	// Two failed requests reach the threshold; the third one goes straight to the secondary provider.
+	for i := 0; i < 3; i++ {
+		resp, err := client.GenerateResponse(context.Background(), userTurns("hi"), "")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if resp.Provider != "secondary" {
+			t.Errorf("request %d: provider = %q, want secondary", i, resp.Provider)
+		}
+	}
+	if primary.calls != 2 {
+		t.Errorf("primary called %d times, want 2", primary.calls)
+	}
+}
+
+func TestFallbackClientAllBreakersOpen(t *testing.T) {
+	primary := &fakeProvider{outcomes: []fakeOutcome{failWith(ErrorFatal)}}
+	client := NewFallbackClient(testFallbackPolicy(), NamedClient{Name: "primary", Client: primary})
+
+	for i := 0; i < 2; i++ {
+		client.GenerateResponse(context.Background(), userTurns("hi"), "")
+	}
+	_, err := client.GenerateResponse(context.Background(), userTurns("hi"), "")
+	if !errors.Is(err, services.ErrLLMUnavailable) || !strings.Contains(err.Error(), "circuit breakers are open") {
+		t.Errorf("error = %v, want ErrLLMUnavailable with open breakers", err)
+	}
+	if primary.calls != 2 {
+		t.Errorf("primary called %d times, want 2", primary.calls)
+	}
+}
+
+func TestFallbackClientStopsOnCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	primary := &fakeProvider{outcomes: []fakeOutcome{failWith(ErrorRetryable)}}
+	secondary := &fakeProvider{outcomes: []fakeOutcome{succeed}}
+	client := NewFallbackClient(testFallbackPolicy(), NamedClient{Name: "primary", Client: primary}, NamedClient{Name: "secondary", Client: secondary})
+
+	cancel()
+	if _, err := client.GenerateResponse(ctx, userTurns("hi"), ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if secondary.calls != 0 {
+		t.Errorf("secondary called %d times after the caller went away", secondary.calls)
+	}
+}
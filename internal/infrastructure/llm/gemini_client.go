@@ -3,67 +3,267 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/DauletBai/oilan.org/internal/app/services"
 	"github.com/DauletBai/oilan.org/internal/domain"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
+// DefaultGeminiModel is used when no model is configured.
+const DefaultGeminiModel = "gemini-1.5-pro-latest"
+
+// GeminiClient implements the LLMClient interface for the Google Gemini API.
+// The underlying genai.Client is safe for concurrent use and everything that depends on the
+// request (system prompt, history) lives in a model created for that request only, so a single
+// GeminiClient can serve any number of goroutines.
 type GeminiClient struct {
-	client *genai.GenerativeModel
+	client    *genai.Client
+	modelName string
 }
 
-func NewGeminiClient() (services.LLMClient, error) {
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+// NewGeminiClient creates a new client for interacting with Gemini.
+func NewGeminiClient(cfg Config) (services.LLMClient, error) {
+	opts := []option.ClientOption{option.WithAPIKey(cfg.APIKey)}
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithEndpoint(cfg.BaseURL))
+	}
+
+	client, err := genai.NewClient(context.Background(), opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create genai client: %w", err)
 	}
-	model := client.GenerativeModel("gemini-1.5-pro-latest")
-	return &GeminiClient{client: model}, nil
+
+	modelName := cfg.Model
+	if modelName == "" {
+		modelName = DefaultGeminiModel
+	}
+	return &GeminiClient{client: client, modelName: modelName}, nil
+}
+
+// GenerateResponse sends the history as chat turns and the last user message as the new turn.
+func (c *GeminiClient) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*services.LLMResponse, error) {
+	// 1. Prepare a chat session for this request.
+	params := services.GenerationParamsFromContext(ctx)
+	chat, last, err := c.startChat(history, systemPrompt, params)
+	if err != nil {
+		return nil, classify("gemini", err)
+	}
+
+	// 2. Send the last user message.
+	start := time.Now()
+	resp, err := chat.SendMessage(ctx, last...)
+	if err != nil {
+		return nil, classify("gemini", geminiError(err))
+	}
+
+	// 3. Collect the text of the answer together with its metadata.
+	text, err := responseText(resp)
+	if err != nil {
+		return nil, classify("gemini", err)
+	}
+	if text == "" {
+		return nil, classify("gemini", emptyGeminiResponse(resp))
+	}
+	result := c.newResponse(text, params, start)
+	addGeminiMetadata(result, resp)
+	return result, nil
 }
 
-// GenerateResponse now sends the entire context in a single, clean request.
-func (c *GeminiClient) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (string, error) {
-	// Set the system prompt for this specific request.
-	c.client.SystemInstruction = &genai.Content{
-		Parts: []genai.Part{genai.Text(systemPrompt)},
+// StreamResponse sends the same request as GenerateResponse but passes each streamed chunk to onChunk.
+func (c *GeminiClient) StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk services.StreamHandler) (*services.LLMResponse, error) {
+	params := services.GenerationParamsFromContext(ctx)
+	chat, last, err := c.startChat(history, systemPrompt, params)
+	if err != nil {
+		return nil, classify("gemini", err)
 	}
 
-	// Start a new chat session.
-	chat := c.client.StartChat()
-	
-	// Convert our entire history to Gemini's format.
-	chat.History = make([]*genai.Content, 0, len(history))
+	start := time.Now()
+	iter := chat.SendMessageStream(ctx, last...)
+	var full strings.Builder
+	var final *genai.GenerateContentResponse
+	for {
+		resp, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, classify("gemini", geminiError(err))
+		}
+		final = resp // Usage and the finish reason arrive with the final chunk
+
+		chunk, err := responseText(resp)
+		if err != nil {
+			return nil, classify("gemini", err)
+		}
+		if chunk == "" {
+			continue
+		}
+		full.WriteString(chunk)
+		if err := onChunk(chunk); err != nil {
+			return nil, err
+		}
+	}
+
+	if full.Len() == 0 {
+		return nil, classify("gemini", emptyGeminiResponse(final))
+	}
+	result := c.newResponse(full.String(), params, start)
+	addGeminiMetadata(result, final)
+	return result, nil
+}
+
+func (c *GeminiClient) newResponse(content string, params services.GenerationParams, start time.Time) *services.LLMResponse {
+	return &services.LLMResponse{
+		Content:  content,
+		Provider: "gemini",
+		Model:    params.Model("gemini", c.modelName),
+		Latency:  time.Since(start),
+	}
+}
+
+// startChat creates a model configured for this request only and a chat session holding every turn
+// but the last one. The last turn, which must come from the user, is returned as the message to send.
+func (c *GeminiClient) startChat(history []domain.Message, systemPrompt string, params services.GenerationParams) (*genai.ChatSession, []genai.Part, error) {
+	contents := geminiContents(history)
+	if len(contents) == 0 || contents[len(contents)-1].Role != "user" {
+		return nil, nil, errors.New("the last message must come from the user")
+	}
+
+	model := c.client.GenerativeModel(params.Model("gemini", c.modelName))
+	if systemPrompt != "" {
+		model.SystemInstruction = genai.NewUserContent(genai.Text(systemPrompt))
+	}
+	if params.Temperature != nil {
+		model.SetTemperature(float32(*params.Temperature))
+	}
+	if params.MaxTokens > 0 {
+		model.SetMaxOutputTokens(int32(params.MaxTokens))
+	}
+
+	chat := model.StartChat()
+	chat.History = contents[:len(contents)-1]
+	return chat, contents[len(contents)-1].Parts, nil
+}
+
+// geminiContents converts our history to Gemini's format. Gemini expects user and model turns
+// to alternate, so consecutive messages of the same role (e.g. a user message whose reply
+// failed, followed by a new one) are merged into a single turn.
+func geminiContents(history []domain.Message) []*genai.Content {
+	contents := make([]*genai.Content, 0, len(history))
 	for _, msg := range history {
-		var role string
-		if msg.Role == domain.RoleUser {
-			role = "user"
-		} else {
+		if msg.Content == "" {
+			continue
+		}
+		role := "user"
+		if msg.Role != domain.RoleUser {
 			role = "model" // Gemini uses "model" for the AI's role
 		}
-		chat.History = append(chat.History, &genai.Content{
-			Role:  role,
-			Parts: []genai.Part{genai.Text(msg.Content)},
-		})
-	}
-	
-	// The prompt is the entire history. We send an empty message to get a response.
-	resp, err := chat.SendMessage(ctx, genai.Text("")) // Send empty message to continue the conversation
-	if err != nil {
-		return "", fmt.Errorf("failed to send message to gemini: %w", err)
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, genai.Text(msg.Content))
+			continue
+		}
+		contents = append(contents, &genai.Content{Role: role, Parts: []genai.Part{genai.Text(msg.Content)}})
+	}
+	return contents
+}
+
+// responseText joins the text parts of the first candidate in a Gemini response. A candidate may
+// consist of several parts; anything that is not text is ignored.
+func responseText(resp *genai.GenerateContentResponse) (string, error) {
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != genai.BlockReasonUnspecified {
+		return "", geminiError(&genai.BlockedError{PromptFeedback: resp.PromptFeedback})
+	}
+	if len(resp.Candidates) == 0 {
+		return "", nil
+	}
+	candidate := resp.Candidates[0]
+	if candidate.FinishReason == genai.FinishReasonSafety || candidate.FinishReason == genai.FinishReasonRecitation {
+		return "", geminiError(&genai.BlockedError{Candidate: candidate})
+	}
+	if candidate.Content == nil {
+		return "", nil
+	}
+
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		if textPart, ok := part.(genai.Text); ok {
+			text.WriteString(string(textPart))
+		}
+	}
+	return text.String(), nil
+}
+
+// addGeminiMetadata copies token usage and the finish reason from a Gemini response.
+func addGeminiMetadata(result *services.LLMResponse, resp *genai.GenerateContentResponse) {
+	if resp == nil {
+		return
+	}
+	if resp.UsageMetadata != nil {
+		result.PromptTokens = int(resp.UsageMetadata.PromptTokenCount)
+		result.CompletionTokens = int(resp.UsageMetadata.CandidatesTokenCount)
 	}
+	if len(resp.Candidates) > 0 {
+		result.FinishReason = geminiFinishReason(resp.Candidates[0].FinishReason)
+	}
+}
 
-	// Extract and return the text content from the response.
-	if len(resp.Candidates) > 0 && len(resp.Candidates[0].Content.Parts) > 0 {
-		if textPart, ok := resp.Candidates[0].Content.Parts[0].(genai.Text); ok {
-			return string(textPart), nil
+func geminiFinishReason(reason genai.FinishReason) string {
+	switch reason {
+	case genai.FinishReasonStop:
+		return services.FinishReasonStop
+	case genai.FinishReasonMaxTokens:
+		return services.FinishReasonMaxTokens
+	case genai.FinishReasonSafety, genai.FinishReasonRecitation:
+		return services.FinishReasonSafety
+	default:
+		return services.FinishReasonOther
+	}
+}
+
+// geminiError explains a blocked response of the Gemini SDK: it wraps services.ErrResponseBlocked
+// and names the safety categories that caused the block. Other errors are returned as they are.
+func geminiError(err error) error {
+	var blockedErr *genai.BlockedError
+	if !errors.As(err, &blockedErr) {
+		return err
+	}
+
+	var ratings []*genai.SafetyRating
+	reason := "response blocked"
+	if blockedErr.PromptFeedback != nil {
+		reason = "prompt blocked: " + blockedErr.PromptFeedback.BlockReason.String()
+		ratings = blockedErr.PromptFeedback.SafetyRatings
+	} else if blockedErr.Candidate != nil {
+		reason = "response blocked: " + blockedErr.Candidate.FinishReason.String()
+		ratings = blockedErr.Candidate.SafetyRatings
+	}
+
+	var flagged []string
+	for _, rating := range ratings {
+		if rating.Blocked || rating.Probability >= genai.HarmProbabilityMedium {
+			flagged = append(flagged, rating.Category.String()+"="+rating.Probability.String())
 		}
 	}
+	if len(flagged) > 0 {
+		reason += " (" + strings.Join(flagged, ", ") + ")"
+	}
+	return fmt.Errorf("%w: %s", services.ErrResponseBlocked, reason)
+}
 
-	return "", fmt.Errorf("no text content found in Gemini response")
-}
\ No newline at end of file
+// emptyGeminiResponse explains why Gemini finished without any text.
+func emptyGeminiResponse(resp *genai.GenerateContentResponse) error {
+	reason := genai.FinishReasonUnspecified
+	if resp != nil && len(resp.Candidates) > 0 {
+		reason = resp.Candidates[0].FinishReason
+	}
+	if reason == genai.FinishReasonMaxTokens {
+		return fmt.Errorf("%w: no text", errOutputLimit)
+	}
+	return fmt.Errorf("%w: finish reason %s", errEmptyResponse, reason)
+}
@@ -5,9 +5,12 @@ import (
 	"context"
 	"github.com/DauletBai/oilan.org/internal/app/services"
 	"github.com/DauletBai/oilan.org/internal/domain"
+	"strings"
 	"time"
 )
 
+const mockResponse = "This is a mock response from the AI. The real LLM is not connected yet."
+
 // MockLLMClient is a dummy implementation of the LLMClient for testing.
 type MockLLMClient struct{}
 
@@ -17,10 +20,39 @@ func NewMockLLMClient() services.LLMClient {
 }
 
 // GenerateResponse simulates a response from an LLM.
-func (c *MockLLMClient) GenerateResponse(ctx context.Context, history []domain.Message, prompt string) (string, error) {
+func (c *MockLLMClient) GenerateResponse(ctx context.Context, history []domain.Message, prompt string) (*services.LLMResponse, error) {
 	// Simulate a network delay
+	start := time.Now()
 	time.Sleep(1 * time.Second)
 	
 	// Return a fixed, pre-programmed response.
-	return "This is a mock response from the AI. The real LLM is not connected yet.", nil
-}
\ No newline at end of file
+	return newMockResponse(start), nil
+}
+
+// StreamResponse simulates a streamed response by emitting the fixed response word by word.
+func (c *MockLLMClient) StreamResponse(ctx context.Context, history []domain.Message, prompt string, onChunk services.StreamHandler) (*services.LLMResponse, error) {
+	start := time.Now()
+	for i, word := range strings.SplitAfter(mockResponse, " ") {
+		if i > 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := onChunk(word); err != nil {
+			return nil, err
+		}
+	}
+	return newMockResponse(start), nil
+}
+
+func newMockResponse(start time.Time) *services.LLMResponse {
+	return &services.LLMResponse{
+		Content:          mockResponse,
+		Provider:         "mock",
+		Model:            "mock",
+		CompletionTokens: len(strings.Fields(mockResponse)),
+		Latency:          time.Since(start),
+		FinishReason:     services.FinishReasonStop,
+	}
+}
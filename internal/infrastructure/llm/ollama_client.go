@@ -0,0 +1,292 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/ollama_client.go
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultOllamaURL is where a local Ollama server listens by default.
+	DefaultOllamaURL = "http://localhost:11434"
+	// DefaultOllamaModel is used when no model is configured.
+	DefaultOllamaModel = "llama3.1"
+)
+
+// OllamaClient implements the LLMClient interface with Ollama's native /api/chat protocol,
+// so that conversations never leave the machines we run on.
+type OllamaClient struct {
+	httpClient *http.Client
+	baseURL    string
+	model      string
+	options    ollamaOptions
+	keepAlive  string
+}
+
+// ollamaOptions are the model parameters sent with every request. Zero values are left to Ollama.
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	NumCtx      int      `json:"num_ctx,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"` // Maximum number of tokens to generate
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaChatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Stream    bool            `json:"stream"`
+	Options   ollamaOptions   `json:"options"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
+}
+
+// ollamaChatResponse is a complete response or, when streaming, one line of the NDJSON stream.
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// NewOllamaClient creates a new client for a local or self-hosted Ollama server.
+func NewOllamaClient(cfg Config) (services.LLMClient, error) {
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultOllamaURL
+	}
+	model := cfg.Model
+	if model == "" {
+		model = DefaultOllamaModel
+	}
+	return &OllamaClient{
+		httpClient: &http.Client{}, // Local models can take minutes to answer; the request context sets the limit
+		baseURL:    baseURL,
+		model:      model,
+		options:    ollamaOptions{Temperature: cfg.Temperature, NumCtx: cfg.NumCtx},
+		keepAlive:  cfg.KeepAlive,
+	}, nil
+}
+
+// GenerateResponse sends the conversation history to Ollama and waits for the complete answer.
+func (c *OllamaClient) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*services.LLMResponse, error) {
+	// 1. Send the request.
+	start := time.Now()
+	req := c.newRequest(history, systemPrompt, services.GenerationParamsFromContext(ctx), false)
+	body, err := c.chat(ctx, req)
+	if err != nil {
+		return nil, classify("ollama", err)
+	}
+	defer body.Close()
+
+	// 2. Decode the answer.
+	var resp ollamaChatResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, classify("ollama", fmt.Errorf("%w: %w", errMalformedResponse, err))
+	}
+	if resp.Error != "" {
+		return nil, classify("ollama", errors.New(resp.Error))
+	}
+
+	// 3. Return the content together with its metadata.
+	result := c.newResponse(req.Model, start)
+	result.Content = resp.Message.Content
+	addOllamaMetadata(result, &resp)
+	if result.Content == "" {
+		return nil, classify("ollama", emptyResponseError(result.FinishReason))
+	}
+	return result, nil
+}
+
+// StreamResponse sends the conversation history to Ollama and forwards every streamed line to onChunk.
+func (c *OllamaClient) StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk services.StreamHandler) (*services.LLMResponse, error) {
+	start := time.Now()
+	req := c.newRequest(history, systemPrompt, services.GenerationParamsFromContext(ctx), true)
+	body, err := c.chat(ctx, req)
+	if err != nil {
+		return nil, classify("ollama", err)
+	}
+	defer body.Close()
+
+	result := c.newResponse(req.Model, start)
+	var full strings.Builder
+	done := false
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var resp ollamaChatResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return nil, classify("ollama", fmt.Errorf("%w: %w", errMalformedResponse, err))
+		}
+		if resp.Error != "" {
+			return nil, classify("ollama", errors.New(resp.Error))
+		}
+
+		if chunk := resp.Message.Content; chunk != "" {
+			full.WriteString(chunk)
+			if err := onChunk(chunk); err != nil {
+				return nil, err
+			}
+		}
+		if resp.Done {
+			addOllamaMetadata(result, &resp)
+			done = true
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, classify("ollama", fmt.Errorf("failed to read stream: %w", err))
+	}
+	if !done {
+		return nil, classify("ollama", fmt.Errorf("%w: stream ended before the answer was complete", errMalformedResponse))
+	}
+
+	if full.Len() == 0 {
+		return nil, classify("ollama", emptyResponseError(result.FinishReason))
+	}
+
+	result.Content = full.String()
+	result.Latency = time.Since(start)
+	return result, nil
+}
+
+// HealthCheck confirms that the server is reachable and the configured model has been pulled.
+func (c *OllamaClient) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ollama is not reachable at %s: %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama returned %s for /api/tags", resp.Status)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return fmt.Errorf("failed to decode ollama tags: %w", err)
+	}
+	for _, m := range tags.Models {
+		if ollamaModelName(m.Name) == ollamaModelName(c.model) {
+			return nil
+		}
+	}
+	return fmt.Errorf("ollama model %q is not pulled; run `ollama pull %s`", c.model, c.model)
+}
+
+// chat posts a request to /api/chat and returns the response body of a successful call.
+func (c *OllamaClient) chat(ctx context.Context, chatReq ollamaChatRequest) (io.ReadCloser, error) {
+	payload, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/chat", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&apiErr)
+		if apiErr.Error == "" {
+			apiErr.Error = resp.Status
+		}
+		return nil, &httpStatusError{StatusCode: resp.StatusCode, Message: apiErr.Error}
+	}
+	return resp.Body, nil
+}
+
+// newRequest builds a chat request from the system prompt, the conversation history and the
+// generation parameters of the request, which take precedence over the configured options.
+func (c *OllamaClient) newRequest(history []domain.Message, systemPrompt string, params services.GenerationParams, stream bool) ollamaChatRequest {
+	messages := make([]ollamaMessage, 0, len(history)+1)
+	if systemPrompt != "" {
+		messages = append(messages, ollamaMessage{Role: "system", Content: systemPrompt})
+	}
+	for _, msg := range history {
+		role := "assistant"
+		if msg.Role == domain.RoleUser {
+			role = "user"
+		}
+		messages = append(messages, ollamaMessage{Role: role, Content: msg.Content})
+	}
+	options := c.options
+	if params.Temperature != nil {
+		options.Temperature = params.Temperature
+	}
+	options.NumPredict = params.MaxTokens
+	return ollamaChatRequest{
+		Model:     params.Model("ollama", c.model),
+		Messages:  messages,
+		Stream:    stream,
+		Options:   options,
+		KeepAlive: c.keepAlive,
+	}
+}
+
+func (c *OllamaClient) newResponse(model string, start time.Time) *services.LLMResponse {
+	return &services.LLMResponse{
+		Provider: "ollama",
+		Model:    model,
+		Latency:  time.Since(start),
+	}
+}
+
+// addOllamaMetadata copies token usage and the finish reason from the final Ollama response.
+func addOllamaMetadata(result *services.LLMResponse, resp *ollamaChatResponse) {
+	if resp.Model != "" {
+		result.Model = resp.Model
+	}
+	result.PromptTokens = resp.PromptEvalCount
+	result.CompletionTokens = resp.EvalCount
+	switch resp.DoneReason {
+	case "stop", "":
+		result.FinishReason = services.FinishReasonStop
+	case "length":
+		result.FinishReason = services.FinishReasonMaxTokens
+	default:
+		result.FinishReason = services.FinishReasonOther
+	}
+}
+
+// ollamaModelName normalizes a model name; Ollama treats "llama3.1" and "llama3.1:latest" as the same model.
+func ollamaModelName(name string) string {
+	if !strings.Contains(name, ":") {
+		return name + ":latest"
+	}
+	return name
+}
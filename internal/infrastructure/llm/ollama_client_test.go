@@ -0,0 +1,300 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/ollama_client_test.go
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ollamaStub serves /api/chat with a fixed status and body and keeps the last request it received.
+type ollamaStub struct {
+	status  int
+	body    string
+	request ollamaChatRequest
+}
+
+func (s *ollamaStub) start(t *testing.T) services.LLMClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&s.request); err != nil {
+			t.Errorf("stub received an invalid request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(s.status)
+		io.WriteString(w, s.body)
+	}))
+	t.Cleanup(server.Close)
+
+	temperature := 0.4 // Matches the configured temperature TestOllamaClientRequest expects
+	client, err := NewOllamaClient(Config{BaseURL: server.URL + "/", Model: "llama3.1", Temperature: &temperature, NumCtx: 8192, KeepAlive: "10m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestOllamaClientStreamResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantChunks []string
+		wantFinish string
+		wantTokens [2]int
+		wantErr    bool
+		wantClass  ErrorClass
+	}{
+		{
+			name: "chunks until the done frame",
+			body: `{"model":"llama3.1:8b","message":{"role":"assistant","content":"Hel"},"done":false}
+{"model":"llama3.1:8b","message":{"role":"assistant","content":"lo!"},"done":false}
+
+{"model":"llama3.1:8b","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":3}
+{"model":"llama3.1:8b","message":{"role":"assistant","content":"ignored"},"done":false}
+`,
+			wantChunks: []string{"Hel", "lo!"},
+			wantFinish: services.FinishReasonStop,
+			wantTokens: [2]int{12, 3},
+		},
+		{
+			name: "content in the done frame",
+			body: `{"message":{"content":"Cut "},"done":false}
+{"message":{"content":"off"},"done":true,"done_reason":"length","prompt_eval_count":5,"eval_count":2}`,
+			wantChunks: []string{"Cut ", "off"},
+			wantFinish: services.FinishReasonMaxTokens,
+			wantTokens: [2]int{5, 2},
+		},
+		{
+			name:       "stream ends without the done frame",
+			body:       `{"message":{"content":"Hel"},"done":false}` + "\n",
+			wantChunks: []string{"Hel"},
+			wantErr:    true,
+			wantClass:  ErrorRetryable,
+		},
+		{
+			name:      "line that is no json",
+			body:      "not json\n",
+			wantErr:   true,
+			wantClass: ErrorRetryable,
+		},
+		{
+			name:      "error line",
+			body:      `{"error":"model requires more system memory"}` + "\n",
+			wantErr:   true,
+			wantClass: ErrorFatal,
+		},
+		{
+			name:      "done without any text",
+			body:      `{"message":{"content":""},"done":true,"done_reason":"stop"}` + "\n",
+			wantErr:   true,
+			wantClass: ErrorRetryable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &ollamaStub{status: http.StatusOK, body: tt.body}
+			client := stub.start(t)
+
+			var chunks []string
+			resp, err := client.StreamResponse(context.Background(), userTurns("hi"), "Be brief.", func(chunk string) error {
+				chunks = append(chunks, chunk)
+				return nil
+			})
+			if strings.Join(chunks, "|") != strings.Join(tt.wantChunks, "|") {
+				t.Errorf("chunks = %q, want %q", chunks, tt.wantChunks)
+			}
+			if !stub.request.Stream {
+				t.Error("request was not sent as a stream")
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("StreamResponse() = %q, want an error", resp.Content)
+				}
+				if class := ClassifyError(err); class != tt.wantClass {
+					t.Errorf("error class = %v, want %v (%v)", class, tt.wantClass, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StreamResponse: %v", err)
+			}
+			if resp.Content != strings.Join(tt.wantChunks, "") || resp.FinishReason != tt.wantFinish {
+				t.Errorf("response = %q (%s), want %q (%s)", resp.Content, resp.FinishReason, strings.Join(tt.wantChunks, ""), tt.wantFinish)
+			}
+			if tokens := [2]int{resp.PromptTokens, resp.CompletionTokens}; tokens != tt.wantTokens {
+				t.Errorf("tokens = %v, want %v", tokens, tt.wantTokens)
+			}
+			if resp.Provider != "ollama" {
+				t.Errorf("provider = %q, want ollama", resp.Provider)
+			}
+		})
+	}
+}
+
+func TestOllamaClientRequest(t *testing.T) {
+	configured, temperature, zero := 0.4, 0.9, 0.0
+	tests := []struct {
+		name            string
+		params          services.GenerationParams
+		wantModel       string
+		wantTemperature *float64
+		wantNumPredict  int
+	}{
+		{name: "configured options", wantModel: "llama3.1", wantTemperature: &configured},
+		{name: "request parameters win", params: services.GenerationParams{Models: map[string]string{"ollama": "qwen2.5:7b", "openai": "gpt-4o"}, Temperature: &temperature, MaxTokens: 256}, wantModel: "qwen2.5:7b", wantTemperature: &temperature, wantNumPredict: 256},
+		{name: "zero temperature", params: services.GenerationParams{Temperature: &zero}, wantModel: "llama3.1", wantTemperature: &zero},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &ollamaStub{status: http.StatusOK, body: `{"message":{"content":"Hi!"},"done":true}`}
+			client := stub.start(t)
+
+			ctx := services.WithGenerationParams(context.Background(), tt.params)
+			history := []domain.Message{
+				{Role: domain.RoleUser, Content: "hi"},
+				{Role: domain.RoleAI, Content: "Hello!"},
+				{Role: domain.RoleUser, Content: "how are you?"},
+			}
+			resp, err := client.GenerateResponse(ctx, history, "Be brief.")
+			if err != nil {
+				t.Fatalf("GenerateResponse: %v", err)
+			}
+			if resp.Content != "Hi!" || resp.FinishReason != services.FinishReasonStop {
+				t.Errorf("response = %q (%s)", resp.Content, resp.FinishReason)
+			}
+
+			req := stub.request
+			if req.Stream {
+				t.Error("complete answer was requested as a stream")
+			}
+			if req.Model != tt.wantModel {
+				t.Errorf("model = %q, want %q", req.Model, tt.wantModel)
+			}
+			if req.KeepAlive != "10m" {
+				t.Errorf("keep_alive = %q, want 10m", req.KeepAlive)
+			}
+			if req.Options.Temperature == nil || *req.Options.Temperature != *tt.wantTemperature {
+				t.Errorf("temperature = %v, want %v", req.Options.Temperature, *tt.wantTemperature)
+			}
+			if req.Options.NumCtx != 8192 || req.Options.NumPredict != tt.wantNumPredict {
+				t.Errorf("options = %+v, want num_ctx 8192 and num_predict %d", req.Options, tt.wantNumPredict)
+			}
+
+			var roles []string
+			for _, msg := range req.Messages {
+				roles = append(roles, msg.Role)
+			}
+			if got := strings.Join(roles, ","); got != "system,user,assistant,user" {
+				t.Errorf("roles = %s, want system,user,assistant,user", got)
+			}
+			if req.Messages[0].Content != "Be brief." {
+				t.Errorf("system message = %q", req.Messages[0].Content)
+			}
+		})
+	}
+}
+
+func TestOllamaClientStatusErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantClass   ErrorClass
+		wantMessage string
+	}{
+		{name: "model loading", status: http.StatusServiceUnavailable, body: `{"error":"server busy"}`, wantClass: ErrorRetryable, wantMessage: "ollama: server busy"},
+		{name: "server error without a body", status: http.StatusInternalServerError, wantClass: ErrorRetryable, wantMessage: "ollama: 500 Internal Server Error"},
+		{name: "too many requests", status: http.StatusTooManyRequests, wantClass: ErrorRateLimited},
+		{name: "model not pulled", status: http.StatusNotFound, body: `{"error":"model \"llama3.1\" not found, try pulling it first"}`, wantClass: ErrorFatal},
+		{name: "bad request", status: http.StatusBadRequest, body: `{"error":"invalid options"}`, wantClass: ErrorFatal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := (&ollamaStub{status: tt.status, body: tt.body}).start(t)
+			for _, stream := range []bool{false, true} {
+				var err error
+				if stream {
+					_, err = client.StreamResponse(context.Background(), userTurns("hi"), "", func(string) error { return nil })
+				} else {
+					_, err = client.GenerateResponse(context.Background(), userTurns("hi"), "")
+				}
+				if err == nil {
+					t.Fatalf("stream %v: succeeded, want an error", stream)
+				}
+				if class := ClassifyError(err); class != tt.wantClass {
+					t.Errorf("stream %v: class = %v, want %v (%v)", stream, class, tt.wantClass, err)
+				}
+				if tt.wantMessage != "" && err.Error() != tt.wantMessage {
+					t.Errorf("stream %v: error = %q, want %q", stream, err, tt.wantMessage)
+				}
+			}
+		})
+	}
+}
+
+func TestOllamaClientHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		model   string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{name: "model pulled as latest", model: "llama3.1", status: http.StatusOK, body: `{"models":[{"name":"qwen2.5:7b"},{"name":"llama3.1:latest"}]}`},
+		{name: "model with a tag", model: "qwen2.5:7b", status: http.StatusOK, body: `{"models":[{"name":"qwen2.5:7b"}]}`},
+		{name: "model not pulled", model: "llama3.1", status: http.StatusOK, body: `{"models":[{"name":"llama3.1:70b"}]}`, wantErr: "ollama pull llama3.1"},
+		{name: "server error", model: "llama3.1", status: http.StatusInternalServerError, wantErr: "500"},
+		{name: "invalid answer", model: "llama3.1", status: http.StatusOK, body: `<html>`, wantErr: "decode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/tags" || r.Method != http.MethodGet {
+					http.NotFound(w, r)
+					return
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			client, err := NewOllamaClient(Config{BaseURL: server.URL, Model: tt.model})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = client.(*OllamaClient).HealthCheck(context.Background())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("HealthCheck: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("HealthCheck() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("server not reachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		client, _ := NewOllamaClient(Config{BaseURL: server.URL})
+		if err := client.(*OllamaClient).HealthCheck(context.Background()); err == nil || !strings.Contains(err.Error(), "not reachable") {
+			t.Errorf("HealthCheck() error = %v, want not reachable", err)
+		}
+	})
+}
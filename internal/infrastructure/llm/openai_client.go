@@ -3,27 +3,142 @@ package llm
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/DauletBai/oilan.org/internal/app/services"
 	"github.com/DauletBai/oilan.org/internal/domain"
-	"os"
+	"io"
+	"math"
+	"strings"
+	"time"
 
 	"github.com/sashabaranov/go-openai"
 )
 
-// OpenAIClient implements the LLMClient interface for the OpenAI API.
+// DefaultOpenAIModel is used when no model is configured.
+const DefaultOpenAIModel = openai.GPT4o
+
+// OpenAIClient implements the LLMClient interface for the OpenAI API
+// and any server that speaks the same protocol (llama.cpp, vLLM, Ollama, ...).
 type OpenAIClient struct {
 	client *openai.Client
+	model  string
 }
 
-// NewOpenAIClient creates a new client for interacting with OpenAI.
-func NewOpenAIClient() services.LLMClient {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	client := openai.NewClient(apiKey)
-	return &OpenAIClient{client: client}
+// NewOpenAIClient creates a new client for interacting with OpenAI or an OpenAI-compatible server.
+func NewOpenAIClient(cfg Config) (services.LLMClient, error) {
+	clientConfig := openai.DefaultConfig(cfg.APIKey)
+	if cfg.BaseURL != "" {
+		clientConfig.BaseURL = cfg.BaseURL
+	}
+
+	model := cfg.Model
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	return &OpenAIClient{client: openai.NewClientWithConfig(clientConfig), model: model}, nil
 }
 
 // GenerateResponse sends the conversation history to OpenAI and gets a response.
-func (c *OpenAIClient) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (string, error) {
+func (c *OpenAIClient) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*services.LLMResponse, error) {
+	// 1. Create the request to the API.
+	start := time.Now()
+	resp, err := c.client.CreateChatCompletion(ctx, c.newRequest(history, systemPrompt, services.GenerationParamsFromContext(ctx)))
+	if err != nil {
+		return nil, classify("openai", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, classify("openai", fmt.Errorf("%w: no choices", errEmptyResponse))
+	}
+	if resp.Choices[0].Message.Content == "" {
+		return nil, classify("openai", emptyResponseError(openAIFinishReason(resp.Choices[0].FinishReason)))
+	}
+
+	// 2. Return the content of the AI's response together with its metadata.
+	return &services.LLMResponse{
+		Content:          resp.Choices[0].Message.Content,
+		Provider:         "openai",
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		Latency:          time.Since(start),
+		FinishReason:     openAIFinishReason(resp.Choices[0].FinishReason),
+	}, nil
+}
+
+// StreamResponse sends the conversation history to OpenAI and forwards the response deltas to onChunk.
+func (c *OpenAIClient) StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk services.StreamHandler) (*services.LLMResponse, error) {
+	req := c.newRequest(history, systemPrompt, services.GenerationParamsFromContext(ctx))
+	req.Stream = true
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true} // Usage arrives in a final chunk without choices
+
+	start := time.Now()
+	result := &services.LLMResponse{Provider: "openai", Model: req.Model}
+	stream, err := c.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return nil, classify("openai", err)
+	}
+	defer stream.Close()
+
+	var full strings.Builder
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, classify("openai", err)
+		}
+		if resp.Model != "" {
+			result.Model = resp.Model
+		}
+		if resp.Usage != nil {
+			result.PromptTokens = resp.Usage.PromptTokens
+			result.CompletionTokens = resp.Usage.CompletionTokens
+		}
+		if len(resp.Choices) == 0 {
+			continue
+		}
+		if resp.Choices[0].FinishReason != "" {
+			result.FinishReason = openAIFinishReason(resp.Choices[0].FinishReason)
+		}
+		if resp.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		chunk := resp.Choices[0].Delta.Content
+		full.WriteString(chunk)
+		if err := onChunk(chunk); err != nil {
+			return nil, err
+		}
+	}
+
+	if full.Len() == 0 {
+		return nil, classify("openai", emptyResponseError(result.FinishReason))
+	}
+
+	result.Content = full.String()
+	result.Latency = time.Since(start)
+	return result, nil
+}
+
+// openAIFinishReason maps OpenAI finish reasons to our normalized values.
+func openAIFinishReason(reason openai.FinishReason) string {
+	switch reason {
+	case openai.FinishReasonStop:
+		return services.FinishReasonStop
+	case openai.FinishReasonLength:
+		return services.FinishReasonMaxTokens
+	case openai.FinishReasonContentFilter:
+		return services.FinishReasonSafety
+	default:
+		return services.FinishReasonOther
+	}
+}
+
+// newRequest builds a chat completion request from the system prompt, the conversation history
+// and the generation parameters of the request.
+func (c *OpenAIClient) newRequest(history []domain.Message, systemPrompt string, params services.GenerationParams) openai.ChatCompletionRequest {
 	// 1. Convert our internal message format to the format OpenAI requires.
 	messages := make([]openai.ChatCompletionMessage, 0, len(history)+1)
 
@@ -47,19 +162,18 @@ func (c *OpenAIClient) GenerateResponse(ctx context.Context, history []domain.Me
 		})
 	}
 
-	// 4. Create the request to the API.
-	resp, err := c.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model:    openai.GPT4o, // You can choose other models like gpt-4-turbo or gpt-3.5-turbo
-			Messages: messages,
-		},
-	)
-
-	if err != nil {
-		return "", err
+	req := openai.ChatCompletionRequest{
+		Model:     params.Model("openai", c.model),
+		Messages:  messages,
+		MaxTokens: params.MaxTokens,
 	}
-
-	// 5. Return the content of the AI's response.
-	return resp.Choices[0].Message.Content, nil
-}
\ No newline at end of file
+	if params.Temperature != nil {
+		req.Temperature = float32(*params.Temperature)
+		// go-openai drops a zero temperature as unset, which leaves the server default in place.
+		// The smallest positive value is sent instead, which samples the same as zero.
+		if req.Temperature == 0 {
+			req.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+	return req
+}
@@ -0,0 +1,149 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/openai_client_test.go
+package llm
+
+import (
+	"context"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newOpenAIStub returns a client for a server that answers every chat completion with body.
+func newOpenAIStub(t *testing.T, contentType, body string) services.LLMClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewOpenAIClient(Config{APIKey: "test", BaseURL: server.URL + "/v1", Model: "gpt-4o-mini"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// openAIStream turns data payloads into a server-sent event stream.
+func openAIStream(events ...string) string {
+	var b strings.Builder
+	for _, event := range append(events, "[DONE]") {
+		b.WriteString("data: " + event + "\n\n")
+	}
+	return b.String()
+}
+
+func TestOpenAIClientGenerateResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		want      string
+		wantErr   bool
+		wantClass ErrorClass
+	}{
+		{name: "answer", body: `{"model":"gpt-4o-mini","choices":[{"message":{"role":"assistant","content":"Hi!"},"finish_reason":"stop"}],"usage":{"prompt_tokens":4,"completion_tokens":2}}`, want: "Hi!"},
+		{name: "no choices", body: `{"choices":[]}`, wantErr: true, wantClass: ErrorRetryable},
+		{name: "empty completion", body: `{"choices":[{"message":{"role":"assistant","content":""},"finish_reason":"stop"}]}`, wantErr: true, wantClass: ErrorRetryable},
+		{name: "output limit before any text", body: `{"choices":[{"message":{"role":"assistant","content":""},"finish_reason":"length"}]}`, wantErr: true, wantClass: ErrorFatal},
+		{name: "filtered before any text", body: `{"choices":[{"message":{"role":"assistant","content":""},"finish_reason":"content_filter"}]}`, wantErr: true, wantClass: ErrorSafetyBlocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newOpenAIStub(t, "application/json", tt.body)
+			resp, err := client.GenerateResponse(context.Background(), userTurns("hi"), "")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("GenerateResponse() = %q, want an error", resp.Content)
+				}
+				if class := ClassifyError(err); class != tt.wantClass {
+					t.Errorf("error class = %v, want %v (%v)", class, tt.wantClass, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateResponse: %v", err)
+			}
+			if resp.Content != tt.want || resp.PromptTokens != 4 || resp.CompletionTokens != 2 {
+				t.Errorf("response = %+v, want %q with its usage", resp, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIClientStreamResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		want      string
+		wantErr   bool
+		wantClass ErrorClass
+	}{
+		{
+			name: "answer",
+			body: openAIStream(
+				`{"choices":[{"delta":{"role":"assistant","content":"Hel"}}]}`,
+				`{"choices":[{"delta":{"content":"lo!"},"finish_reason":"stop"}]}`,
+				`{"choices":[],"usage":{"prompt_tokens":4,"completion_tokens":2}}`,
+			),
+			want: "Hello!",
+		},
+		{
+			name:      "empty completion",
+			body:      openAIStream(`{"choices":[{"delta":{"role":"assistant"}}]}`, `{"choices":[{"delta":{},"finish_reason":"stop"}]}`),
+			wantErr:   true,
+			wantClass: ErrorRetryable,
+		},
+		{
+			name:      "no events",
+			body:      openAIStream(),
+			wantErr:   true,
+			wantClass: ErrorRetryable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newOpenAIStub(t, "text/event-stream", tt.body)
+			var chunks []string
+			resp, err := client.StreamResponse(context.Background(), userTurns("hi"), "", func(chunk string) error {
+				chunks = append(chunks, chunk)
+				return nil
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("StreamResponse() = %q, want an error", resp.Content)
+				}
+				if class := ClassifyError(err); class != tt.wantClass {
+					t.Errorf("error class = %v, want %v (%v)", class, tt.wantClass, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StreamResponse: %v", err)
+			}
+			if resp.Content != tt.want || strings.Join(chunks, "") != tt.want {
+				t.Errorf("streamed %q, content %q, want %q", chunks, resp.Content, tt.want)
+			}
+			if resp.FinishReason != services.FinishReasonStop || resp.CompletionTokens != 2 {
+				t.Errorf("metadata = %s, %d tokens", resp.FinishReason, resp.CompletionTokens)
+			}
+		})
+	}
+}
+
+func TestOpenAIClientEmptyCompletionFallsBack(t *testing.T) {
+	empty := newOpenAIStub(t, "application/json", `{"choices":[{"message":{"role":"assistant","content":""},"finish_reason":"stop"}]}`)
+	backup := &fakeProvider{outcomes: []fakeOutcome{succeed}}
+	client := NewFallbackClient(testFallbackPolicy(), NamedClient{Name: "openai", Client: empty}, NamedClient{Name: "backup", Client: backup})
+
+	resp, err := client.GenerateResponse(context.Background(), userTurns("hi"), "")
+	if err != nil {
+		t.Fatalf("GenerateResponse: %v", err)
+	}
+	if resp.Provider != "backup" {
+		t.Errorf("provider = %q, want the backup after empty completions", resp.Provider)
+	}
+}
@@ -0,0 +1,218 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/scripted_mock_client.go
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Script is the content of a mock fixture file. Rules are checked in order and the first
+// rule whose conditions all match the request produces the response.
+type Script struct {
+	LatencyMs    int          `json:"latency_ms"`     // Default delay before the response starts
+	ChunkDelayMs int          `json:"chunk_delay_ms"` // Default delay between streamed chunks
+	Rules        []ScriptRule `json:"rules"`
+}
+
+// ScriptRule describes when a scripted response is used and what it looks like.
+// Unset conditions always match, so a rule without conditions works as a default.
+type ScriptRule struct {
+	// Conditions
+	Turn    int    `json:"turn,omitempty"`    // 1-based number of the user turn in the dialog
+	Match   string `json:"match,omitempty"`   // Regular expression on the last user message
+	Persona string `json:"persona,omitempty"` // Regular expression on the system prompt
+
+	// Response
+	Content      string   `json:"content,omitempty"`
+	Chunks       []string `json:"chunks,omitempty"` // Explicit stream chunks; by default Content is streamed word by word
+	FinishReason string   `json:"finish_reason,omitempty"`
+
+	// Behaviour
+	LatencyMs    *int   `json:"latency_ms,omitempty"`
+	ChunkDelayMs *int   `json:"chunk_delay_ms,omitempty"`
+	Error        string `json:"error,omitempty"`       // Error class to fail with: "fatal", "retryable", "rate-limited" or "safety-blocked"
+	ErrorAfter   int    `json:"error_after,omitempty"` // When streaming, fail only after this many chunks were sent
+
+	match      *regexp.Regexp
+	persona    *regexp.Regexp
+	errorClass ErrorClass
+}
+
+// ScriptedMockClient is an LLMClient that answers from a fixture file, so that conversation
+// flows can be exercised deterministically and without network access.
+type ScriptedMockClient struct {
+	script *Script
+}
+
+// NewScriptedMockClient loads a fixture file and creates a client that plays it back.
+func NewScriptedMockClient(path string) (services.LLMClient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mock fixtures: %w", err)
+	}
+	var script Script
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse mock fixtures: %w", err)
+	}
+
+	for i := range script.Rules {
+		rule := &script.Rules[i]
+		if rule.Match != "" {
+			if rule.match, err = regexp.Compile(rule.Match); err != nil {
+				return nil, fmt.Errorf("rule %d: invalid match: %w", i+1, err)
+			}
+		}
+		if rule.Persona != "" {
+			if rule.persona, err = regexp.Compile(rule.Persona); err != nil {
+				return nil, fmt.Errorf("rule %d: invalid persona: %w", i+1, err)
+			}
+		}
+		if rule.Error != "" {
+			if rule.errorClass, err = ParseErrorClass(rule.Error); err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i+1, err)
+			}
+		}
+	}
+	return &ScriptedMockClient{script: &script}, nil
+}
+
+// GenerateResponse answers with the first matching rule.
+func (c *ScriptedMockClient) GenerateResponse(ctx context.Context, history []domain.Message, systemPrompt string) (*services.LLMResponse, error) {
+	start := time.Now()
+	rule, err := c.find(history, systemPrompt)
+	if err != nil {
+		return nil, err
+	}
+	if err := sleepContext(ctx, c.latency(rule)); err != nil {
+		return nil, err
+	}
+	if rule.Error != "" {
+		return nil, rule.err()
+	}
+	return rule.response(history, start), nil
+}
+
+// StreamResponse streams the first matching rule chunk by chunk.
+func (c *ScriptedMockClient) StreamResponse(ctx context.Context, history []domain.Message, systemPrompt string, onChunk services.StreamHandler) (*services.LLMResponse, error) {
+	start := time.Now()
+	rule, err := c.find(history, systemPrompt)
+	if err != nil {
+		return nil, err
+	}
+	if err := sleepContext(ctx, c.latency(rule)); err != nil {
+		return nil, err
+	}
+	if rule.Error != "" && rule.ErrorAfter == 0 {
+		return nil, rule.err()
+	}
+
+	for i, chunk := range rule.chunks() {
+		if rule.Error != "" && i == rule.ErrorAfter {
+			return nil, rule.err()
+		}
+		if i > 0 {
+			if err := sleepContext(ctx, c.chunkDelay(rule)); err != nil {
+				return nil, err
+			}
+		}
+		if err := onChunk(chunk); err != nil {
+			return nil, err
+		}
+	}
+	if rule.Error != "" {
+		return nil, rule.err()
+	}
+	return rule.response(history, start), nil
+}
+
+// find returns the first rule that matches the request.
+func (c *ScriptedMockClient) find(history []domain.Message, systemPrompt string) (*ScriptRule, error) {
+	turn := 0
+	lastUserMessage := ""
+	for _, msg := range history {
+		if msg.Role == domain.RoleUser {
+			turn++
+			lastUserMessage = msg.Content
+		}
+	}
+
+	for i := range c.script.Rules {
+		rule := &c.script.Rules[i]
+		if rule.Turn != 0 && rule.Turn != turn {
+			continue
+		}
+		if rule.match != nil && !rule.match.MatchString(lastUserMessage) {
+			continue
+		}
+		if rule.persona != nil && !rule.persona.MatchString(systemPrompt) {
+			continue
+		}
+		return rule, nil
+	}
+	return nil, &ProviderError{
+		Class: ErrorFatal,
+		Err:   fmt.Errorf("no scripted response matches turn %d: %q", turn, lastUserMessage),
+	}
+}
+
+func (c *ScriptedMockClient) latency(rule *ScriptRule) time.Duration {
+	if rule.LatencyMs != nil {
+		return time.Duration(*rule.LatencyMs) * time.Millisecond
+	}
+	return time.Duration(c.script.LatencyMs) * time.Millisecond
+}
+
+func (c *ScriptedMockClient) chunkDelay(rule *ScriptRule) time.Duration {
+	if rule.ChunkDelayMs != nil {
+		return time.Duration(*rule.ChunkDelayMs) * time.Millisecond
+	}
+	return time.Duration(c.script.ChunkDelayMs) * time.Millisecond
+}
+
+func (r *ScriptRule) chunks() []string {
+	if len(r.Chunks) > 0 {
+		return r.Chunks
+	}
+	return strings.SplitAfter(r.Content, " ")
+}
+
+func (r *ScriptRule) err() error {
+	return &ProviderError{Class: r.errorClass, Err: errors.New("scripted " + r.Error + " error")}
+}
+
+func (r *ScriptRule) response(history []domain.Message, start time.Time) *services.LLMResponse {
+	content := r.Content
+	if content == "" {
+		content = strings.Join(r.Chunks, "")
+	}
+	finishReason := r.FinishReason
+	if finishReason == "" {
+		finishReason = services.FinishReasonStop
+	}
+	return &services.LLMResponse{
+		Content:          content,
+		Provider:         "mock",
+		Model:            "scripted",
+		PromptTokens:     estimatePromptTokens(history),
+		CompletionTokens: len(strings.Fields(content)),
+		Latency:          time.Since(start),
+		FinishReason:     finishReason,
+	}
+}
+
+// estimatePromptTokens gives scripted responses plausible usage numbers for quota accounting.
+func estimatePromptTokens(history []domain.Message) int {
+	total := 0
+	for _, msg := range history {
+		total += len(strings.Fields(msg.Content))
+	}
+	return total
+}
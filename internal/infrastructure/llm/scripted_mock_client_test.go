@@ -0,0 +1,164 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/llm/scripted_mock_client_test.go
+package llm
+
+import (
+	"context"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testScript answers without any delay, so the tests run fast.
+const testScript = `{
+    "rules": [
+        {"match": "(?i)test rate limit", "error": "rate-limited"},
+        {"match": "(?i)test broken stream", "content": "one two three four", "error": "retryable", "error_after": 2},
+        {"match": "(?i)test safety", "error": "safety-blocked"},
+        {"turn": 1, "match": "[А-Яа-я]", "content": "Здравствуйте!"},
+        {"turn": 1, "content": "Hello!"},
+        {"persona": "(?i)coach", "chunks": ["What ", "happened ", "then?"], "finish_reason": "length"},
+        {"match": "^only this$", "content": "Matched."}
+    ]
+}`
+
+func newTestScriptedMock(t *testing.T, script string) services.LLMClient {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewScriptedMockClient(path)
+	if err != nil {
+		t.Fatalf("NewScriptedMockClient: %v", err)
+	}
+	return client
+}
+
+// userTurns builds a history of user messages with AI replies in between.
+func userTurns(messages ...string) []domain.Message {
+	var history []domain.Message
+	for i, content := range messages {
+		if i > 0 {
+			history = append(history, domain.Message{Role: domain.RoleAI, Content: "..."})
+		}
+		history = append(history, domain.Message{Role: domain.RoleUser, Content: content})
+	}
+	return history
+}
+
+func TestScriptedMockClientGenerateResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		history      []domain.Message
+		systemPrompt string
+		want         string
+		wantFinish   string
+		wantErr      bool
+		wantClass    ErrorClass
+	}{
+		{name: "first turn", history: userTurns("hi"), want: "Hello!", wantFinish: services.FinishReasonStop},
+		{name: "first turn in Russian", history: userTurns("привет"), want: "Здравствуйте!", wantFinish: services.FinishReasonStop},
+		{name: "persona of the system prompt", history: userTurns("hi", "and now?"), systemPrompt: "You are a coach.", want: "What happened then?", wantFinish: "length"},
+		{name: "match on the last user message", history: userTurns("hi", "only this"), want: "Matched.", wantFinish: services.FinishReasonStop},
+		{name: "error rule", history: userTurns("please test rate limit"), wantErr: true, wantClass: ErrorRateLimited},
+		{name: "safety block", history: userTurns("test safety"), wantErr: true, wantClass: ErrorSafetyBlocked},
+		{name: "no rule matches", history: userTurns("hi", "something else"), wantErr: true, wantClass: ErrorFatal},
+	}
+
+	client := newTestScriptedMock(t, testScript)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.GenerateResponse(context.Background(), tt.history, tt.systemPrompt)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("GenerateResponse() = %q, want an error", resp.Content)
+				}
+				if class := ClassifyError(err); class != tt.wantClass {
+					t.Errorf("error class = %v, want %v", class, tt.wantClass)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateResponse: %v", err)
+			}
+			if resp.Content != tt.want || resp.FinishReason != tt.wantFinish {
+				t.Errorf("GenerateResponse() = %q (%s), want %q (%s)", resp.Content, resp.FinishReason, tt.want, tt.wantFinish)
+			}
+			if resp.Provider != "mock" {
+				t.Errorf("provider = %q, want mock", resp.Provider)
+			}
+		})
+	}
+}
+
+func TestScriptedMockClientStreamResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		history      []domain.Message
+		systemPrompt string
+		wantChunks   []string
+		wantErr      bool
+		wantClass    ErrorClass
+	}{
+		{name: "content streamed word by word", history: userTurns("hi"), wantChunks: []string{"Hello!"}},
+		{name: "explicit chunks", history: userTurns("hi", "go on"), systemPrompt: "coach", wantChunks: []string{"What ", "happened ", "then?"}},
+		{name: "error after some chunks", history: userTurns("test broken stream"), wantChunks: []string{"one ", "two "}, wantErr: true, wantClass: ErrorRetryable},
+		{name: "error before any chunk", history: userTurns("test rate limit"), wantErr: true, wantClass: ErrorRateLimited},
+	}
+
+	client := newTestScriptedMock(t, testScript)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chunks []string
+			resp, err := client.StreamResponse(context.Background(), tt.history, tt.systemPrompt, func(chunk string) error {
+				chunks = append(chunks, chunk)
+				return nil
+			})
+			if strings.Join(chunks, "|") != strings.Join(tt.wantChunks, "|") {
+				t.Errorf("chunks = %q, want %q", chunks, tt.wantChunks)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("StreamResponse() succeeded, want an error")
+				}
+				if class := ClassifyError(err); class != tt.wantClass {
+					t.Errorf("error class = %v, want %v", class, tt.wantClass)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StreamResponse: %v", err)
+			}
+			if resp.Content != strings.Join(tt.wantChunks, "") {
+				t.Errorf("content = %q, want the joined chunks", resp.Content)
+			}
+		})
+	}
+}
+
+func TestNewScriptedMockClientRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{name: "invalid match", script: `{"rules": [{"match": "(", "content": "x"}]}`},
+		{name: "invalid persona", script: `{"rules": [{"persona": "[", "content": "x"}]}`},
+		{name: "unknown error class", script: `{"rules": [{"error": "sometimes"}]}`},
+		{name: "not json", script: `rules:`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fixtures.json")
+			if err := os.WriteFile(path, []byte(tt.script), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewScriptedMockClient(path); err == nil {
+				t.Error("NewScriptedMockClient() succeeded, want an error")
+			}
+		})
+	}
+}
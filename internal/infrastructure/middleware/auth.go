@@ -27,43 +27,54 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		tokenString := cookie.Value
-		secretKey := []byte(os.Getenv("SESSION_SECRET"))
-
 		// 2. Parse and validate the token.
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return secretKey, nil
-		})
-
-		// 3. Check for errors or if the token is invalid.
-		if err != nil || !token.Valid {
+		userID, err := parseUserID(cookie.Value)
+		if err != nil {
 			// If token is invalid, delete the bad cookie and redirect to login.
 			http.SetCookie(w, &http.Cookie{Name: "jwt_token", Value: "", Path: "/", MaxAge: -1})
 			http.Redirect(w, r, "/", http.StatusSeeOther)
 			return
 		}
-		
-		// 4. Extract user ID from the token's claims.
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok { // Should not happen with our own tokens
-			http.Redirect(w, r, "/", http.StatusSeeOther)
-			return
-		}
 
-		userIDFloat, ok := claims["sub"].(float64)
-		if !ok { // Should not happen with our own tokens
-			http.Redirect(w, r, "/", http.StatusSeeOther)
-			return
-		}
-		userID := int64(userIDFloat)
-
-		// 5. Add the user ID to the request's context.
+		// 3. Add the user ID to the request's context.
 		ctx := context.WithValue(r.Context(), UserIDContextKey, userID)
 		
-		// 6. Call the next handler in the chain with the updated context.
+		// 4. Call the next handler in the chain with the updated context.
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
-}
\ No newline at end of file
+}
+
+// UserIDFromRequest returns the ID of the signed-in user on routes that do not require a login.
+func UserIDFromRequest(r *http.Request) (int64, bool) {
+	cookie, err := r.Cookie("jwt_token")
+	if err != nil {
+		return 0, false
+	}
+	userID, err := parseUserID(cookie.Value)
+	return userID, err == nil
+}
+
+// parseUserID verifies a JWT token and extracts the user ID from its claims.
+func parseUserID(tokenString string) (int64, error) {
+	secretKey := []byte(os.Getenv("SESSION_SECRET"))
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return secretKey, nil
+	})
+	if err != nil || !token.Valid {
+		return 0, fmt.Errorf("invalid token: %v", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok { // Should not happen with our own tokens
+		return 0, fmt.Errorf("unexpected claims type %T", token.Claims)
+	}
+	userIDFloat, ok := claims["sub"].(float64)
+	if !ok { // Should not happen with our own tokens
+		return 0, fmt.Errorf("token has no user ID")
+	}
+	return int64(userIDFloat), nil
+}
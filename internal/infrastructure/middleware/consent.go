@@ -0,0 +1,59 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/middleware/consent.go
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/i18n"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ConsentPath is the page where users accept the legal documents.
+const ConsentPath = "/consent"
+
+// ConsentChecker reports the required legal documents a user has not accepted yet.
+type ConsentChecker interface {
+	Pending(ctx context.Context, userID int64) ([]*domain.LegalDocument, error)
+}
+
+// ConsentMiddleware blocks a route until the user has accepted the current version of every
+// required legal document. Pages redirect to the consent page and come back afterwards; API
+// and WebSocket requests are refused with a 403 the frontend can tell apart from other errors.
+// It must run after AuthMiddleware.
+func ConsentMiddleware(consents ConsentChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value(UserIDContextKey).(int64)
+			if !ok {
+				http.Error(w, "Unauthorized: Not logged in", http.StatusUnauthorized)
+				return
+			}
+
+			pending, err := consents.Pending(r.Context(), userID)
+			if err != nil {
+				log.Printf("Error checking consents of user %d: %v", userID, err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if len(pending) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if strings.HasPrefix(r.URL.Path, "/api/") || r.Header.Get("Upgrade") != "" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": i18n.T(i18n.FromContext(r.Context()), "consent.required"),
+					"code":  "consent_required",
+				})
+				return
+			}
+			http.Redirect(w, r, ConsentPath+"?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		})
+	}
+}
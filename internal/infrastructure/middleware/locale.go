@@ -0,0 +1,42 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/middleware/locale.go
+package middleware
+
+import (
+	"github.com/DauletBai/oilan.org/internal/i18n"
+	"net/http"
+)
+
+// LocaleCookieName is the cookie that remembers the language the user chose.
+const LocaleCookieName = "lang"
+
+// LocaleMiddleware negotiates the locale of every request and stores it in the request's context.
+// The cookie holds the user's language: it is set from their profile when they sign in or change
+// it, and by a "lang" query parameter from the language switcher, which only applies to this
+// browser. Saving a language to the profile is left to the profile endpoint, so that a GET never
+// changes stored data, and no request has to load the profile.
+func LocaleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 1. An explicit switch is remembered in the cookie.
+		chosen := ""
+		if cookie, err := r.Cookie(LocaleCookieName); err == nil {
+			chosen = cookie.Value
+		}
+		if switched := r.URL.Query().Get("lang"); i18n.IsSupported(switched) {
+			SetLocaleCookie(w, switched)
+			chosen = switched
+		}
+
+		// 2. Negotiate and pass the locale on.
+		locale := i18n.Negotiate(chosen, r.Header.Get("Accept-Language"))
+		next.ServeHTTP(w, r.WithContext(i18n.WithLocale(r.Context(), locale)))
+	})
+}
+
+// SetLocaleCookie remembers the language of the user in their browser; an empty language forgets it.
+func SetLocaleCookie(w http.ResponseWriter, language string) {
+	if language == "" {
+		http.SetCookie(w, &http.Cookie{Name: LocaleCookieName, Value: "", Path: "/", MaxAge: -1})
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: LocaleCookieName, Value: language, Path: "/", MaxAge: 365 * 24 * 60 * 60, SameSite: http.SameSiteLaxMode})
+}
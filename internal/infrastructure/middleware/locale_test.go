@@ -0,0 +1,59 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/middleware/locale_test.go
+package middleware
+
+import (
+	"github.com/DauletBai/oilan.org/internal/i18n"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocaleMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		cookie     string
+		accept     string
+		want       string
+		wantCookie string // Value of the cookie set in the response, if any
+	}{
+		{name: "browser language", target: "/", accept: "ru-RU,ru;q=0.9,en;q=0.8", want: "ru"},
+		{name: "cookie wins over the browser", target: "/", cookie: "kk", accept: "ru", want: "kk"},
+		{name: "switch sets the cookie", target: "/?lang=kk", cookie: "ru", accept: "ru", want: "kk", wantCookie: "kk"},
+		{name: "unsupported switch is ignored", target: "/?lang=de", cookie: "ru", want: "ru"},
+		{name: "unsupported cookie is ignored", target: "/", cookie: "xx", accept: "kk", want: "kk"},
+		{name: "default", target: "/", want: i18n.Default},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := LocaleMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = i18n.FromContext(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: LocaleCookieName, Value: tt.cookie})
+			}
+			if tt.accept != "" {
+				r.Header.Set("Accept-Language", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got != tt.want {
+				t.Errorf("locale = %q, want %q", got, tt.want)
+			}
+			var setCookie string
+			for _, c := range w.Result().Cookies() {
+				if c.Name == LocaleCookieName {
+					setCookie = c.Value
+				}
+			}
+			if setCookie != tt.wantCookie {
+				t.Errorf("cookie set to %q, want %q", setCookie, tt.wantCookie)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/middleware/ratelimit.go
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/i18n"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimiter takes a token from a client's bucket of a rate limit policy.
+type RateLimiter interface {
+	Allow(ctx context.Context, policy, key string) (bool, time.Duration, error)
+}
+
+// RateLimitKey identifies the client of a request for rate limiting. It reports false when the
+// request has no such client, which leaves it unlimited.
+type RateLimitKey func(r *http.Request) (string, bool)
+
+// ByUser keys requests by the signed-in user. It must run after AuthMiddleware.
+func ByUser(r *http.Request) (string, bool) {
+	userID, ok := r.Context().Value(UserIDContextKey).(int64)
+	if !ok {
+		return "", false
+	}
+	return "user:" + strconv.FormatInt(userID, 10), true
+}
+
+// TrustedProxies are the reverse proxies whose X-Forwarded-For headers are believed.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies reads a comma-separated list of addresses and CIDR ranges, e.g.
+// "10.0.0.0/8, 192.168.1.10". An empty list trusts no proxy.
+func ParseTrustedProxies(list string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// Contains reports whether an address belongs to a trusted proxy.
+func (p TrustedProxies) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ByIP keys requests by the address of the client, as ClientIP finds it.
+func ByIP(proxies TrustedProxies) RateLimitKey {
+	return func(r *http.Request) (string, bool) {
+		return "ip:" + ClientIP(r, proxies), true
+	}
+}
+
+// ClientIP returns the address of the client that sent a request. Behind a reverse proxy every
+// request comes from the proxy, so when the request comes from a trusted proxy, X-Forwarded-For is
+// walked from the right, past the hops the trusted proxies appended, to the first address that is
+// not a trusted proxy. Entries left of that one were sent by the client and are never used, as a
+// client could pick a fresh address for every request with them.
+func ClientIP(r *http.Request, proxies TrustedProxies) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remote = host
+	}
+	addr, err := netip.ParseAddr(remote)
+	if err != nil || !proxies.Contains(addr) {
+		return remote
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	client := addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := parseForwardedAddr(hops[i])
+		if err != nil {
+			break // A trusted proxy would not have written this, so the client did
+		}
+		client = hop
+		if !proxies.Contains(hop) {
+			break
+		}
+	}
+	return client.Unmap().String()
+}
+
+// parseForwardedAddr parses an entry of X-Forwarded-For, which some proxies write with a port.
+func parseForwardedAddr(entry string) (netip.Addr, error) {
+	entry = strings.TrimSpace(entry)
+	if addrPort, err := netip.ParseAddrPort(entry); err == nil {
+		return addrPort.Addr(), nil
+	}
+	return netip.ParseAddr(entry)
+}
+
+// RateLimitMiddleware refuses requests with 429 Too Many Requests and a Retry-After header once
+// the client's bucket of the policy is empty. API and WebSocket requests get a JSON body the
+// frontend can tell apart from other errors. If the store fails, requests are let through, so
+// that an outage of the store does not take the site down with it.
+func RateLimitMiddleware(limiter RateLimiter, policy string, key RateLimitKey) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client, ok := key(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, retryAfter, err := limiter.Allow(r.Context(), policy, client)
+			if err != nil {
+				log.Printf("Error checking rate limit %q of %s: %v", policy, client, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			seconds := RetryAfterSeconds(retryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			message := i18n.T(i18n.FromContext(r.Context()), "ratelimit.exceeded", seconds)
+			if strings.HasPrefix(r.URL.Path, "/api/") || r.Header.Get("Upgrade") != "" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error":       message,
+					"code":        "rate_limited",
+					"retry_after": seconds,
+				})
+				return
+			}
+			http.Error(w, message, http.StatusTooManyRequests)
+		})
+	}
+}
+
+// RetryAfterSeconds rounds a wait up to whole seconds, as Retry-After has no finer resolution.
+func RetryAfterSeconds(wait time.Duration) int {
+	return int(math.Max(1, math.Ceil(wait.Seconds())))
+}
@@ -0,0 +1,74 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/middleware/ratelimit_test.go
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.10")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		proxies   TrustedProxies
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{name: "no proxy configured", remote: "203.0.113.7:5000", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "request not from a proxy", proxies: proxies, remote: "203.0.113.7:5000", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "one proxy", proxies: proxies, remote: "10.0.0.2:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "spoofed entries on the left", proxies: proxies, remote: "10.0.0.2:5000", forwarded: []string{"1.2.3.4, 5.6.7.8, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "chain of trusted proxies", proxies: proxies, remote: "10.0.0.2:5000", forwarded: []string{"1.2.3.4, 198.51.100.1, 192.168.1.10, 10.1.2.3"}, want: "198.51.100.1"},
+		{name: "several headers", proxies: proxies, remote: "10.0.0.2:5000", forwarded: []string{"1.2.3.4", "198.51.100.1"}, want: "198.51.100.1"},
+		{name: "entry with a port", proxies: proxies, remote: "10.0.0.2:5000", forwarded: []string{"198.51.100.1:4711"}, want: "198.51.100.1"},
+		{name: "ipv6 client", proxies: proxies, remote: "10.0.0.2:5000", forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "garbage stops the walk", proxies: proxies, remote: "10.0.0.2:5000", forwarded: []string{"198.51.100.1, not-an-ip, 10.0.0.3"}, want: "10.0.0.3"},
+		{name: "only proxies", proxies: proxies, remote: "10.0.0.2:5000", forwarded: []string{"10.0.0.5"}, want: "10.0.0.5"},
+		{name: "no header from a proxy", proxies: proxies, remote: "10.0.0.2:5000", want: "10.0.0.2"},
+		{name: "mapped ipv4 proxy", proxies: proxies, remote: "[::ffff:10.0.0.2]:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/auth/google", nil)
+			r.RemoteAddr = tt.remote
+			for _, header := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", header)
+			}
+			if got := ClientIP(r, tt.proxies); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    int
+		wantErr bool
+	}{
+		{list: "", want: 0},
+		{list: "10.0.0.0/8", want: 1},
+		{list: " 10.0.0.1 , 2001:db8::/32,", want: 2},
+		{list: "10.0.0.0/33", wantErr: true},
+		{list: "proxy.local", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			got, err := ParseTrustedProxies(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTrustedProxies(%q) error = %v, wantErr %v", tt.list, err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("ParseTrustedProxies(%q) = %v, want %d entries", tt.list, got, tt.want)
+			}
+		})
+	}
+}
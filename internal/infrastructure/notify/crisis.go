@@ -0,0 +1,75 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/notify/crisis.go
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/app/services"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// WebhookNotifier posts crisis alerts to a webhook, e.g. an incoming webhook of the on-call
+// team's chat. The payload has a "text" field, as Slack and Mattermost expect, plus the event.
+type WebhookNotifier struct {
+	url        string
+	adminURL   string // Base URL of the admin area, used to link to the dialog
+	httpClient *http.Client
+}
+
+// NewWebhookNotifier creates a notifier that posts to url. baseURL is where the app is reachable,
+// e.g. https://oilan.org; without it the alert links to the dialog by its path only.
+func NewWebhookNotifier(url, baseURL string) services.CrisisNotifier {
+	return &WebhookNotifier{
+		url:        url,
+		adminURL:   strings.TrimRight(baseURL, "/") + "/admin/dialogs/",
+		httpClient: &http.Client{}, // The caller's context sets the limit
+	}
+}
+
+// NotifyCrisis posts the alert and fails unless the webhook accepts it.
+func (n *WebhookNotifier) NotifyCrisis(ctx context.Context, event *domain.CrisisEvent) error {
+	link := fmt.Sprintf("%s%d", n.adminURL, event.DialogID)
+	payload := struct {
+		Text  string              `json:"text"`
+		URL   string              `json:"url"`
+		Event *domain.CrisisEvent `json:"event"`
+	}{
+		Text:  fmt.Sprintf("Possible crisis in dialog %d of user %d (%s). Please review: %s", event.DialogID, event.UserID, event.Classifier, link),
+		URL:   link,
+		Event: event,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("crisis webhook failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("crisis webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// LogNotifier writes crisis alerts to the log, for deployments without a webhook.
+type LogNotifier struct{}
+
+// NotifyCrisis logs the alert.
+func (LogNotifier) NotifyCrisis(ctx context.Context, event *domain.CrisisEvent) error {
+	log.Printf("CRISIS detected in dialog %d of user %d by %s (%q); review it under /admin/dialogs/%d",
+		event.DialogID, event.UserID, event.Classifier, event.Evidence, event.DialogID)
+	return nil
+}
@@ -0,0 +1,52 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/repository/memory/ratelimit_memory.go
+package memory
+
+import (
+	"context"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"sync"
+	"time"
+)
+
+// rateLimitStore implements the repository.RateLimitStore interface in the memory of one
+// server instance.
+type rateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*domain.TokenBucket
+}
+
+// NewRateLimitStore creates a new in-memory store for rate limit buckets.
+func NewRateLimitStore() repository.RateLimitStore {
+	return &rateLimitStore{buckets: make(map[string]*domain.TokenBucket)}
+}
+
+// Take takes a token from the bucket under key.
+func (s *rateLimitStore) Take(ctx context.Context, key string, policy domain.RateLimitPolicy) (bool, time.Duration, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		full := domain.NewTokenBucket(policy, now)
+		bucket = &full
+		s.buckets[key] = bucket
+	}
+	allowed, retryAfter := bucket.Take(policy, now)
+	return allowed, retryAfter, nil
+}
+
+// Prune removes the buckets that were not used for longer than idle.
+func (s *rateLimitStore) Prune(ctx context.Context, idle time.Duration) error {
+	cutoff := time.Now().Add(-idle)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, bucket := range s.buckets {
+		if bucket.UpdatedAt.Before(cutoff) {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
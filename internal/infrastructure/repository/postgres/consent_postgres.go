@@ -0,0 +1,99 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/repository/postgres/consent_postgres.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+)
+
+// consentRepo implements the repository.ConsentRepository interface.
+type consentRepo struct {
+	db *sql.DB
+}
+
+// NewConsentRepository creates a new instance of the consent repository.
+func NewConsentRepository(db *sql.DB) repository.ConsentRepository {
+	return &consentRepo{db: db}
+}
+
+// SaveDocument inserts a version of a document unless it exists, and returns the stored version.
+func (r *consentRepo) SaveDocument(ctx context.Context, doc *domain.LegalDocument) (*domain.LegalDocument, error) {
+	title, err := json.Marshal(doc.Title)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(doc.Body)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+        INSERT INTO legal_documents (kind, version, required, title, body)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (kind, version) DO NOTHING;
+    `
+	if _, err := r.db.ExecContext(ctx, query, doc.Kind, doc.Version, doc.Required, title, body); err != nil {
+		return nil, err
+	}
+	return r.FindDocument(ctx, doc.Kind, doc.Version)
+}
+
+// FindDocument finds a version of a document, or returns nil if it does not exist.
+func (r *consentRepo) FindDocument(ctx context.Context, kind string, version int) (*domain.LegalDocument, error) {
+	query := `SELECT id, kind, version, required, title, body, published_at FROM legal_documents WHERE kind = $1 AND version = $2;`
+	doc := &domain.LegalDocument{}
+	var title, body []byte
+	err := r.db.QueryRowContext(ctx, query, kind, version).Scan(
+		&doc.ID, &doc.Kind, &doc.Version, &doc.Required, &title, &body, &doc.PublishedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(title, &doc.Title); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, &doc.Body); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Record appends an entry to the consent ledger.
+func (r *consentRepo) Record(ctx context.Context, consent *domain.Consent) error {
+	query := `
+        INSERT INTO user_consents (user_id, document_id, action)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at;
+    `
+	return r.db.QueryRowContext(ctx, query, consent.UserID, consent.DocumentID, consent.Action).Scan(&consent.ID, &consent.CreatedAt)
+}
+
+// ListByUser returns a user's ledger with the kind and version of every document, oldest entry first.
+func (r *consentRepo) ListByUser(ctx context.Context, userID int64) ([]*domain.Consent, error) {
+	query := `
+        SELECT c.id, c.user_id, c.document_id, d.kind, d.version, c.action, c.created_at
+        FROM user_consents c JOIN legal_documents d ON d.id = c.document_id
+        WHERE c.user_id = $1 ORDER BY c.id;
+    `
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var consents []*domain.Consent
+	for rows.Next() {
+		consent := &domain.Consent{}
+		if err := rows.Scan(&consent.ID, &consent.UserID, &consent.DocumentID, &consent.Kind, &consent.Version, &consent.Action, &consent.CreatedAt); err != nil {
+			return nil, err
+		}
+		consents = append(consents, consent)
+	}
+	return consents, rows.Err()
+}
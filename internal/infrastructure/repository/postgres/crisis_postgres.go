@@ -0,0 +1,75 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/repository/postgres/crisis_postgres.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+)
+
+// crisisRepo implements the repository.CrisisRepository interface.
+type crisisRepo struct {
+	db *sql.DB
+}
+
+// NewCrisisRepository creates a new instance of the crisis repository.
+func NewCrisisRepository(db *sql.DB) repository.CrisisRepository {
+	return &crisisRepo{db: db}
+}
+
+// Record stores a crisis event and flags its dialog in one transaction. A dialog keeps the time
+// its first crisis was detected.
+func (r *crisisRepo) Record(ctx context.Context, event *domain.CrisisEvent) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO crisis_events (dialog_id, message_id, classifier, language, evidence)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at;
+    `
+	err = tx.QueryRowContext(ctx, query,
+		event.DialogID, event.MessageID, event.Classifier, event.Language, event.Evidence,
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE dialogs SET crisis_at = COALESCE(crisis_at, $1) WHERE id = $2;`,
+		event.CreatedAt, event.DialogID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListByDialog returns the crisis events of a dialog, oldest first.
+func (r *crisisRepo) ListByDialog(ctx context.Context, dialogID int64) ([]*domain.CrisisEvent, error) {
+	query := `
+        SELECT e.id, e.dialog_id, d.user_id, e.message_id, e.classifier, e.language, e.evidence, e.created_at
+        FROM crisis_events e JOIN dialogs d ON d.id = e.dialog_id
+        WHERE e.dialog_id = $1 ORDER BY e.id;
+    `
+	rows, err := r.db.QueryContext(ctx, query, dialogID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*domain.CrisisEvent
+	for rows.Next() {
+		event := &domain.CrisisEvent{}
+		if err := rows.Scan(
+			&event.ID, &event.DialogID, &event.UserID, &event.MessageID,
+			&event.Classifier, &event.Language, &event.Evidence, &event.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,101 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/repository/postgres/dialog_lock_postgres.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"log"
+	"time"
+)
+
+// dialogLockNamespace is mixed into the keys of the advisory locks of dialogs, so that they do not
+// collide with advisory locks taken for anything else in the database. It spells "oila".
+const dialogLockNamespace int64 = 0x6F696C61
+
+// dialogLockRetry is how long Lock waits before it tries a lock held by another instance again.
+const dialogLockRetry = 200 * time.Millisecond
+
+// dialogLocker implements the repository.DialogLocker interface with Postgres advisory locks,
+// so that the lock holds across server instances.
+type dialogLocker struct {
+	db      *sql.DB
+	holders chan struct{} // One value per lock held, see NewDialogLocker
+}
+
+// NewDialogLocker creates a new instance of the dialog locker. Advisory locks belong to a database
+// session, so every lock held keeps a connection of the pool. At most half of the pool is handed to
+// locks; the rest stays free for the queries of the lock holders and everyone else.
+func NewDialogLocker(db *sql.DB) repository.DialogLocker {
+	holders := db.Stats().MaxOpenConnections / 2
+	if holders < 1 {
+		holders = defaultMaxOpenConns / 2
+	}
+	return &dialogLocker{db: db, holders: make(chan struct{}, holders)}
+}
+
+// Lock takes the advisory lock of a dialog, trying again until it is free or ctx is done. Between
+// tries the connection goes back to the pool, so waiting for a lock keeps no connection busy.
+func (l *dialogLocker) Lock(ctx context.Context, dialogID int64) (func(), error) {
+	for {
+		unlock, err := l.tryLock(ctx, dialogID)
+		if err != nil || unlock != nil {
+			return unlock, err
+		}
+		select {
+		case <-time.After(dialogLockRetry):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// tryLock takes the lock of a dialog if it is free, and returns a nil unlock function if it is not.
+func (l *dialogLocker) tryLock(ctx context.Context, dialogID int64) (func(), error) {
+	select {
+	case l.holders <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		<-l.holders
+		return nil, err
+	}
+
+	key := dialogLockKey(dialogID)
+	var locked bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1);`, key).Scan(&locked)
+	if err != nil {
+		// The lock may have been taken before the query failed; only closing the session surely frees it.
+		discard(conn)
+	}
+	if err != nil || !locked {
+		conn.Close()
+		<-l.holders
+		return nil, err
+	}
+
+	return func() {
+		// The request may be cancelled by now, but the lock must be released regardless.
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1);`, key); err != nil {
+			log.Printf("Could not release the lock of dialog %d, dropping its connection: %v", dialogID, err)
+			discard(conn)
+		}
+		conn.Close()
+		<-l.holders
+	}, nil
+}
+
+// dialogLockKey returns the key of the advisory lock of a dialog. It is the dialog ID with the
+// namespace in its upper half, so every dialog has a key of its own, however large its ID.
+func dialogLockKey(dialogID int64) int64 {
+	return dialogLockNamespace<<32 ^ dialogID
+}
+
+// discard makes the pool drop a connection when it is closed, which ends its session and with it
+// the session's advisory locks. A connection returned to the pool would keep them.
+func discard(conn *sql.Conn) {
+	conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+}
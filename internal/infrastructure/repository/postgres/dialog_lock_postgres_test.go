@@ -0,0 +1,272 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/repository/postgres/dialog_lock_postgres_test.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// advisoryServer stands in for Postgres: it knows only the advisory lock queries of dialogLocker
+// and keeps the locks in memory, owned by the session (connection) that took them.
+type advisoryServer struct {
+	mu    sync.Mutex
+	locks map[int64]*advisoryConn
+}
+
+func newAdvisoryServer() *advisoryServer {
+	return &advisoryServer{locks: make(map[int64]*advisoryConn)}
+}
+
+// open returns a pool of connections to the server, as one server instance would have.
+func (s *advisoryServer) open(maxOpenConns int) *sql.DB {
+	db := sql.OpenDB(advisoryConnector{server: s})
+	db.SetMaxOpenConns(maxOpenConns)
+	return db
+}
+
+// held reports whether a session holds the lock of a key.
+func (s *advisoryServer) held(key int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.locks[key]
+	return ok
+}
+
+type advisoryConnector struct {
+	server *advisoryServer
+}
+
+func (c advisoryConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &advisoryConn{server: c.server}, nil
+}
+
+func (c advisoryConnector) Driver() driver.Driver {
+	return nil
+}
+
+// advisoryConn is a session of the advisory server.
+type advisoryConn struct {
+	server *advisoryServer
+}
+
+func (c *advisoryConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !strings.Contains(query, "pg_try_advisory_lock") || len(args) != 1 {
+		return nil, fmt.Errorf("unexpected query %q with %d arguments", query, len(args))
+	}
+	key := args[0].Value.(int64)
+	s := c.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	holder, held := s.locks[key]
+	if !held {
+		s.locks[key] = c
+	}
+	return &boolRows{value: !held || holder == c}, nil
+}
+
+func (c *advisoryConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !strings.Contains(query, "pg_advisory_unlock") || len(args) != 1 {
+		return nil, fmt.Errorf("unexpected statement %q with %d arguments", query, len(args))
+	}
+	key := args[0].Value.(int64)
+	s := c.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locks[key] == c {
+		delete(s.locks, key)
+	}
+	return driver.RowsAffected(0), nil
+}
+
+// Close ends the session, which releases its locks.
+func (c *advisoryConn) Close() error {
+	s := c.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, holder := range s.locks {
+		if holder == c {
+			delete(s.locks, key)
+		}
+	}
+	return nil
+}
+
+func (c *advisoryConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *advisoryConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+// boolRows is the result of a query that returns a single boolean.
+type boolRows struct {
+	value bool
+	read  bool
+}
+
+func (r *boolRows) Columns() []string { return []string{"locked"} }
+func (r *boolRows) Close() error      { return nil }
+
+func (r *boolRows) Next(dest []driver.Value) error {
+	if r.read {
+		return io.EOF
+	}
+	r.read = true
+	dest[0] = r.value
+	return nil
+}
+
+func TestDialogLockerExcludesOtherInstances(t *testing.T) {
+	server := newAdvisoryServer()
+	first := NewDialogLocker(server.open(4))
+	second := NewDialogLocker(server.open(4))
+
+	unlock, err := first.Lock(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	// Another dialog is not held up.
+	unlockOther, err := second.Lock(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Lock of another dialog: %v", err)
+	}
+	unlockOther()
+
+	// The same dialog waits until the first instance releases it.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*dialogLockRetry/2)
+	defer cancel()
+	if _, err := second.Lock(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock of a held dialog = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	locked := make(chan func())
+	go func() {
+		unlock, err := second.Lock(context.Background(), 1)
+		if err != nil {
+			t.Errorf("Lock after release: %v", err)
+		}
+		locked <- unlock
+	}()
+	unlock()
+	select {
+	case unlock := <-locked:
+		unlock()
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second instance never got the released lock")
+	}
+	if server.held(dialogLockKey(1)) {
+		t.Error("the lock is still held after both instances released it")
+	}
+}
+
+func TestDialogLockerReleasesOnCancel(t *testing.T) {
+	server := newAdvisoryServer()
+	db := server.open(4)
+	locker := NewDialogLocker(db).(*dialogLocker)
+	other := NewDialogLocker(server.open(4))
+
+	unlock, err := other.Lock(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := locker.Lock(ctx, 1)
+		done <- err
+	}()
+	time.Sleep(dialogLockRetry / 2)
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Lock = %v, want %v", err, context.Canceled)
+	}
+	if n := len(locker.holders); n != 0 {
+		t.Errorf("%d holder slots still taken after the wait was cancelled", n)
+	}
+	if inUse := db.Stats().InUse; inUse != 0 {
+		t.Errorf("%d connections still in use after the wait was cancelled", inUse)
+	}
+}
+
+func TestDialogLockerHoldsAtMostHalfThePool(t *testing.T) {
+	server := newAdvisoryServer()
+	db := server.open(4)
+	locker := NewDialogLocker(db)
+
+	// Two locks take half of the four connections.
+	var unlocks []func()
+	for dialogID := int64(1); dialogID <= 2; dialogID++ {
+		unlock, err := locker.Lock(context.Background(), dialogID)
+		if err != nil {
+			t.Fatalf("Lock of dialog %d: %v", dialogID, err)
+		}
+		unlocks = append(unlocks, unlock)
+	}
+
+	// A third dialog waits for a free slot, although nobody holds its lock.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := locker.Lock(ctx, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock beyond half the pool = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if inUse := db.Stats().InUse; inUse != 2 {
+		t.Errorf("%d connections in use, want 2 for the two locks", inUse)
+	}
+
+	// The other half of the pool stays free for queries.
+	var conns []*sql.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("connection %d of the free half: %v", i+1, err)
+		}
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	unlocks[0]()
+	unlock, err := locker.Lock(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Lock after a slot was freed: %v", err)
+	}
+	unlock()
+	unlocks[1]()
+}
+
+func TestDialogLockKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b int64
+	}{
+		{name: "neighbours", a: 1, b: 2},
+		{name: "2^32 apart", a: 1, b: 1 + 1<<32},
+		{name: "largest ids", a: 1<<63 - 1, b: 1<<63 - 1 - 1<<32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if dialogLockKey(tt.a) == dialogLockKey(tt.b) {
+				t.Errorf("dialogs %d and %d share the key %d", tt.a, tt.b, dialogLockKey(tt.a))
+			}
+		})
+	}
+
+	// The namespace keeps the keys of small dialog IDs apart from small keys used for anything else.
+	if key := dialogLockKey(1); key>>32 != dialogLockNamespace {
+		t.Errorf("key of dialog 1 = %#x, want the namespace %#x in its upper half", key, dialogLockNamespace)
+	}
+}
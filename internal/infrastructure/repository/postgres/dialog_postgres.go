@@ -23,18 +23,19 @@ func NewDialogRepository(db *sql.DB) repository.DialogRepository {
 // Save creates a new dialog session.
 func (r *dialogRepo) Save(ctx context.Context, dialog *domain.Dialog) error {
 	query := `
-        INSERT INTO dialogs (user_id, title, created_at, updated_at)
-        VALUES ($1, $2, $3, $4)
+        INSERT INTO dialogs (user_id, title, persona_id, phase, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
         RETURNING id;
     `
 	now := time.Now()
 	dialog.CreatedAt = now
 	dialog.UpdatedAt = now
 	
-	return r.db.QueryRowContext(ctx, query, dialog.UserID, dialog.Title, dialog.CreatedAt, dialog.UpdatedAt).Scan(&dialog.ID)
+	return r.db.QueryRowContext(ctx, query, dialog.UserID, dialog.Title, dialog.PersonaID, dialog.Phase, dialog.CreatedAt, dialog.UpdatedAt).Scan(&dialog.ID)
 }
 
-// AddMessage adds a new message to an existing dialog and updates the dialog's timestamp.
+// AddMessage adds a new message to an existing dialog, numbers it after the dialog's last message
+// and updates the dialog's timestamp.
 func (r *dialogRepo) AddMessage(ctx context.Context, message *domain.Message) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -42,19 +43,25 @@ func (r *dialogRepo) AddMessage(ctx context.Context, message *domain.Message) er
 	}
 	defer tx.Rollback()
 
-	msgQuery := `
-        INSERT INTO messages (dialog_id, role, content, created_at)
-        VALUES ($1, $2, $3, $4)
-        RETURNING id;
-    `
+	// The dialog row stays locked until the commit, so concurrent messages get consecutive numbers.
 	message.CreatedAt = time.Now()
-	err = tx.QueryRowContext(ctx, msgQuery, message.DialogID, message.Role, message.Content, message.CreatedAt).Scan(&message.ID)
+	dialogQuery := `UPDATE dialogs SET updated_at = $1, message_seq = message_seq + 1 WHERE id = $2 RETURNING message_seq;`
+	err = tx.QueryRowContext(ctx, dialogQuery, message.CreatedAt, message.DialogID).Scan(&message.Seq)
 	if err != nil {
 		return err
 	}
 
-	dialogQuery := `UPDATE dialogs SET updated_at = $1 WHERE id = $2;`
-	_, err = tx.ExecContext(ctx, dialogQuery, message.CreatedAt, message.DialogID)
+	msgQuery := `
+        INSERT INTO messages (dialog_id, seq, role, content, created_at,
+            provider, model, prompt_tokens, completion_tokens, latency_ms, finish_reason, prompt_version_id, redactions)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        RETURNING id;
+    `
+	err = tx.QueryRowContext(ctx, msgQuery,
+		message.DialogID, message.Seq, message.Role, message.Content, message.CreatedAt,
+		message.Provider, message.Model, message.PromptTokens, message.CompletionTokens, message.LatencyMs, message.FinishReason,
+		message.PromptVersionID, message.Redactions,
+	).Scan(&message.ID)
 	if err != nil {
 		return err
 	}
@@ -64,10 +71,10 @@ func (r *dialogRepo) AddMessage(ctx context.Context, message *domain.Message) er
 
 // FindByID finds a single dialog with all its messages.
 func (r *dialogRepo) FindByID(ctx context.Context, id int64) (*domain.Dialog, error) {
-	dialogQuery := `SELECT id, user_id, title, created_at, updated_at FROM dialogs WHERE id = $1;`
+	dialogQuery := `SELECT id, user_id, title, persona_id, phase, crisis_at, created_at, updated_at FROM dialogs WHERE id = $1;`
 	dialog := &domain.Dialog{}
 	err := r.db.QueryRowContext(ctx, dialogQuery, id).Scan(
-		&dialog.ID, &dialog.UserID, &dialog.Title, &dialog.CreatedAt, &dialog.UpdatedAt,
+		&dialog.ID, &dialog.UserID, &dialog.Title, &dialog.PersonaID, &dialog.Phase, &dialog.CrisisAt, &dialog.CreatedAt, &dialog.UpdatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -76,7 +83,11 @@ func (r *dialogRepo) FindByID(ctx context.Context, id int64) (*domain.Dialog, er
 		return nil, err
 	}
 
-	messagesQuery := `SELECT id, dialog_id, role, content, created_at FROM messages WHERE dialog_id = $1 ORDER BY created_at ASC;`
+	messagesQuery := `
+        SELECT id, dialog_id, seq, role, content, created_at,
+               provider, model, prompt_tokens, completion_tokens, latency_ms, finish_reason, prompt_version_id, redactions
+        FROM messages WHERE dialog_id = $1 ORDER BY seq ASC;
+    `
 	rows, err := r.db.QueryContext(ctx, messagesQuery, id)
 	if err != nil {
 		return nil, err
@@ -86,7 +97,11 @@ func (r *dialogRepo) FindByID(ctx context.Context, id int64) (*domain.Dialog, er
 	var messages []domain.Message
 	for rows.Next() {
 		var msg domain.Message
-		if err := rows.Scan(&msg.ID, &msg.DialogID, &msg.Role, &msg.Content, &msg.CreatedAt); err != nil {
+		if err := rows.Scan(
+			&msg.ID, &msg.DialogID, &msg.Seq, &msg.Role, &msg.Content, &msg.CreatedAt,
+			&msg.Provider, &msg.Model, &msg.PromptTokens, &msg.CompletionTokens, &msg.LatencyMs, &msg.FinishReason,
+			&msg.PromptVersionID, &msg.Redactions,
+		); err != nil {
 			return nil, err
 		}
 		messages = append(messages, msg)
@@ -98,7 +113,7 @@ func (r *dialogRepo) FindByID(ctx context.Context, id int64) (*domain.Dialog, er
 
 // FindAllByUserID finds all dialogs for a specific user (without messages for performance).
 func (r *dialogRepo) FindAllByUserID(ctx context.Context, userID int64) ([]*domain.Dialog, error) {
-	query := `SELECT id, user_id, title, created_at, updated_at FROM dialogs WHERE user_id = $1 ORDER BY updated_at DESC;`
+	query := `SELECT id, user_id, title, persona_id, phase, crisis_at, created_at, updated_at FROM dialogs WHERE user_id = $1 ORDER BY updated_at DESC;`
 	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
@@ -108,7 +123,7 @@ func (r *dialogRepo) FindAllByUserID(ctx context.Context, userID int64) ([]*doma
 	var dialogs []*domain.Dialog
 	for rows.Next() {
 		var dialog domain.Dialog
-		if err := rows.Scan(&dialog.ID, &dialog.UserID, &dialog.Title, &dialog.CreatedAt, &dialog.UpdatedAt); err != nil {
+		if err := rows.Scan(&dialog.ID, &dialog.UserID, &dialog.Title, &dialog.PersonaID, &dialog.Phase, &dialog.CrisisAt, &dialog.CreatedAt, &dialog.UpdatedAt); err != nil {
 			return nil, err
 		}
 		dialogs = append(dialogs, &dialog)
@@ -119,7 +134,7 @@ func (r *dialogRepo) FindAllByUserID(ctx context.Context, userID int64) ([]*doma
 
 // GetAll retrieves all dialogs from the database.
 func (r *dialogRepo) GetAll(ctx context.Context) ([]*domain.Dialog, error) {
-	query := `SELECT id, user_id, title, created_at, updated_at FROM dialogs ORDER BY updated_at DESC;`
+	query := `SELECT id, user_id, title, persona_id, phase, crisis_at, created_at, updated_at FROM dialogs ORDER BY updated_at DESC;`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -130,7 +145,7 @@ func (r *dialogRepo) GetAll(ctx context.Context) ([]*domain.Dialog, error) {
 	for rows.Next() {
 		var dialog domain.Dialog
 		if err := rows.Scan(
-			&dialog.ID, &dialog.UserID, &dialog.Title, &dialog.CreatedAt, &dialog.UpdatedAt,
+			&dialog.ID, &dialog.UserID, &dialog.Title, &dialog.PersonaID, &dialog.Phase, &dialog.CrisisAt, &dialog.CreatedAt, &dialog.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,33 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/repository/postgres/feedback_postgres.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+)
+
+// feedbackRepo implements the repository.FeedbackRepository interface.
+type feedbackRepo struct {
+	db *sql.DB
+}
+
+// NewFeedbackRepository creates a new instance of the feedback repository.
+func NewFeedbackRepository(db *sql.DB) repository.FeedbackRepository {
+	return &feedbackRepo{db: db}
+}
+
+// Save stores the score of a message. A message has one score, which the user may change.
+func (r *feedbackRepo) Save(ctx context.Context, feedback *domain.MessageFeedback) error {
+	query := `
+        INSERT INTO message_feedback (message_id, user_id, score, comment)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (message_id) DO UPDATE
+        SET score = EXCLUDED.score, comment = EXCLUDED.comment, updated_at = NOW()
+        RETURNING created_at;
+    `
+	return r.db.QueryRowContext(ctx, query,
+		feedback.MessageID, feedback.UserID, feedback.Score, feedback.Comment,
+	).Scan(&feedback.CreatedAt)
+}
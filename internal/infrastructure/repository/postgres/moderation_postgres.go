@@ -0,0 +1,197 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/repository/postgres/moderation_postgres.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"strings"
+	"time"
+)
+
+// moderationRepo implements the repository.ModerationRepository interface.
+type moderationRepo struct {
+	db *sql.DB
+}
+
+// NewModerationRepository creates a new instance of the moderation repository.
+func NewModerationRepository(db *sql.DB) repository.ModerationRepository {
+	return &moderationRepo{db: db}
+}
+
+const moderationItemColumns = `
+        SELECT i.id, i.dialog_id, d.user_id, i.message_id, i.source, i.severity, i.reason, i.excerpt,
+               i.status, i.assignee_id, i.created_at, i.updated_at, i.resolved_at
+        FROM moderation_items i JOIN dialogs d ON d.id = i.dialog_id`
+
+// moderationOrder puts the most urgent items first, oldest first within a severity.
+const moderationOrder = ` ORDER BY CASE i.severity WHEN 'critical' THEN 0 WHEN 'high' THEN 1 WHEN 'medium' THEN 2 ELSE 3 END, i.created_at`
+
+func scanModerationItem(row interface{ Scan(dest ...any) error }) (*domain.ModerationItem, error) {
+	item := &domain.ModerationItem{}
+	err := row.Scan(
+		&item.ID, &item.DialogID, &item.UserID, &item.MessageID, &item.Source, &item.Severity, &item.Reason, &item.Excerpt,
+		&item.Status, &item.AssigneeID, &item.CreatedAt, &item.UpdatedAt, &item.ResolvedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Create stores a new item and its first event in one transaction.
+func (r *moderationRepo) Create(ctx context.Context, item *domain.ModerationItem, event *domain.ModerationEvent) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO moderation_items (dialog_id, message_id, source, severity, reason, excerpt, status, assignee_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, created_at, updated_at;
+    `
+	err = tx.QueryRowContext(ctx, query,
+		item.DialogID, item.MessageID, item.Source, item.Severity, item.Reason, item.Excerpt, item.Status, item.AssigneeID,
+	).Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	event.ItemID = item.ID
+	if err := insertModerationEvent(ctx, tx, event); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Update stores the state of an item and the event that changed it in one transaction.
+func (r *moderationRepo) Update(ctx context.Context, item *domain.ModerationItem, event *domain.ModerationEvent) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	item.UpdatedAt = time.Now()
+	query := `
+        UPDATE moderation_items
+        SET severity = $1, reason = $2, status = $3, assignee_id = $4, updated_at = $5, resolved_at = $6
+        WHERE id = $7;
+    `
+	_, err = tx.ExecContext(ctx, query,
+		item.Severity, item.Reason, item.Status, item.AssigneeID, item.UpdatedAt, item.ResolvedAt, item.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	event.ItemID = item.ID
+	if err := insertModerationEvent(ctx, tx, event); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertModerationEvent(ctx context.Context, tx *sql.Tx, event *domain.ModerationEvent) error {
+	query := `
+        INSERT INTO moderation_events (item_id, actor_id, action, note)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at;
+    `
+	return tx.QueryRowContext(ctx, query, event.ItemID, event.ActorID, event.Action, event.Note).Scan(&event.ID, &event.CreatedAt)
+}
+
+// FindByID finds an item together with its history.
+func (r *moderationRepo) FindByID(ctx context.Context, id int64) (*domain.ModerationItem, error) {
+	item, err := scanModerationItem(r.db.QueryRowContext(ctx, moderationItemColumns+` WHERE i.id = $1;`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	query := `SELECT id, item_id, actor_id, action, note, created_at FROM moderation_events WHERE item_id = $1 ORDER BY id;`
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event domain.ModerationEvent
+		if err := rows.Scan(&event.ID, &event.ItemID, &event.ActorID, &event.Action, &event.Note, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		item.Events = append(item.Events, event)
+	}
+	return item, rows.Err()
+}
+
+// FindOpen returns the open item of a source for a message, or for the dialog itself when messageID is nil.
+func (r *moderationRepo) FindOpen(ctx context.Context, dialogID int64, messageID *int64, source string) (*domain.ModerationItem, error) {
+	query := moderationItemColumns + `
+        WHERE i.dialog_id = $1 AND i.message_id IS NOT DISTINCT FROM $2 AND i.source = $3 AND i.status = 'open'
+        ORDER BY i.id LIMIT 1;`
+	item, err := scanModerationItem(r.db.QueryRowContext(ctx, query, dialogID, messageID, source))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return item, err
+}
+
+// List returns the items that match the filter, most urgent first.
+func (r *moderationRepo) List(ctx context.Context, filter domain.ModerationFilter) ([]*domain.ModerationItem, error) {
+	var conditions []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Status != "" {
+		add("i.status = $%d", filter.Status)
+	}
+	if filter.Severity != "" {
+		add("i.severity = $%d", filter.Severity)
+	}
+	if filter.Source != "" {
+		add("i.source = $%d", filter.Source)
+	}
+	if filter.AssigneeID != nil {
+		add("i.assignee_id = $%d", *filter.AssigneeID)
+	}
+
+	query := moderationItemColumns
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return r.list(ctx, query+moderationOrder+";", args...)
+}
+
+// ListByDialog returns all items of a dialog, most urgent first.
+func (r *moderationRepo) ListByDialog(ctx context.Context, dialogID int64) ([]*domain.ModerationItem, error) {
+	return r.list(ctx, moderationItemColumns+` WHERE i.dialog_id = $1`+moderationOrder+";", dialogID)
+}
+
+func (r *moderationRepo) list(ctx context.Context, query string, args ...any) ([]*domain.ModerationItem, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*domain.ModerationItem
+	for rows.Next() {
+		item, err := scanModerationItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
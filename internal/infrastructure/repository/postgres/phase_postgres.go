@@ -0,0 +1,96 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/repository/postgres/phase_postgres.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+)
+
+// phaseRepo implements the repository.PhaseRepository interface.
+type phaseRepo struct {
+	db *sql.DB
+}
+
+// NewPhaseRepository creates a new instance of the phase repository.
+func NewPhaseRepository(db *sql.DB) repository.PhaseRepository {
+	return &phaseRepo{db: db}
+}
+
+const phaseTransitionColumns = `id, dialog_id, from_phase, to_phase, trigger, message_id, created_at FROM dialog_phase_transitions`
+
+func scanPhaseTransition(row interface{ Scan(dest ...any) error }) (*domain.PhaseTransition, error) {
+	transition := &domain.PhaseTransition{}
+	err := row.Scan(
+		&transition.ID, &transition.DialogID, &transition.From, &transition.To,
+		&transition.Trigger, &transition.MessageID, &transition.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return transition, nil
+}
+
+// Transition changes the phase of a dialog and records the transition in one transaction.
+// The update only applies while the dialog is still in currentPhase, so concurrent transitions
+// cannot both succeed.
+func (r *phaseRepo) Transition(ctx context.Context, transition *domain.PhaseTransition, currentPhase string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE dialogs SET phase = $1 WHERE id = $2 AND phase = $3;`,
+		transition.To, transition.DialogID, currentPhase)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	query := `
+        INSERT INTO dialog_phase_transitions (dialog_id, from_phase, to_phase, trigger, message_id)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at;
+    `
+	err = tx.QueryRowContext(ctx, query,
+		transition.DialogID, transition.From, transition.To, transition.Trigger, transition.MessageID,
+	).Scan(&transition.ID, &transition.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ListTransitions returns all phase transitions of a dialog, oldest first.
+func (r *phaseRepo) ListTransitions(ctx context.Context, dialogID int64) ([]*domain.PhaseTransition, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+phaseTransitionColumns+` WHERE dialog_id = $1 ORDER BY id;`, dialogID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []*domain.PhaseTransition
+	for rows.Next() {
+		transition, err := scanPhaseTransition(rows)
+		if err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, transition)
+	}
+	return transitions, rows.Err()
+}
+
+// LastTransition returns the most recent phase transition of a dialog, or nil if it never changed phase.
+func (r *phaseRepo) LastTransition(ctx context.Context, dialogID int64) (*domain.PhaseTransition, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+phaseTransitionColumns+` WHERE dialog_id = $1 ORDER BY id DESC LIMIT 1;`, dialogID)
+	transition, err := scanPhaseTransition(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return transition, err
+}
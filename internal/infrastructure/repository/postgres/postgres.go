@@ -6,8 +6,12 @@ import (
 	"fmt"
 	_ "github.com/lib/pq"
 	"os"
+	"strconv"
 )
 
+// defaultMaxOpenConns caps the database connections of a server instance unless DB_MAX_OPEN_CONNS is set.
+const defaultMaxOpenConns = 20
+
 // NewConnection creates a new database connection.
 func NewConnection() (*sql.DB, error) {
 	// Build connection string from environment variables
@@ -24,6 +28,16 @@ func NewConnection() (*sql.DB, error) {
 		return nil, err
 	}
 
+	// Limit the pool, so that load is queued here instead of exhausting the connections Postgres allows.
+	maxOpen := defaultMaxOpenConns
+	if value := os.Getenv("DB_MAX_OPEN_CONNS"); value != "" {
+		if maxOpen, err = strconv.Atoi(value); err != nil || maxOpen < 2 {
+			return nil, fmt.Errorf("DB_MAX_OPEN_CONNS must be a number of at least 2, got %q", value)
+		}
+	}
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxOpen)
+
 	// Ping the database to verify the connection is alive.
 	if err = db.Ping(); err != nil {
 		return nil, err
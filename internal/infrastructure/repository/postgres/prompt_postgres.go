@@ -0,0 +1,94 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/repository/postgres/prompt_postgres.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+)
+
+// promptRepo implements the repository.PromptRepository interface.
+type promptRepo struct {
+	db *sql.DB
+}
+
+// NewPromptRepository creates a new instance of the prompt repository.
+func NewPromptRepository(db *sql.DB) repository.PromptRepository {
+	return &promptRepo{db: db}
+}
+
+const promptVersionColumns = `
+        v.id, v.persona_id, v.version, v.content, v.author_id, COALESCE(u.email, ''), v.note, v.created_at
+        FROM prompt_versions v LEFT JOIN users u ON u.id = v.author_id`
+
+func scanPromptVersion(row interface{ Scan(dest ...any) error }) (*domain.PromptVersion, error) {
+	version := &domain.PromptVersion{}
+	err := row.Scan(
+		&version.ID, &version.PersonaID, &version.Version, &version.Content,
+		&version.AuthorID, &version.AuthorEmail, &version.Note, &version.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// CreateVersion stores a new version with the next version number of its persona.
+func (r *promptRepo) CreateVersion(ctx context.Context, version *domain.PromptVersion) error {
+	query := `
+        INSERT INTO prompt_versions (persona_id, version, content, author_id, note)
+        SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4 FROM prompt_versions WHERE persona_id = $1
+        RETURNING id, version, created_at;
+    `
+	return r.db.QueryRowContext(ctx, query, version.PersonaID, version.Content, version.AuthorID, version.Note).Scan(
+		&version.ID, &version.Version, &version.CreatedAt,
+	)
+}
+
+// FindVersion finds a single prompt version by its ID.
+func (r *promptRepo) FindVersion(ctx context.Context, id int64) (*domain.PromptVersion, error) {
+	version, err := scanPromptVersion(r.db.QueryRowContext(ctx, `SELECT`+promptVersionColumns+` WHERE v.id = $1;`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return version, err
+}
+
+// ListVersions returns all versions of a persona's prompt, newest first.
+func (r *promptRepo) ListVersions(ctx context.Context, personaID string) ([]*domain.PromptVersion, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT`+promptVersionColumns+` WHERE v.persona_id = $1 ORDER BY v.version DESC;`, personaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*domain.PromptVersion
+	for rows.Next() {
+		version, err := scanPromptVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// FindActive returns the most recently activated version of a persona's prompt, or nil if none was activated.
+func (r *promptRepo) FindActive(ctx context.Context, personaID string) (*domain.PromptVersion, error) {
+	query := `SELECT` + promptVersionColumns + `
+        WHERE v.id = (SELECT version_id FROM prompt_activations WHERE persona_id = $1 ORDER BY id DESC LIMIT 1);`
+	version, err := scanPromptVersion(r.db.QueryRowContext(ctx, query, personaID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return version, err
+}
+
+// Activate makes a version the active prompt of its persona. Rolling back is activating an older version.
+func (r *promptRepo) Activate(ctx context.Context, personaID string, versionID int64, activatedBy *int64) error {
+	query := `INSERT INTO prompt_activations (persona_id, version_id, activated_by) VALUES ($1, $2, $3);`
+	_, err := r.db.ExecContext(ctx, query, personaID, versionID, activatedBy)
+	return err
+}
@@ -0,0 +1,159 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/repository/postgres/quota_postgres.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"time"
+)
+
+// quotaRepo implements the repository.QuotaRepository interface.
+type quotaRepo struct {
+	db *sql.DB
+}
+
+// NewQuotaRepository creates a new instance of the quota repository.
+func NewQuotaRepository(db *sql.DB) repository.QuotaRepository {
+	return &quotaRepo{db: db}
+}
+
+// FindOverride returns the admin-defined quota of a user, or nil if the role default applies.
+func (r *quotaRepo) FindOverride(ctx context.Context, userID int64) (*domain.Quota, error) {
+	query := `SELECT messages_per_day, tokens_per_month, concurrent_generations FROM user_quotas WHERE user_id = $1;`
+	quota := &domain.Quota{}
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&quota.MessagesPerDay, &quota.TokensPerMonth, &quota.ConcurrentGenerations,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return quota, nil
+}
+
+// SaveOverride creates or replaces the quota override of a user.
+func (r *quotaRepo) SaveOverride(ctx context.Context, userID int64, quota *domain.Quota) error {
+	query := `
+        INSERT INTO user_quotas (user_id, messages_per_day, tokens_per_month, concurrent_generations, updated_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (user_id) DO UPDATE
+        SET messages_per_day = EXCLUDED.messages_per_day,
+            tokens_per_month = EXCLUDED.tokens_per_month,
+            concurrent_generations = EXCLUDED.concurrent_generations,
+            updated_at = EXCLUDED.updated_at;
+    `
+	_, err := r.db.ExecContext(ctx, query, userID, quota.MessagesPerDay, quota.TokensPerMonth, quota.ConcurrentGenerations, time.Now())
+	return err
+}
+
+// DeleteOverride removes the quota override, so the role default applies again.
+func (r *quotaRepo) DeleteOverride(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM user_quotas WHERE user_id = $1;`, userID)
+	return err
+}
+
+// CountUserMessagesSince counts the messages a user has sent in all their dialogs since the given time.
+func (r *quotaRepo) CountUserMessagesSince(ctx context.Context, userID int64, since time.Time) (int, error) {
+	query := `
+        SELECT COUNT(*)
+        FROM messages m JOIN dialogs d ON d.id = m.dialog_id
+        WHERE d.user_id = $1 AND m.role = $2 AND m.created_at >= $3;
+    `
+	var count int
+	err := r.db.QueryRowContext(ctx, query, userID, domain.RoleUser, since).Scan(&count)
+	return count, err
+}
+
+// SumTokensSince sums the prompt and completion tokens of all AI answers to a user since the given time,
+// together with the tokens recorded in token_usage, e.g. for summaries of their dialogs.
+func (r *quotaRepo) SumTokensSince(ctx context.Context, userID int64, since time.Time) (int, error) {
+	query := `
+        SELECT
+            (SELECT COALESCE(SUM(m.prompt_tokens + m.completion_tokens), 0)
+             FROM messages m JOIN dialogs d ON d.id = m.dialog_id
+             WHERE d.user_id = $1 AND m.role = $2 AND m.created_at >= $3)
+          + (SELECT COALESCE(SUM(u.prompt_tokens + u.completion_tokens), 0)
+             FROM token_usage u
+             WHERE u.user_id = $1 AND u.created_at >= $3);
+    `
+	var total int
+	err := r.db.QueryRowContext(ctx, query, userID, domain.RoleAI, since).Scan(&total)
+	return total, err
+}
+
+// RecordTokenUsage stores tokens spent on a user's behalf outside of a message.
+func (r *quotaRepo) RecordTokenUsage(ctx context.Context, usage *domain.TokenUsage) error {
+	query := `
+        INSERT INTO token_usage (user_id, dialog_id, purpose, prompt_tokens, completion_tokens, created_at)
+        VALUES ($1, $2, $3, $4, $5, NOW())
+        RETURNING id, created_at;
+    `
+	var dialogID sql.NullInt64
+	if usage.DialogID != 0 {
+		dialogID = sql.NullInt64{Int64: usage.DialogID, Valid: true}
+	}
+	return r.db.QueryRowContext(ctx, query, usage.UserID, dialogID, usage.Purpose, usage.PromptTokens, usage.CompletionTokens).
+		Scan(&usage.ID, &usage.CreatedAt)
+}
+
+// AcquireGenerationSlot reserves one of a user's concurrent generation slots for at most ttl. It reports
+// false when the user already holds limit slots; a limit of 0 never refuses. The user's row is locked
+// for the duration of the transaction, so that instances cannot both take the last free slot.
+func (r *quotaRepo) AcquireGenerationSlot(ctx context.Context, userID int64, limit int, ttl time.Duration) (int64, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	// 1. Serialize the slots of this user
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE;`, userID); err != nil {
+		return 0, false, err
+	}
+
+	// 2. Drop the slots of generations whose instance never released them
+	if _, err := tx.ExecContext(ctx, `DELETE FROM generation_slots WHERE user_id = $1 AND expires_at < NOW();`, userID); err != nil {
+		return 0, false, err
+	}
+
+	// 3. Count the slots still held and take a new one if the limit allows it
+	var held int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM generation_slots WHERE user_id = $1;`, userID).Scan(&held); err != nil {
+		return 0, false, err
+	}
+	if limit > 0 && held >= limit {
+		return 0, false, nil
+	}
+	var slotID int64
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO generation_slots (user_id, created_at, expires_at) VALUES ($1, NOW(), NOW() + make_interval(secs => $2)) RETURNING id;`,
+		userID, ttl.Seconds(),
+	).Scan(&slotID)
+	if err != nil {
+		return 0, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
+	}
+	return slotID, true, nil
+}
+
+// ReleaseGenerationSlot frees a slot taken with AcquireGenerationSlot.
+func (r *quotaRepo) ReleaseGenerationSlot(ctx context.Context, slotID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM generation_slots WHERE id = $1;`, slotID)
+	return err
+}
+
+// CountGenerationSlots counts the generations of a user that are running on any instance.
+func (r *quotaRepo) CountGenerationSlots(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM generation_slots WHERE user_id = $1 AND expires_at >= NOW();`, userID,
+	).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,69 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/repository/postgres/ratelimit_postgres.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"time"
+)
+
+// rateLimitStore implements the repository.RateLimitStore interface in Postgres, so that all
+// server instances share the same buckets.
+type rateLimitStore struct {
+	db *sql.DB
+}
+
+// NewRateLimitStore creates a new Postgres store for rate limit buckets.
+func NewRateLimitStore(db *sql.DB) repository.RateLimitStore {
+	return &rateLimitStore{db: db}
+}
+
+// Take takes a token from the bucket under key. The bucket row is locked for the duration of the
+// transaction, and the database clock is used so that instances with skewed clocks agree.
+func (s *rateLimitStore) Take(ctx context.Context, key string, policy domain.RateLimitPolicy) (bool, time.Duration, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	// 1. Create a full bucket on first use
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO rate_limit_buckets (bucket_key, tokens, updated_at)
+        VALUES ($1, $2, NOW())
+        ON CONFLICT (bucket_key) DO NOTHING;
+    `, key, float64(policy.Burst))
+	if err != nil {
+		return false, 0, err
+	}
+
+	// 2. Lock the bucket and take a token
+	var bucket domain.TokenBucket
+	var now time.Time
+	err = tx.QueryRowContext(ctx,
+		`SELECT tokens, updated_at, NOW() FROM rate_limit_buckets WHERE bucket_key = $1 FOR UPDATE;`, key,
+	).Scan(&bucket.Tokens, &bucket.UpdatedAt, &now)
+	if err != nil {
+		return false, 0, err
+	}
+	allowed, retryAfter := bucket.Take(policy, now)
+
+	// 3. Store what is left
+	_, err = tx.ExecContext(ctx, `UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE bucket_key = $1;`,
+		key, bucket.Tokens, bucket.UpdatedAt)
+	if err != nil {
+		return false, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, 0, err
+	}
+	return allowed, retryAfter, nil
+}
+
+// Prune removes the buckets that were not used for longer than idle.
+func (s *rateLimitStore) Prune(ctx context.Context, idle time.Duration) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < NOW() - make_interval(secs => $1);`, idle.Seconds())
+	return err
+}
@@ -0,0 +1,78 @@
+// github.com/DauletBai/oilan.org/internal/infrastructure/repository/postgres/summary_postgres.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DauletBai/oilan.org/internal/domain"
+	"github.com/DauletBai/oilan.org/internal/domain/repository"
+	"time"
+)
+
+// summaryRepo implements the repository.SummaryRepository interface.
+type summaryRepo struct {
+	db *sql.DB
+}
+
+// NewSummaryRepository creates a new instance of the summary repository.
+func NewSummaryRepository(db *sql.DB) repository.SummaryRepository {
+	return &summaryRepo{db: db}
+}
+
+// FindByDialogID returns the stored summary of a dialog, or nil if there is none yet.
+func (r *summaryRepo) FindByDialogID(ctx context.Context, dialogID int64) (*domain.DialogSummary, error) {
+	query := `SELECT dialog_id, content, covered_until_message_id, updated_at FROM dialog_summaries WHERE dialog_id = $1;`
+	summary := &domain.DialogSummary{}
+	err := r.db.QueryRowContext(ctx, query, dialogID).Scan(
+		&summary.DialogID, &summary.Content, &summary.CoveredUntilID, &summary.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return summary, nil
+}
+
+// Save creates or replaces the summary of a dialog.
+func (r *summaryRepo) Save(ctx context.Context, summary *domain.DialogSummary) error {
+	query := `
+        INSERT INTO dialog_summaries (dialog_id, content, covered_until_message_id, updated_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (dialog_id) DO UPDATE
+        SET content = EXCLUDED.content,
+            covered_until_message_id = EXCLUDED.covered_until_message_id,
+            updated_at = EXCLUDED.updated_at;
+    `
+	summary.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query, summary.DialogID, summary.Content, summary.CoveredUntilID, summary.UpdatedAt)
+	return err
+}
+
+// FindRecentByUserID returns the summaries of a user's most recent dialogs started before the given one, newest first.
+func (r *summaryRepo) FindRecentByUserID(ctx context.Context, userID int64, beforeDialogID int64, limit int) ([]*domain.DialogSummary, error) {
+	query := `
+        SELECT s.dialog_id, s.content, s.covered_until_message_id, s.updated_at
+        FROM dialog_summaries s JOIN dialogs d ON d.id = s.dialog_id
+        WHERE d.user_id = $1 AND d.id < $2
+        ORDER BY d.id DESC
+        LIMIT $3;
+    `
+	rows, err := r.db.QueryContext(ctx, query, userID, beforeDialogID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []*domain.DialogSummary
+	for rows.Next() {
+		summary := &domain.DialogSummary{}
+		if err := rows.Scan(&summary.DialogID, &summary.Content, &summary.CoveredUntilID, &summary.UpdatedAt); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
@@ -21,10 +21,10 @@ func NewUserRepository(db *sql.DB) repository.UserRepository {
 
 // FindByEmail finds a user by their email address.
 func (r *userRepo) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
-	query := `SELECT id, provider, provider_id, email, role, created_at FROM users WHERE email = $1;`
+	query := `SELECT id, provider, provider_id, email, role, language, timezone, created_at FROM users WHERE email = $1;`
 	user := &domain.User{}
 	err := r.db.QueryRowContext(ctx, query, email).Scan(
-		&user.ID, &user.Provider, &user.ProviderID, &user.Email, &user.Role, &user.CreatedAt,
+		&user.ID, &user.Provider, &user.ProviderID, &user.Email, &user.Role, &user.Language, &user.Timezone, &user.CreatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -35,10 +35,10 @@ func (r *userRepo) FindByEmail(ctx context.Context, email string) (*domain.User,
 	return user, nil
 }
 
-// Update updates an existing user's data (e.g., their role).
+// Update updates an existing user's data (e.g., their role or profile settings).
 func (r *userRepo) Update(ctx context.Context, user *domain.User) error {
-	query := `UPDATE users SET role = $1 WHERE id = $2;`
-	_, err := r.db.ExecContext(ctx, query, user.Role, user.ID)
+	query := `UPDATE users SET role = $1, language = $2, timezone = $3 WHERE id = $4;`
+	_, err := r.db.ExecContext(ctx, query, user.Role, user.Language, user.Timezone, user.ID)
 	return err
 }
 
@@ -55,7 +55,7 @@ func (r *userRepo) Save(ctx context.Context, user *domain.User) error {
 
 // FindByID finds a user by their unique internal ID.
 func (r *userRepo) FindByID(ctx context.Context, id int64) (*domain.User, error) {
-	query := `SELECT id, provider, provider_id, email, role, created_at FROM users WHERE id = $1;`
+	query := `SELECT id, provider, provider_id, email, role, language, timezone, created_at FROM users WHERE id = $1;`
 	
 	user := &domain.User{}
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
@@ -64,6 +64,8 @@ func (r *userRepo) FindByID(ctx context.Context, id int64) (*domain.User, error)
 		&user.ProviderID,
 		&user.Email,
 		&user.Role,
+		&user.Language,
+		&user.Timezone,
 		&user.CreatedAt,
 	)
 
@@ -80,7 +82,7 @@ func (r *userRepo) FindByID(ctx context.Context, id int64) (*domain.User, error)
 
 // FindByProviderID finds a user by their provider and provider-specific ID.
 func (r *userRepo) FindByProviderID(ctx context.Context, provider string, providerID string) (*domain.User, error) {
-	query := `SELECT id, provider, provider_id, email, role, created_at FROM users WHERE provider = $1 AND provider_id = $2;`
+	query := `SELECT id, provider, provider_id, email, role, language, timezone, created_at FROM users WHERE provider = $1 AND provider_id = $2;`
 
 	user := &domain.User{}
 	err := r.db.QueryRowContext(ctx, query, provider, providerID).Scan(
@@ -89,6 +91,8 @@ func (r *userRepo) FindByProviderID(ctx context.Context, provider string, provid
 		&user.ProviderID,
 		&user.Email,
 		&user.Role,
+		&user.Language,
+		&user.Timezone,
 		&user.CreatedAt,
 	)
 
@@ -104,7 +108,7 @@ func (r *userRepo) FindByProviderID(ctx context.Context, provider string, provid
 
 // GetAll retrieves all users from the database.
 func (r *userRepo) GetAll(ctx context.Context) ([]*domain.User, error) {
-	query := `SELECT id, provider, provider_id, email, role, created_at FROM users ORDER BY created_at DESC;`
+	query := `SELECT id, provider, provider_id, email, role, language, timezone, created_at FROM users ORDER BY created_at DESC;`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -115,7 +119,7 @@ func (r *userRepo) GetAll(ctx context.Context) ([]*domain.User, error) {
 	for rows.Next() {
 		var user domain.User
 		if err := rows.Scan(
-			&user.ID, &user.Provider, &user.ProviderID, &user.Email, &user.Role, &user.CreatedAt,
+			&user.ID, &user.Provider, &user.ProviderID, &user.Email, &user.Role, &user.Language, &user.Timezone, &user.CreatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -5,13 +5,14 @@ import (
 	"net/http"
 	"github.com/DauletBai/oilan.org/internal/domain/repository"
 	"github.com/DauletBai/oilan.org/internal/infrastructure/handlers"
+	"github.com/DauletBai/oilan.org/internal/infrastructure/middleware"
 	"time"
 )
 
 // NewServer now uses the router returned by RegisterRoutes.
-func NewServer(api *handlers.APIHandlers, pages *handlers.PageHandlers, admin *handlers.AdminHandlers, userRepo repository.UserRepository) *http.Server {
+func NewServer(api *handlers.APIHandlers, pages *handlers.PageHandlers, admin *handlers.AdminHandlers, userRepo repository.UserRepository, proxies middleware.TrustedProxies) *http.Server {
 	// The router is now configured inside RegisterRoutes
-	router := handlers.RegisterRoutes(api, pages, admin, userRepo)
+	router := handlers.RegisterRoutes(api, pages, admin, userRepo, proxies)
 
 	return &http.Server{
 		Addr:         ":8080",
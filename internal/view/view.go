@@ -2,34 +2,54 @@
 package view
 
 import (
+	"github.com/DauletBai/oilan.org/internal/i18n"
 	"html/template"
 	"io"
+	"sync/atomic"
 	"time"
 )
 
 // Template represents a single template that can be rendered.
+// It can be re-parsed from its files while requests are being rendered.
 type Template struct {
-	htmlTpl *template.Template
+	files   []string
+	htmlTpl atomic.Pointer[template.Template]
 }
 
 // NewTemplate creates and parses a set of template files.
 func NewTemplate(files ...string) (*Template, error) {
-	// We add a custom function 'currentYear' that will be available in all templates.
-	funcMap := template.FuncMap{
-		"currentYear": func() int {
-			return time.Now().Year()
-		},
+	t := &Template{files: files}
+	if err := t.Reload(); err != nil {
+		return nil, err
 	}
+	return t, nil
+}
 
-	tpl, err := template.New("base.html").Funcs(funcMap).ParseFiles(files...)
+// Reload parses the template files again and swaps in the result. If parsing fails,
+// the previously parsed version stays in use and the error is returned.
+func (t *Template) Reload() error {
+	tpl, err := parse(t.files)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	return &Template{htmlTpl: tpl}, nil
+	t.htmlTpl.Store(tpl)
+	return nil
 }
 
 // Render executes the template with the given data.
 func (t *Template) Render(w io.Writer, name string, data interface{}) error {
-	return t.htmlTpl.ExecuteTemplate(w, name, data)
-}
\ No newline at end of file
+	return t.htmlTpl.Load().ExecuteTemplate(w, name, data)
+}
+
+func parse(files []string) (*template.Template, error) {
+	// We add custom functions that will be available in all templates:
+	// 'currentYear', and 't' which translates a message key, e.g. {{t .locale "chat.send"}}.
+	funcMap := template.FuncMap{
+		"currentYear": func() int {
+			return time.Now().Year()
+		},
+		"t":       i18n.T,
+		"locales": func() []string { return i18n.Supported },
+	}
+	return template.New("base.html").Funcs(funcMap).ParseFiles(files...)
+}
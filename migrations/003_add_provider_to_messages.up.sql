@@ -0,0 +1,4 @@
+-- 003_add_provider_to_messages.up.sql
+
+-- Records which LLM provider produced each AI message (empty for user messages)
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS provider VARCHAR(50) NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+-- 004_create_dialog_summaries_table.up.sql
+
+-- Rolling summary of the older part of a dialog that no longer fits into the model's context window
+CREATE TABLE IF NOT EXISTS dialog_summaries (
+    dialog_id BIGINT PRIMARY KEY REFERENCES dialogs(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    covered_until_message_id BIGINT NOT NULL, -- last message included in the summary
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,8 @@
+-- 005_add_generation_metadata_to_messages.up.sql
+
+-- Generation metadata of AI messages (left empty for user messages)
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS model VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS prompt_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS completion_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS latency_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS finish_reason VARCHAR(20) NOT NULL DEFAULT '';
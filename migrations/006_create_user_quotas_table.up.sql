@@ -0,0 +1,13 @@
+-- 006_create_user_quotas_table.up.sql
+
+-- Per-user quota overrides set by admins; users without a row get their role's default quota
+CREATE TABLE IF NOT EXISTS user_quotas (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    messages_per_day INTEGER NOT NULL DEFAULT 0,       -- 0 means unlimited
+    tokens_per_month INTEGER NOT NULL DEFAULT 0,
+    concurrent_generations INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Usage is counted per user and period
+CREATE INDEX IF NOT EXISTS messages_dialog_id_created_at_idx ON messages (dialog_id, created_at);
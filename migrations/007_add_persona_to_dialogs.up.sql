@@ -0,0 +1,4 @@
+-- 007_add_persona_to_dialogs.up.sql
+
+-- Every dialog created so far used the therapist prompt.
+ALTER TABLE dialogs ADD COLUMN IF NOT EXISTS persona_id VARCHAR(50) NOT NULL DEFAULT 'therapist';
@@ -0,0 +1,37 @@
+-- 008_create_prompt_versions_tables.up.sql
+
+-- Immutable versions of the system prompt of every persona
+CREATE TABLE IF NOT EXISTS prompt_versions (
+    id BIGSERIAL PRIMARY KEY,
+    persona_id VARCHAR(50) NOT NULL,
+    version INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    author_id BIGINT REFERENCES users(id) ON DELETE SET NULL, -- NULL for versions imported from configs
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (persona_id, version)
+);
+
+CREATE OR REPLACE FUNCTION prompt_versions_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'prompt versions are immutable; save a new version instead';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS prompt_versions_no_update ON prompt_versions;
+CREATE TRIGGER prompt_versions_no_update BEFORE UPDATE ON prompt_versions
+    FOR EACH ROW EXECUTE FUNCTION prompt_versions_immutable();
+
+-- Every activation and rollback; the latest row of a persona is its active version
+CREATE TABLE IF NOT EXISTS prompt_activations (
+    id BIGSERIAL PRIMARY KEY,
+    persona_id VARCHAR(50) NOT NULL,
+    version_id BIGINT NOT NULL REFERENCES prompt_versions(id),
+    activated_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    activated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS prompt_activations_persona_id_idx ON prompt_activations (persona_id, id DESC);
+
+-- The prompt version that produced each AI message
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS prompt_version_id BIGINT REFERENCES prompt_versions(id);
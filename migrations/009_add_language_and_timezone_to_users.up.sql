@@ -0,0 +1,5 @@
+-- 009_add_language_and_timezone_to_users.up.sql
+
+-- Profile settings used to personalize prompts; empty means unknown
+ALTER TABLE users ADD COLUMN IF NOT EXISTS language VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,17 @@
+-- 010_add_phases_to_dialogs.up.sql
+
+-- The phase of the guided session a dialog is in; empty for personas without phases
+ALTER TABLE dialogs ADD COLUMN IF NOT EXISTS phase VARCHAR(64) NOT NULL DEFAULT '';
+
+-- Every phase change of a dialog
+CREATE TABLE IF NOT EXISTS dialog_phase_transitions (
+    id BIGSERIAL PRIMARY KEY,
+    dialog_id BIGINT NOT NULL REFERENCES dialogs(id) ON DELETE CASCADE,
+    from_phase VARCHAR(64) NOT NULL,
+    to_phase VARCHAR(64) NOT NULL,
+    trigger VARCHAR(16) NOT NULL, -- "llm" or "user"
+    message_id BIGINT REFERENCES messages(id) ON DELETE SET NULL, -- The AI message that proposed an "llm" transition
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS dialog_phase_transitions_dialog_id_idx ON dialog_phase_transitions (dialog_id, id);
@@ -0,0 +1,17 @@
+-- 011_create_crisis_events_table.up.sql
+
+-- When a crisis was first detected in a dialog; NULL for dialogs without one
+ALTER TABLE dialogs ADD COLUMN IF NOT EXISTS crisis_at TIMESTAMPTZ;
+
+-- Every user message that was classified as a crisis
+CREATE TABLE IF NOT EXISTS crisis_events (
+    id BIGSERIAL PRIMARY KEY,
+    dialog_id BIGINT NOT NULL REFERENCES dialogs(id) ON DELETE CASCADE,
+    message_id BIGINT REFERENCES messages(id) ON DELETE SET NULL, -- The user message that was flagged
+    classifier VARCHAR(32) NOT NULL, -- "keywords" or "llm"
+    language VARCHAR(8) NOT NULL DEFAULT '', -- Language of the crisis response that was sent
+    evidence TEXT NOT NULL DEFAULT '', -- What the classifier matched, e.g. the keyword
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS crisis_events_dialog_id_idx ON crisis_events (dialog_id, id);
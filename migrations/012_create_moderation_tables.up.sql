@@ -0,0 +1,42 @@
+-- 012_create_moderation_tables.up.sql
+
+-- Ratings users give to AI messages
+CREATE TABLE IF NOT EXISTS message_feedback (
+    message_id BIGINT PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    score SMALLINT NOT NULL CHECK (score BETWEEN 1 AND 5),
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Dialogs and messages waiting for review by an admin
+CREATE TABLE IF NOT EXISTS moderation_items (
+    id BIGSERIAL PRIMARY KEY,
+    dialog_id BIGINT NOT NULL REFERENCES dialogs(id) ON DELETE CASCADE,
+    message_id BIGINT REFERENCES messages(id) ON DELETE SET NULL, -- The message that triggered the item, if any
+    source VARCHAR(32) NOT NULL, -- "crisis", "safety_block", "user_report" or "low_feedback"
+    severity VARCHAR(16) NOT NULL, -- "low", "medium", "high" or "critical"
+    reason TEXT NOT NULL DEFAULT '',
+    excerpt TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'open', -- "open" or "resolved"
+    assignee_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS moderation_items_status_idx ON moderation_items (status, created_at);
+CREATE INDEX IF NOT EXISTS moderation_items_dialog_id_idx ON moderation_items (dialog_id);
+
+-- Every change to a moderation item
+CREATE TABLE IF NOT EXISTS moderation_events (
+    id BIGSERIAL PRIMARY KEY,
+    item_id BIGINT NOT NULL REFERENCES moderation_items(id) ON DELETE CASCADE,
+    actor_id BIGINT REFERENCES users(id) ON DELETE SET NULL, -- NULL for changes made by the system
+    action VARCHAR(16) NOT NULL, -- "created", "flagged", "assigned", "unassigned", "noted", "resolved" or "reopened"
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS moderation_events_item_id_idx ON moderation_events (item_id, id);
@@ -0,0 +1,4 @@
+-- 013_add_redactions_to_messages.up.sql
+
+-- How many values of personal data in a message are replaced with placeholders before it is sent to an LLM provider
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS redactions INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,38 @@
+-- 014_create_consent_tables.up.sql
+
+-- Immutable versions of the legal documents users agree to, imported from configs/legal.json
+CREATE TABLE IF NOT EXISTS legal_documents (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL, -- e.g. "terms", "medical_disclaimer" or "research_consent"
+    version INTEGER NOT NULL,
+    required BOOLEAN NOT NULL, -- Chat is blocked until the current version is accepted
+    title JSONB NOT NULL, -- Per locale
+    body JSONB NOT NULL, -- Per locale
+    published_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (kind, version)
+);
+
+-- The consent ledger: every acceptance and withdrawal; the latest row of a user and kind is in force
+CREATE TABLE IF NOT EXISTS user_consents (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    document_id BIGINT NOT NULL REFERENCES legal_documents(id),
+    action VARCHAR(20) NOT NULL, -- "accepted" or "withdrawn"
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS user_consents_user_id_idx ON user_consents (user_id, id);
+
+CREATE OR REPLACE FUNCTION legal_records_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'legal documents and consents are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS legal_documents_no_update ON legal_documents;
+CREATE TRIGGER legal_documents_no_update BEFORE UPDATE ON legal_documents
+    FOR EACH ROW EXECUTE FUNCTION legal_records_immutable();
+
+DROP TRIGGER IF EXISTS user_consents_no_update ON user_consents;
+CREATE TRIGGER user_consents_no_update BEFORE UPDATE ON user_consents
+    FOR EACH ROW EXECUTE FUNCTION legal_records_immutable();
@@ -0,0 +1,10 @@
+-- 015_create_rate_limit_buckets_table.up.sql
+
+-- Token buckets of the rate limiter, shared by all server instances when RATE_LIMIT_STORE=postgres
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    bucket_key VARCHAR(255) PRIMARY KEY, -- Policy and client, e.g. "messages:user:42"
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at);
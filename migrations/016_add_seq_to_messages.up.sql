@@ -0,0 +1,16 @@
+-- 016_add_seq_to_messages.up.sql
+
+-- Position of a message in its dialog; history is ordered by it rather than by the clock
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS seq BIGINT;
+-- Position of the last message of a dialog; the next message takes the one after it
+ALTER TABLE dialogs ADD COLUMN IF NOT EXISTS message_seq BIGINT NOT NULL DEFAULT 0;
+
+-- Number the existing messages in the order they were written
+UPDATE messages m SET seq = numbered.seq
+FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY dialog_id ORDER BY created_at, id) AS seq FROM messages) numbered
+WHERE m.id = numbered.id AND m.seq IS NULL;
+
+UPDATE dialogs d SET message_seq = COALESCE((SELECT MAX(seq) FROM messages WHERE dialog_id = d.id), 0);
+
+ALTER TABLE messages ALTER COLUMN seq SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_dialog_seq ON messages(dialog_id, seq);
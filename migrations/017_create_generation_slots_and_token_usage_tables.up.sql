@@ -0,0 +1,25 @@
+-- 017_create_generation_slots_and_token_usage_tables.up.sql
+
+-- Generations currently running, shared by all server instances so the concurrency quota holds across them.
+-- A slot whose instance crashed is ignored once it expires.
+CREATE TABLE IF NOT EXISTS generation_slots (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_generation_slots_user_id ON generation_slots(user_id);
+
+-- Tokens spent on a user's behalf that are not stored with a message, e.g. by the history summarizer
+CREATE TABLE IF NOT EXISTS token_usage (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    dialog_id BIGINT REFERENCES dialogs(id) ON DELETE SET NULL,
+    purpose VARCHAR(50) NOT NULL, -- e.g. "summary"
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_token_usage_user_id_created_at ON token_usage(user_id, created_at);
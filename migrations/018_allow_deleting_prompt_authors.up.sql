@@ -0,0 +1,15 @@
+-- 018_allow_deleting_prompt_authors.up.sql
+
+-- Deleting a user sets author_id of their prompt versions to NULL through the foreign key, which is an
+-- UPDATE the immutability trigger used to reject, so that no author of a prompt version could be deleted.
+-- The trigger now lets exactly that change through. prompt_activations.activated_by has no such trigger.
+CREATE OR REPLACE FUNCTION prompt_versions_immutable() RETURNS trigger AS $$
+BEGIN
+    IF OLD.author_id IS NOT NULL AND NEW.author_id IS NULL
+        AND (NEW.id, NEW.persona_id, NEW.version, NEW.content, NEW.note, NEW.created_at)
+            IS NOT DISTINCT FROM (OLD.id, OLD.persona_id, OLD.version, OLD.content, OLD.note, OLD.created_at) THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'prompt versions are immutable; save a new version instead';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,25 @@
+-- 019_make_consent_ledger_append_only.up.sql
+
+-- Consents and the documents they refer to were only protected against UPDATE: deleting a user
+-- removed their consents through the foreign key, and rows could be deleted directly. The ledger is
+-- the proof of what a user agreed to, so a user with consents can no longer be deleted, and no row of
+-- either table can be deleted or truncated.
+ALTER TABLE user_consents DROP CONSTRAINT IF EXISTS user_consents_user_id_fkey;
+ALTER TABLE user_consents ADD CONSTRAINT user_consents_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
+
+DROP TRIGGER IF EXISTS legal_documents_no_delete ON legal_documents;
+CREATE TRIGGER legal_documents_no_delete BEFORE DELETE ON legal_documents
+    FOR EACH ROW EXECUTE FUNCTION legal_records_immutable();
+
+DROP TRIGGER IF EXISTS user_consents_no_delete ON user_consents;
+CREATE TRIGGER user_consents_no_delete BEFORE DELETE ON user_consents
+    FOR EACH ROW EXECUTE FUNCTION legal_records_immutable();
+
+DROP TRIGGER IF EXISTS legal_documents_no_truncate ON legal_documents;
+CREATE TRIGGER legal_documents_no_truncate BEFORE TRUNCATE ON legal_documents
+    FOR EACH STATEMENT EXECUTE FUNCTION legal_records_immutable();
+
+DROP TRIGGER IF EXISTS user_consents_no_truncate ON user_consents;
+CREATE TRIGGER user_consents_no_truncate BEFORE TRUNCATE ON user_consents
+    FOR EACH STATEMENT EXECUTE FUNCTION legal_records_immutable();
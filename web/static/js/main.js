@@ -2,8 +2,8 @@
 document.addEventListener('DOMContentLoaded', () => {
     if (document.querySelector('#chat-section')) {
         handleChatPage();
-    } else {
-        // This is for the login page
+    } else if (window.location.pathname === '/') {
+        // This is for the login page; the consent and legal pages need no script
         handleWelcomePage();
     }
 });
@@ -14,9 +14,16 @@ function handleWelcomePage() {
     });
 }
 
+// Version of the chat WebSocket protocol; must match ProtocolVersion in websocket_protocol.go.
+const PROTOCOL_VERSION = 1;
+
 async function handleChatPage() {
     let currentDialogID = null;
     let socket = null;
+    let streamingMessage = null; // The AI message element currently receiving chunks
+    let typingIndicator = null;  // Shown between the ack and the first chunk
+    let nextClientID = 1;
+    const pendingMessages = new Map(); // client_id -> user message element awaiting an ack
 
     const chatWindowCard = document.getElementById('chat-window');
     const chatWindowBody = document.querySelector('#chat-window .card-body');
@@ -24,11 +31,18 @@ async function handleChatPage() {
     const sendButton = document.getElementById('send-button');
     const newChatButton = document.getElementById('new-chat-button');
     const dialogList = document.getElementById('dialog-list');
+    const personaSelect = document.getElementById('persona-select');
+    const personaDescription = document.getElementById('persona-description');
+    const phaseBar = document.getElementById('phase-bar');
+    const phaseName = document.getElementById('phase-name');
+    const phaseDescription = document.getElementById('phase-description');
+    const phaseNext = document.getElementById('phase-next');
+    let personas = [];
 
     /**
      * Appends a message to the chat window UI.
      */
-    function addMessageToWindow(role, content) {
+    function addMessageToWindow(role, content, messageID) {
         const messageWrapper = document.createElement('div');
         messageWrapper.className = `p-2 my-1 d-flex flex-column ${role === 'user' ? 'align-items-end' : 'align-items-start'}`;
 
@@ -46,13 +60,142 @@ async function handleChatPage() {
         messageDiv.textContent = content;
         messageWrapper.appendChild(messageDiv);
         chatWindowBody.appendChild(messageWrapper);
+        if (role === 'ai' && messageID) {
+            addMessageActions(messageDiv, messageID);
+        }
         
         // chatWindowBody.scrollTop = chatWindowBody.scrollHeight;
+        scrollToBottom();
+        return messageDiv;
+    }
+
+    /**
+     * Adds the buttons to score an AI message from 1 to 5 and to report it to the admins.
+     */
+    function addMessageActions(messageDiv, messageID) {
+        messageDiv.dataset.messageId = messageID;
+        const actions = document.createElement('div');
+        actions.className = 'd-flex align-items-center gap-1 mt-1 small text-muted';
+        actions.append(uiText.rate);
+
+        // Loaded messages are rendered before the dialog becomes current, so resolve it on click.
+        const endpoint = () => `/dialogs/${currentDialogID}/messages/${messageID}`;
+        for (let score = 1; score <= 5; score++) {
+            const button = document.createElement('button');
+            button.type = 'button';
+            button.className = 'btn btn-link btn-sm p-0 text-muted text-decoration-none';
+            button.textContent = score;
+            button.addEventListener('click', async () => {
+                try {
+                    await apiFetch(`${endpoint()}/feedback`, 'POST', { score });
+                    actions.textContent = uiText.rated;
+                } catch (error) {
+                    addNotice(`${uiText.error} ${error.message}`, true);
+                }
+            });
+            actions.appendChild(button);
+        }
+
+        const report = document.createElement('button');
+        report.type = 'button';
+        report.className = 'btn btn-link btn-sm p-0 ms-2 text-muted';
+        report.textContent = uiText.report;
+        report.addEventListener('click', async () => {
+            const reason = window.prompt(uiText.reportPrompt);
+            if (reason === null) return;
+            try {
+                await apiFetch(`${endpoint()}/report`, 'POST', { reason });
+                actions.textContent = uiText.reported;
+            } catch (error) {
+                addNotice(`${uiText.error} ${error.message}`, true);
+            }
+        });
+        actions.appendChild(report);
+
+        messageDiv.parentElement.appendChild(actions);
+    }
+
+    /**
+     * Appends a system notice, which is visually distinct from anything the AI said.
+     */
+    function addNotice(content, isError) {
+        const notice = document.createElement('div');
+        notice.className = `my-2 small text-center ${isError ? 'text-danger' : 'text-muted'}`;
+        notice.textContent = content;
+        chatWindowBody.appendChild(notice);
+        scrollToBottom();
+        return notice;
+    }
+
+    function showTyping() {
+        hideTyping();
+        typingIndicator = addNotice(uiText.typing, false);
+    }
+
+    function hideTyping() {
+        if (typingIndicator) {
+            typingIndicator.remove();
+            typingIndicator = null;
+        }
+    }
+
+    function scrollToBottom() {
         requestAnimationFrame(() => {
             chatWindowCard.scrollTo({ top: chatWindowCard.scrollHeight, behavior: 'smooth' });
         });
     }
 
+    /**
+     * Appends a streamed chunk to the AI message that is currently being generated.
+     */
+    function appendChunk(chunk) {
+        hideTyping();
+        if (!streamingMessage) {
+            streamingMessage = addMessageToWindow('ai', '');
+        }
+        streamingMessage.textContent += chunk;
+        scrollToBottom();
+    }
+
+    /**
+     * Finishes the current AI message and unlocks the input.
+     */
+    function finishResponse(content, messageID) {
+        hideTyping();
+        let messageDiv = streamingMessage;
+        if (messageDiv) {
+            // The server sends the complete text, which replaces whatever was streamed.
+            messageDiv.textContent = content;
+        } else {
+            messageDiv = addMessageToWindow('ai', content);
+        }
+        if (messageID) {
+            addMessageActions(messageDiv, messageID);
+        }
+        streamingMessage = null;
+        unlockInput();
+    }
+
+    function unlockInput() {
+        sendButton.disabled = false;
+        messageInput.disabled = false;
+        messageInput.focus();
+    }
+
+    /**
+     * Reports a server error frame as a notice and drops any half-streamed reply.
+     */
+    function handleErrorFrame(frame) {
+        hideTyping();
+        if (streamingMessage) {
+            streamingMessage.parentElement.remove();
+            streamingMessage = null;
+        }
+        pendingMessages.delete(frame.client_id);
+        addNotice(frame.content, true);
+        unlockInput();
+    }
+
     /**
      * Helper for making authenticated API calls.
      */
@@ -66,6 +209,8 @@ async function handleChatPage() {
         if (!response.ok) {
             if (response.status === 401) { window.location.href = '/'; }
             const error = await response.json();
+            // A new version of a required document was published while the chat was open.
+            if (error.code === 'consent_required') { window.location.href = '/consent?next=/chat'; }
             throw new Error(error.error);
         }
         if (response.status === 204) return null;
@@ -78,13 +223,16 @@ async function handleChatPage() {
     function connectWebSocket(dialogID) {
         if (socket) { socket.close(); }
         currentDialogID = dialogID;
+        streamingMessage = null;
+        pendingMessages.clear();
 
         const proto = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
         const wsURL = `${proto}//${window.location.host}/ws/chat?dialogID=${dialogID}`;
 
-        socket = new WebSocket(wsURL);
+        const ws = new WebSocket(wsURL);
+        socket = ws;
 
-        socket.onopen = () => {
+        ws.onopen = () => {
             console.log('WebSocket connection established for dialog', dialogID);
             messageInput.disabled = false;
             sendButton.disabled = false;
@@ -92,23 +240,62 @@ async function handleChatPage() {
             messageInput.focus();
         };
 
-        socket.onmessage = (event) => {
-            addMessageToWindow('ai', event.data);
-            sendButton.disabled = false;
-            messageInput.disabled = false;
-            messageInput.focus();
+        ws.onmessage = (event) => {
+            const frame = JSON.parse(event.data);
+            if (frame.v !== PROTOCOL_VERSION) {
+                console.warn('Unsupported protocol version:', frame.v);
+                return;
+            }
+            switch (frame.type) {
+                case 'dialog_bound':
+                    currentDialogID = frame.dialog_id;
+                    console.log('WebSocket bound to dialog', frame.dialog_id);
+                    break;
+                case 'ack': {
+                    const messageDiv = pendingMessages.get(frame.client_id);
+                    if (messageDiv) {
+                        messageDiv.dataset.messageId = frame.message_id;
+                        pendingMessages.delete(frame.client_id);
+                    }
+                    break;
+                }
+                case 'greeting':
+                    addMessageToWindow('ai', frame.content);
+                    break;
+                case 'typing':
+                    showTyping();
+                    break;
+                case 'ai_chunk':
+                    appendChunk(frame.content);
+                    break;
+                case 'ai_done':
+                    finishResponse(frame.content, frame.message_id);
+                    break;
+                case 'phase':
+                    loadPhase(frame.dialog_id);
+                    break;
+                case 'error':
+                    handleErrorFrame(frame);
+                    break;
+                default:
+                    console.warn('Unknown frame type:', frame.type);
+            }
         };
         
-        socket.onclose = () => {
+        ws.onclose = () => {
             console.log('WebSocket connection closed.');
-            addMessageToWindow('ai', 'Connection has been closed.');
+            // A socket replaced by another dialog closes silently.
+            if (ws !== socket) return;
+            hideTyping();
+            addNotice(uiText.closed, false);
             messageInput.disabled = true;
             sendButton.disabled = true;
         };
 
-        socket.onerror = (error) => {
+        ws.onerror = (error) => {
             console.error('WebSocket error:', error);
-            addMessageToWindow('ai', 'A connection error occurred.');
+            if (ws !== socket) return;
+            addNotice(uiText.connectionError, true);
         };
     }
 
@@ -117,18 +304,54 @@ async function handleChatPage() {
      */
     async function loadDialog(dialogID) {
         chatWindowBody.innerHTML = '';
-        addMessageToWindow('ai', 'Loading history...');
+        addNotice(uiText.loading, false);
         try {
             const dialogData = await apiFetch(`/dialogs/${dialogID}`, 'GET');
             chatWindowBody.innerHTML = ''; // Clear loading message
             if (dialogData.messages && dialogData.messages.length > 0) {
-                 dialogData.messages.forEach(msg => addMessageToWindow(msg.role, msg.content));
-            } else {
-                addMessageToWindow('ai', 'This is a new chat. How can I help?');
+                 dialogData.messages.forEach(msg => addMessageToWindow(msg.role, msg.content, msg.id));
             }
+            // The server greets the user itself when the dialog is still empty.
             connectWebSocket(dialogID);
+            loadPhase(dialogID);
         } catch (error) {
-            addMessageToWindow('ai', `Error loading chat: ${error.message}`);
+            addNotice(`${uiText.loadError} ${error.message}`, true);
+        }
+    }
+
+    /**
+     * Shows the stage of a guided session and the stages the user can move on to.
+     */
+    async function loadPhase(dialogID) {
+        try {
+            renderPhase(await apiFetch(`/dialogs/${dialogID}/phase`, 'GET'));
+        } catch (error) {
+            console.error("Failed to load phase:", error.message);
+            phaseBar.hidden = true;
+        }
+    }
+
+    function renderPhase(state) {
+        phaseBar.hidden = !state.phase;
+        if (!state.phase) return;
+        phaseName.textContent = state.phase.name;
+        phaseDescription.textContent = state.phase.description;
+        phaseNext.innerHTML = '';
+        state.next.forEach(next => {
+            const button = document.createElement('button');
+            button.className = 'btn btn-sm btn-outline-secondary ms-1';
+            button.textContent = `${uiText.phaseNext}: ${next.name}`;
+            button.title = next.description;
+            button.addEventListener('click', () => changePhase(next.id));
+            phaseNext.appendChild(button);
+        });
+    }
+
+    async function changePhase(phaseID) {
+        try {
+            renderPhase(await apiFetch(`/dialogs/${currentDialogID}/phase`, 'POST', { phase: phaseID }));
+        } catch (error) {
+            addNotice(`${uiText.error} ${error.message}`, true);
         }
     }
 
@@ -137,15 +360,15 @@ async function handleChatPage() {
      */
     async function startNewChat() {
         chatWindowBody.innerHTML = ''; 
-        addMessageToWindow('ai', 'Creating a new session...');
+        addNotice(uiText.creating, false);
         messageInput.disabled = true;
         sendButton.disabled = true;
         try {
-            const dialog = await apiFetch('/dialogs', 'POST', { title: 'New Chat' });
+            const dialog = await apiFetch('/dialogs', 'POST', { title: uiText.newTitle, persona_id: personaSelect.value });
             await loadUserDialogs(); // Refresh dialog list
             await loadDialog(dialog.id); // Load the new (empty) dialog
         } catch (error) {
-            addMessageToWindow('ai', `Error: ${error.message}`);
+            addNotice(`${uiText.error} ${error.message}`, true);
         }
     }
 
@@ -155,8 +378,14 @@ async function handleChatPage() {
     function sendMessage() {
         const content = messageInput.value.trim();
         if (!content || !socket || socket.readyState !== WebSocket.OPEN) return;
-        addMessageToWindow('user', content);
-        socket.send(content);
+        const clientID = `c${nextClientID++}`;
+        pendingMessages.set(clientID, addMessageToWindow('user', content));
+        socket.send(JSON.stringify({
+            v: PROTOCOL_VERSION,
+            type: 'user_message',
+            client_id: clientID,
+            content,
+        }));
         messageInput.value = '';
         messageInput.disabled = true;
         sendButton.disabled = true;
@@ -172,7 +401,7 @@ async function handleChatPage() {
                 const item = document.createElement('a');
                 item.href = '#';
                 item.className = 'list-group-item list-group-item-action';
-                item.textContent = dialog.title || `Chat ${dialog.id}`;
+                item.textContent = dialog.title || `${uiText.untitled} ${dialog.id}`;
                 item.dataset.dialogId = dialog.id;
                 if (dialog.id === currentDialogID) {
                     item.classList.add('active');
@@ -184,6 +413,56 @@ async function handleChatPage() {
         }
     }
 
+    /**
+     * Fetches the available personas and fills the selector used for new chats.
+     */
+    async function loadPersonas() {
+        try {
+            personas = await apiFetch('/personas', 'GET');
+        } catch (error) {
+            console.error("Failed to load personas:", error.message);
+            personas = [];
+        }
+        personaSelect.innerHTML = '';
+        personas.forEach(persona => {
+            const option = document.createElement('option');
+            option.value = persona.id;
+            option.textContent = persona.name;
+            option.selected = persona.default;
+            personaSelect.appendChild(option);
+        });
+        personaSelect.hidden = personas.length < 2; // Nothing to choose from
+        showPersonaDescription();
+    }
+
+    function showPersonaDescription() {
+        const persona = personas.find(p => p.id === personaSelect.value);
+        personaDescription.textContent = persona && personas.length > 1 ? persona.description : '';
+    }
+
+    /**
+     * Fills in the user's timezone and language from the browser the first time,
+     * so the assistant knows the local date and which language to answer in.
+     */
+    async function syncProfile() {
+        try {
+            const profile = await apiFetch('/profile', 'GET');
+            const update = {};
+            if (!profile.timezone) {
+                update.timezone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+            }
+            // The page is rendered in the language negotiated from the browser and the language switcher.
+            if (!profile.language && document.documentElement.lang) {
+                update.language = document.documentElement.lang;
+            }
+            if (Object.keys(update).length > 0) {
+                await apiFetch('/profile', 'PUT', update);
+            }
+        } catch (error) {
+            console.error("Failed to sync profile:", error.message);
+        }
+    }
+
     /**
      * Fetches all dialogs for the user and renders them.
      */
@@ -202,6 +481,7 @@ async function handleChatPage() {
         if (event.key === 'Enter') { sendMessage(); }
     });
     newChatButton.addEventListener('click', startNewChat);
+    personaSelect.addEventListener('change', showPersonaDescription);
     
     dialogList.addEventListener('click', (event) => {
         event.preventDefault();
@@ -212,6 +492,8 @@ async function handleChatPage() {
     });
 
     // Initial Load
+    await syncProfile();
+    await loadPersonas();
     const initialDialogs = await apiFetch('/dialogs', 'GET');
     renderDialogList(initialDialogs);
     if (initialDialogs && initialDialogs.length > 0) {